@@ -12,6 +12,8 @@
 
 package exasol
 
+import "encoding/json"
+
 // This is the Version 1.0 API definition based on
 // https://github.com/exasol/websocket-api/blob/master/docs/WebsocketAPIV1.md
 //
@@ -33,33 +35,102 @@ type response struct {
 	Status     string      `json:"status"`
 	Attributes *Attributes `json:"attributes"`
 	Exception  *exception  `json:"exception"`
+	Warnings   []exception `json:"warnings,omitempty"` // Not sent by all server versions
+}
+
+// Used by RawCommand to get both the raw map and the status/exception
+type rawResponse struct {
+	response
+	data map[string]interface{}
 }
 
+func (r *rawResponse) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.data); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &r.response)
+}
+
+// Implemented by all responses so receive can check the status
+// whatever the shape of the rest of the response
+type statusResponse interface {
+	status() (string, *exception)
+}
+
+// Implemented by responses whose responseData must be present on success
+type dataResponse interface {
+	hasData() bool
+}
+
+func (r *response) status() (string, *exception)       { return r.Status, r.Exception }
+func (r *sessionAttrRes) status() (string, *exception) { return r.Status, r.Exception }
+
+func (r *loginRes) hasData() bool          { return r.ResponseData != nil }
+func (r *authResp) hasData() bool          { return r.ResponseData != nil }
+func (r *execRes) hasData() bool           { return r.ResponseData != nil }
+func (r *fetchRes) hasData() bool          { return r.ResponseData != nil }
+func (r *createPrepStmtRes) hasData() bool { return r.ResponseData != nil }
+
 type exception struct {
 	Text    string `json:"text"`
 	Sqlcode string `json:"sqlcode"`
 }
 
 // This struct needs to be visible outside this package
-// because it is returned by GetSessionAttr
+// because it is returned by GetSessionAttr. The attributes not
+// reported by the server are left as their zero values.
 type Attributes struct {
 	Autocommit                  bool   `json:"autocommit,omitempty"`
 	CompressionEnabled          bool   `json:"compressionEnabled,omitempty"`
-	CurrentSchema               string `json:"currentSchema,omitempty"`
-	DateFormat                  string `json:"dateFormat,omitempty"`
-	DateLanguage                string `json:"dateLanguage,omitempty"`
-	DatetimeFormat              string `json:"datetimeFormat,omitempty"`
+	CurrentSchema               string `json:"currentSchema,omitempty"`  // "" if no schema is open
+	DateFormat                  string `json:"dateFormat,omitempty"`     // e.g. YYYY-MM-DD
+	DateLanguage                string `json:"dateLanguage,omitempty"`   // e.g. ENG
+	DatetimeFormat              string `json:"datetimeFormat,omitempty"` // e.g. YYYY-MM-DD HH24:MI:SS.FF6
 	DefaultLikeEscapeCharacter  string `json:"defaultLikeEscapeCharacter,omitempty"`
-	FeedbackInterval            uint32 `json:"feedbackInterval,omitempty"`
-	NumericCharacters           string `json:"numericCharacters,omitempty"`
-	OpenTransaction             int    `json:"openTransaction,omitempty"` // Boolean, really (1/0)
-	QueryTimeout                uint32 `json:"queryTimeout,omitempty"`
+	FeedbackInterval            uint32 `json:"feedbackInterval,omitempty"`  // In seconds
+	NumericCharacters           string `json:"numericCharacters,omitempty"` // The decimal then group separator
+	OpenTransaction             int    `json:"openTransaction,omitempty"`   // Boolean, really (1/0), see InTransaction
+	QueryTimeout                uint32 `json:"queryTimeout,omitempty"`      // In seconds, 0 for none
 	SnapshotTransactionsEnabled bool   `json:"snapshotTransactionsEnabled,omitempty"`
 	TimestampUtcEnabled         bool   `json:"timestampUtcEnabled,omitempty"`
-	Timezone                    string `json:"timezone,omitempty"`
+	Timezone                    string `json:"timezone,omitempty"` // e.g. EUROPE/BERLIN, see Conn.SessionTimeZone
 	TimeZoneBehavior            string `json:"timeZoneBehavior,omitempty"`
 }
 
+// Whether the session has an open transaction
+func (a *Attributes) InTransaction() bool { return a.OpenTransaction != 0 }
+
+// SessionAttributes is used by Get/SetAttributes. The fields are pointers
+// so that only the attributes you set are sent (including false/zero values)
+type SessionAttributes struct {
+	Autocommit                  *bool   `json:"autocommit,omitempty"`
+	CompressionEnabled          *bool   `json:"compressionEnabled,omitempty"` // Read-only
+	CurrentSchema               *string `json:"currentSchema,omitempty"`
+	DateFormat                  *string `json:"dateFormat,omitempty"`
+	DateLanguage                *string `json:"dateLanguage,omitempty"`
+	DatetimeFormat              *string `json:"datetimeFormat,omitempty"`
+	DefaultLikeEscapeCharacter  *string `json:"defaultLikeEscapeCharacter,omitempty"`
+	FeedbackInterval            *uint32 `json:"feedbackInterval,omitempty"`
+	NumericCharacters           *string `json:"numericCharacters,omitempty"`
+	OpenTransaction             *int    `json:"openTransaction,omitempty"` // Read-only. Boolean, really (1/0)
+	QueryTimeout                *uint32 `json:"queryTimeout,omitempty"`
+	SnapshotTransactionsEnabled *bool   `json:"snapshotTransactionsEnabled,omitempty"`
+	TimestampUtcEnabled         *bool   `json:"timestampUtcEnabled,omitempty"`
+	Timezone                    *string `json:"timezone,omitempty"`
+	TimeZoneBehavior            *string `json:"timeZoneBehavior,omitempty"`
+}
+
+type sessionAttrReq struct {
+	Command    string      `json:"command"`
+	Attributes interface{} `json:"attributes,omitempty"` // *SessionAttributes or a map (for SetAttribute)
+}
+
+type sessionAttrRes struct {
+	Status     string             `json:"status"`
+	Attributes *SessionAttributes `json:"attributes"`
+	Exception  *exception         `json:"exception"`
+}
+
 type loginReq struct {
 	Command         string      `json:"command"`
 	Attributes      *Attributes `json:"attributes,omitempty"`
@@ -98,6 +169,8 @@ type authResp struct {
 }
 
 type AuthData struct {
+	// Session IDs are 20 digits so this has to be decoded straight
+	// into an integer in order to avoid float64 precision loss
 	SessionID             uint64  `json:"sessionId"`
 	ProtocolVersion       float64 `json:"protocolVersion"`
 	ReleaseVersion        string  `json:"releaseVersion"`
@@ -117,6 +190,12 @@ type execReq struct {
 	SqlText    string      `json:"sqlText"`
 }
 
+type execBatchReq struct {
+	Command    string      `json:"command"`
+	Attributes *Attributes `json:"attributes,omitempty"`
+	SqlTexts   []string    `json:"sqlTexts"`
+}
+
 type execPrepStmt struct {
 	Command         string          `json:"command"`
 	Attributes      *Attributes     `json:"attributes,omitempty"`
@@ -158,7 +237,10 @@ type column struct {
 }
 
 // This is visible outside of this package because
-// it is passed in as a connection parameter
+// it is passed in as a connection parameter.
+// Which fields are set depends on the Type: Precision/Scale for DECIMAL,
+// Size/CharacterSet for CHAR/VARCHAR, Size for HASHTYPE, SRId for GEOMETRY,
+// WithLocalTimeZone for TIMESTAMP and Precision/Fraction for INTERVALs.
 type DataType struct {
 	Type              string `json:"type"`
 	Precision         int    `json:"precision"`
@@ -208,10 +290,9 @@ type createPrepStmtRes struct {
 type createPrepStmtData struct {
 	StatementHandle int           `json:"statementHandle"`
 	ParameterData   parameterData `json:"parameterData"`
-	// The API defines the next two fields but they don't
-	// seem to make sense in the context of creating a prepared statement
-	//numResults
-	//results [...]
+	// For queries this describes the result set (without any data)
+	NumResults uint64   `json:"numResults"`
+	Results    []result `json:"results"`
 }
 
 type parameterData struct {
@@ -0,0 +1,121 @@
+/*
+	Conversion of bind values into what Exasol expects in the JSON
+	data of executePreparedStatement requests.
+
+    AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binds are columnar. A new matrix is returned so the caller's binds
+// aren't modified. nil binds are sent as JSON nulls which Exasol treats
+// as SQL NULLs. Values implementing driver.Valuer (e.g. sql.NullString,
+// sql.NullInt64, sql.NullFloat64, sql.NullTime) are converted to their
+// underlying value or NULL if they aren't valid.
+// time.Time values are formatted as per the column's data type.
+// bools (and sql.NullBool) are sent as JSON booleans; for BOOLEAN columns
+// strings such as "true", "f" or "0" are converted too (e.g. from CSV).
+// Integers beyond float64's exact range (±2^53) and big.Int/big.Float values
+// are sent as decimal strings so Exasol receives them exactly.
+func (c *Conn) convertBinds(binds [][]interface{}, cols []column) ([][]interface{}, error) {
+	var sessionLoc *time.Location
+	ret := make([][]interface{}, len(binds))
+	for col, vals := range binds {
+		var dt DataType
+		if col < len(cols) {
+			dt = cols[col].DataType
+		}
+		ret[col] = make([]interface{}, len(vals))
+		for row, val := range vals {
+			v, err := convertBind(val)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid bind value in row %d, column %d: %s", row+1, col+1, err)
+			}
+			if t, ok := v.(time.Time); ok {
+				if dt.WithLocalTimeZone {
+					if sessionLoc == nil {
+						sessionLoc, err = c.SessionTimeZone()
+						if err != nil {
+							return nil, err
+						}
+					}
+					t = t.In(sessionLoc)
+				}
+				v = FormatTimestamp(dt, t)
+			}
+			if s, ok := v.(string); ok && strings.ToUpper(dt.Type) == "BOOLEAN" {
+				v, err = strconv.ParseBool(s)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid bind value in row %d, column %d: %q isn't a BOOLEAN", row+1, col+1, s)
+				}
+			}
+			ret[col][row] = v
+		}
+	}
+	return ret, nil
+}
+
+func convertBind(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case driver.Valuer:
+		dv, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		return convertBind(dv)
+	case int:
+		return exactInt(int64(v)), nil
+	case int64:
+		return exactInt(v), nil
+	case uint:
+		return exactUint(uint64(v)), nil
+	case uint64:
+		return exactUint(v), nil
+	case *big.Int:
+		if v == nil {
+			return nil, nil
+		}
+		return v.String(), nil
+	case big.Int:
+		return v.String(), nil
+	case *big.Float:
+		if v == nil {
+			return nil, nil
+		}
+		return v.Text('f', -1), nil
+	}
+	return val, nil
+}
+
+func exactInt(i int64) interface{} {
+	if i > maxExactInt || i < -maxExactInt {
+		return strconv.FormatInt(i, 10)
+	}
+	return i
+}
+
+func exactUint(u uint64) interface{} {
+	if u > maxExactInt {
+		return strconv.FormatUint(u, 10)
+	}
+	return u
+}
@@ -15,6 +15,13 @@
 	slices of about 10KB.
 	When reading you will receive a series of slices in the 10KB range
 	which you will need to concatenate to form the full dataset.
+	Alternatively set ExportOptions.RowAligned and each slice will
+	contain only complete CSV rows so it can be parsed on its own.
+
+	There are also Reader/Writer variants of the Stream interface which
+	upload from an io.Reader or download to an io.Writer (e.g. a file or
+	an HTTP body) handling the chunking for you. ImportFile and ExportFile
+	go one step further and take the path of a (optionally gzipped) file.
 
 
 	For each of the Bulk & Streaming interfaces there are 4 possible interactions:
@@ -34,10 +41,6 @@
 	   statement similar to that in the getTableExportSQL routine below
 
 
-	TODO:
-	1) Automate the sizing of incoming streamed slices
-
-
 	AUTHOR
 
 	Grant Street Group <developers@grantstreet.com>
@@ -53,134 +56,319 @@ package exasol
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
-func (c *Conn) BulkInsert(schema, table string, data *bytes.Buffer) (err error) {
-	sql := c.getTableImportSQL(schema, table)
-	return c.BulkExecute(sql, data)
+// Returned by the Bulk methods when passed a nil *bytes.Buffer
+var ErrNilBuffer = errors.New("Nil buffer")
+
+// Returned by the Stream methods when passed a nil chan
+var ErrNilChan = errors.New("Nil chan")
+
+// Returned by ReaderExecute/ReaderInsert when passed a nil io.Reader
+var ErrNilReader = errors.New("Nil reader")
+
+// Returned by WriterQuery/WriterSelect when passed a nil io.Writer
+var ErrNilWriter = errors.New("Nil writer")
+
+func (c *Conn) BulkInsert(schema, table string, data *bytes.Buffer, opts ...ImportOptions) (int64, error) {
+	sql := c.getTableImportSQL(schema, table, opts...)
+	return c.BulkExecute(sql, data, opts...)
 }
 
-func (c *Conn) BulkExecute(sql string, data *bytes.Buffer) error {
+// Returns the number of rows imported.
+// Only ImportOptions.Progress applies to BulkExecute
+func (c *Conn) BulkExecute(sql string, data *bytes.Buffer, opts ...ImportOptions) (int64, error) {
 	if data == nil {
-		return fmt.Errorf("You must pass in a bytes.Buffer pointer to BulkExecute")
+		return 0, c.errorf("%w: You must pass in a bytes.Buffer pointer to BulkExecute", ErrNilBuffer)
 	}
 	dataChan := make(chan []byte, 1)
 	dataChan <- data.Bytes()
 	close(dataChan)
-	return c.StreamExecute(sql, dataChan)
+	return c.StreamExecute(sql, dataChan, opts...)
 }
 
-func (c *Conn) BulkSelect(schema, table string, data *bytes.Buffer) (err error) {
-	sql := c.getTableExportSQL(schema, table)
+func (c *Conn) BulkSelect(schema, table string, data *bytes.Buffer, opts ...ExportOptions) (int64, error) {
+	sql := c.getTableExportSQL(schema, table, opts...)
 	return c.BulkQuery(sql, data)
 }
 
-func (c *Conn) BulkQuery(sql string, data *bytes.Buffer) error {
+// Returns the number of bytes appended to data. If the export fails partway
+// the partial data is removed again (leaving data as it was passed in)
+// so it's never mistaken for a complete export.
+func (c *Conn) BulkQuery(sql string, data *bytes.Buffer) (int64, error) {
 	if data == nil {
-		return fmt.Errorf("You must pass in a bytes.Buffer pointer to BulkQuery")
+		return 0, c.errorf("%w: You must pass in a bytes.Buffer pointer to BulkQuery", ErrNilBuffer)
 	}
+	start := data.Len()
 	rows := c.StreamQuery(sql)
 	for b := range rows.Data {
 		data.Write(b)
+		rows.Recycle(b)
 	}
 	if rows.Error != nil {
-		return fmt.Errorf("Unable to BulkQuery: %s", rows.Error)
+		data.Truncate(start)
+		return 0, fmt.Errorf("Unable to BulkQuery: %w", rows.Error)
 	}
-	return nil
+	return int64(data.Len() - start), nil
 }
 
-func (c *Conn) StreamInsert(schema, table string, data <-chan []byte) (err error) {
-	sql := c.getTableImportSQL(schema, table)
-	return c.StreamExecute(sql, data)
+func (c *Conn) StreamInsert(schema, table string, data <-chan []byte, opts ...ImportOptions) (int64, error) {
+	sql := c.getTableImportSQL(schema, table, opts...)
+	return c.StreamExecute(sql, data, opts...)
 }
 
-func (c *Conn) StreamExecute(origSQL string, data <-chan []byte) error {
+// The data is read from the chan as it's uploaded so a producer writing
+// faster than Exasol can consume blocks on the chan (per its buffer size).
+// Returns the number of rows imported.
+// Only ImportOptions.Progress applies to StreamExecute
+func (c *Conn) StreamExecute(origSQL string, data <-chan []byte, opts ...ImportOptions) (int64, error) {
+	return c.StreamExecuteContext(context.Background(), origSQL, data, opts...)
+}
+
+// The same as StreamExecute but cancelling ctx shuts down the proxy which
+// aborts the IMPORT (so none of the data is committed) returning ctx.Err().
+func (c *Conn) StreamExecuteContext(
+	ctx context.Context, origSQL string, data <-chan []byte, opts ...ImportOptions,
+) (int64, error) {
 	if data == nil {
-		return fmt.Errorf("You must pass in a []byte chan to StreamExecute")
+		return 0, c.errorf("%w: You must pass in a []byte chan to StreamExecute", ErrNilChan)
 	}
+	return c.streamExecute(ctx, origSQL, data, importOpts(opts), nil)
+}
 
-	// Retry twice cuz it seems we sometimes get sentient errors
-	for range []int{1, 2} {
-		bytesWritten, err := c.streamExecuteNoRetry(origSQL, data)
-		if err != nil {
-			if retryableError(err) {
-				if bytesWritten == 0 {
-					c.error("Retrying...")
-					continue
-				}
-				// If there was an error while writing the data
-				// we've lost the data we've written so we can't retry
-				c.error("Data already sent can't retry...")
-			}
-			c.error(err.Error())
-			return err
+// StreamWriter is an io.WriteCloser uploading the CSV data written to it
+// to an IMPORT (see StreamExecuteWriter). Close finishes the upload and
+// waits for the IMPORT whereas Cancel aborts it so none of the data is
+// committed. Only Cancel may be called concurrently with the other methods.
+type StreamWriter struct {
+	RowsImported int64 // Set once Close returns (unless there's an error)
+
+	data   chan []byte
+	done   chan struct{} // Closed once the IMPORT has finished
+	ctx    context.Context
+	cancel context.CancelFunc
+	err    error // Only set before done is closed
+	mux    sync.Mutex
+	closed bool // Set (under mux) once data is closed
+}
+
+func (c *Conn) StreamInsertWriter(schema, table string, opts ...ImportOptions) *StreamWriter {
+	sql := c.getTableImportSQL(schema, table, opts...)
+	return c.StreamExecuteWriter(sql, opts...)
+}
+
+// The IMPORT is started straight away and fed by the Writes.
+// Only ImportOptions.Progress applies to StreamExecuteWriter
+func (c *Conn) StreamExecuteWriter(origSQL string, opts ...ImportOptions) *StreamWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &StreamWriter{
+		data:   make(chan []byte, chanSize(c.Conf.StreamChanSize, defaultStreamChanSize)),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go func() {
+		defer close(w.done)
+		w.RowsImported, w.err = c.streamExecute(ctx, origSQL, w.data, importOpts(opts), nil)
+	}()
+	return w
+}
+
+// Write queues a copy of p for uploading (so p can be reused). It blocks
+// while Exasol is behind and fails once the IMPORT has ended or been cancelled.
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		// Sending on the closed data chan would panic
+		if w.err != nil {
+			return 0, w.err
 		}
-		break
+		return 0, errors.New("Unable to write: the StreamWriter is closed")
 	}
-	return nil
+	select {
+	case w.data <- append([]byte(nil), p...):
+		return len(p), nil
+	case <-w.done:
+	case <-w.ctx.Done():
+		<-w.done
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return 0, errors.New("Unable to write: the IMPORT has finished")
+}
+
+// Close ends the data and waits for the IMPORT to finish, returning its error if any
+func (w *StreamWriter) Close() error {
+	w.mux.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.data)
+	}
+	w.mux.Unlock()
+	<-w.done
+	w.cancel() // Releases the context
+	return w.err
+}
+
+// Cancel aborts the IMPORT (so none of the data is committed) and waits for
+// it to stop. Writes then fail and Close returns an error wrapping context.Canceled.
+func (w *StreamWriter) Cancel() {
+	w.cancel()
+	<-w.done
+}
+
+// RetryPolicy controls how the Stream/Bulk methods retry the transient
+// errors we occasionally get when Exasol connects to the proxy.
+// Zero values fall back to the defaults.
+type RetryPolicy struct {
+	MaxAttempts int              // Total number of attempts (defaults to 3)
+	Backoff     time.Duration    // How long to wait between attempts (defaults to none)
+	Retryable   func(error) bool // Which errors to retry (defaults to proxy connection refused/reset errors)
+}
+
+// ImportOptions are optional settings for the *Insert methods
+type ImportOptions struct {
+	Columns []string // The table columns (in CSV order) to import into. Defaults to all
+	Skip    int      // Number of leading (e.g. header) rows to skip
+	// Where to log rejected rows, e.g. "my_schema.my_errors" or a CSV file clause.
+	// This is passed through as raw SQL.
+	ErrorsInto string
+	// Number of invalid rows to tolerate before aborting the import.
+	// Negative means unlimited. Defaults to aborting on the first invalid row.
+	RejectLimit int
+	// Optional FORMATs for parsing the CSV's columns (in CSV order) where they
+	// don't match the session's NLS settings, e.g. "DD.MM.YYYY" for dates.
+	// Use "" for columns parsed as normal, there must be one per CSV column
+	// as only the listed columns are read. CSVFormat returns the format for
+	// values written with FormatTimestamp.
+	ColumnFormats []string
+	// If set this is called with the total bytes uploaded so far
+	// after each chunk is sent and once more when the upload completes.
+	Progress func(bytesWritten int64)
+}
+
+// ExportOptions are optional settings for the export methods
+type ExportOptions struct {
+	Columns []string // The table columns (in CSV order) to export. Defaults to all. *Select methods only
+	// Prepend a header row of the column names. *Select methods only,
+	// for the *Query methods add WITH COLUMN NAMES to the EXPORT.
+	WithColumnNames bool
+	// Optionally only export the rows matching this predicate, e.g. "id > 10".
+	// This is passed through as raw SQL. *Select methods only
+	Where string
+	// Optionally sort the exported rows, e.g. "id DESC".
+	// This is passed through as raw SQL. *Select methods only
+	OrderBy string
+	// Optionally only export this many rows. *Select methods only
+	Limit int
+
+	// By default the slices sent down Rows.Data are split at arbitrary
+	// byte boundaries so a CSV row may span multiple slices. If RowAligned
+	// is set then partial rows are buffered until the end of the row is
+	// received so that every slice contains only complete CSV rows.
+	// This assumes the default CSV row separator (\n) and quoting (").
+	// Stream methods only.
+	RowAligned bool
 }
 
-func (c *Conn) StreamSelect(schema, table string) *Rows {
-	sql := c.getTableExportSQL(schema, table)
-	return c.StreamQuery(sql)
+func (c *Conn) StreamSelect(schema, table string, opts ...ExportOptions) *Rows {
+	sql := c.getTableExportSQL(schema, table, opts...)
+	return c.StreamQuery(sql, opts...)
 }
 
+// The size of the buffers the EXPORTed data is read into
+const proxyBufSize = 65524
+
 var bufPool = sync.Pool{
 	New: func() interface{} {
-		return make([]byte, 65524, 65524)
+		return make([]byte, proxyBufSize)
 	},
 }
 
-func (c *Conn) StreamQuery(exportSQL string) *Rows {
+func (c *Conn) StreamQuery(exportSQL string, opts ...ExportOptions) *Rows {
+	return c.StreamQueryContext(context.Background(), exportSQL, opts...)
+}
+
+// The same as StreamQuery but cancelling ctx shuts down the proxy which
+// aborts the EXPORT. Rows.Data is then closed and Rows.Error is ctx.Err().
+func (c *Conn) StreamQueryContext(ctx context.Context, exportSQL string, opts ...ExportOptions) *Rows {
 	r := &Rows{
-		Data: make(chan []byte, 1),
+		Data: make(chan []byte, chanSize(c.Conf.StreamChanSize, defaultStreamChanSize)),
 		Pool: &bufPool,
 		conn: c,
+		ctx:  ctx,
 		stop: make(chan bool, 1),
 		wg:   sync.WaitGroup{},
 	}
+	if len(opts) > 0 {
+		r.opts = opts[0]
+	}
 
 	// Asynchronously read in the data from Exasol
 	r.wg.Add(1)
+	c.inFlight.Add(1)
 	go func() {
 		defer func() {
 			close(r.Data)
 			r.wg.Done()
+			c.inFlight.Done()
 		}()
 
-		// Retry once because for some reason we occasionally get "connection refused"
+		// Retry because for some reason we occasionally get "connection refused"
 		// errors when Exasol tries to connect to the internal proxy that it set up.
-		for i := 0; i <= 2; i++ {
-			r.Error = r.streamQuery(exportSQL)
-			if retryableError(r.Error) {
-				c.error("Retrying...")
-				r.Error = nil
-				continue
-			}
-			return
-		}
+		r.Error = c.withRetry(func() (bool, error) {
+			err := r.streamQuery(exportSQL)
+			// Data already sent down the chan can't be taken back
+			return r.BytesRead == 0 && ctx.Err() == nil, err
+		})
 	}()
 
 	return r
 }
 
+// Each chunk received from Data is yours until you pass it to Recycle.
 type Rows struct {
-	BytesRead int64
-	Data      chan []byte
-	Pool      *sync.Pool // Use this to return the []bytes
-	Error     error
+	BytesRead    int64
+	Data         chan []byte
+	Pool         *sync.Pool // Deprecated: Use Recycle which ignores chunks not from the pool
+	Error        error
+	RowsExported int64 // Set once Data is closed (unless there's an Error)
 
 	conn  *Conn
+	ctx   context.Context
+	opts  ExportOptions
 	proxy *Proxy
 	stop  chan bool
 	wg    sync.WaitGroup
 }
 
+// Hands a chunk received from Data back to be reused for subsequent chunks
+// so that streaming doesn't allocate a new buffer for each one. The chunk
+// (and any slice of it) mustn't be used afterwards since it's overwritten
+// by later chunks, so copy out anything you hold on to. It's fine not to
+// Recycle chunks, they're then garbage collected as usual.
+func (r *Rows) Recycle(chunk []byte) {
+	recycleBuf(&bufPool, chunk)
+}
+
 func (r *Rows) Close() {
 	origCfg := r.conn.Conf.SuppressError
 	if r.proxy.IsRunning() {
@@ -195,33 +383,200 @@ func (r *Rows) Close() {
 	r.conn.Conf.SuppressError = origCfg
 }
 
+func (c *Conn) ReaderInsert(schema, table string, r io.Reader, opts ...ImportOptions) (int64, error) {
+	sql := c.getTableImportSQL(schema, table, opts...)
+	return c.ReaderExecute(sql, r, opts...)
+}
+
+// ReaderExecute uploads the CSV data read from r until EOF returning the number of rows imported.
+// If reading fails the import is aborted rather than committing the partial data.
+// Only ImportOptions.Progress applies to ReaderExecute
+func (c *Conn) ReaderExecute(sql string, r io.Reader, opts ...ImportOptions) (int64, error) {
+	if r == nil {
+		return 0, c.errorf("%w: You must pass in an io.Reader to ReaderExecute", ErrNilReader)
+	}
+	data := make(chan []byte, 1)
+	done := make(chan bool)
+	defer close(done)
+	var readErr error
+	go func() {
+		defer close(data)
+		for {
+			chunk := make([]byte, readerChunkSize)
+			n, err := r.Read(chunk)
+			if n > 0 {
+				select {
+				case data <- chunk[:n]:
+				case <-done:
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+	// readErr is only checked once data is closed
+	return c.streamExecute(context.Background(), sql, data, importOpts(opts), func() error { return readErr })
+}
+
+func (c *Conn) WriterSelect(schema, table string, w io.Writer, opts ...ExportOptions) (int64, error) {
+	sql := c.getTableExportSQL(schema, table, opts...)
+	return c.WriterQuery(sql, w)
+}
+
+// WriterQuery copies the exported CSV data to w returning the number of
+// bytes written. If w fails the export is stopped.
+func (c *Conn) WriterQuery(exportSQL string, w io.Writer) (int64, error) {
+	if w == nil {
+		return 0, c.errorf("%w: You must pass in an io.Writer to WriterQuery", ErrNilWriter)
+	}
+	return copyRows(c.StreamQuery(exportSQL), w)
+}
+
+// ImportFile imports the CSV file at path into the table returning the
+// number of rows imported. Files ending in .gz are gunzipped as they're read.
+func (c *Conn) ImportFile(schema, table, path string, opts ...ImportOptions) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, c.errorf("Unable to ImportFile: %s", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, c.errorf("Unable to ImportFile: %s: %s", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return c.ReaderInsert(schema, table, r, opts...)
+}
+
+// ExportFile exports the table to a CSV file at path (which is truncated
+// if it exists) returning the number of rows exported. Files ending in .gz
+// are gzipped. If the export fails the partially written file is removed.
+func (c *Conn) ExportFile(schema, table, path string, opts ...ExportOptions) (rowsExported int64, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, c.errorf("Unable to ExportFile: %s", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = c.errorf("Unable to ExportFile: %s", cerr)
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	sql := c.getTableExportSQL(schema, table, opts...)
+	rows := c.StreamQuery(sql, opts...)
+	if _, err = copyRows(rows, w); err != nil {
+		return 0, err
+	}
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return 0, c.errorf("Unable to ExportFile: %s", err)
+		}
+	}
+	return rows.RowsExported, nil
+}
+
 /*--- Private Routines ---*/
 
+// How much of an io.Reader to upload in each chunk
+const readerChunkSize = 64 * 1024
+
+// Copies the exported data to w returning the number of bytes written
+func copyRows(rows *Rows, w io.Writer) (int64, error) {
+	var written int64
+	for b := range rows.Data {
+		n, err := w.Write(b)
+		written += int64(n)
+		rows.Recycle(b)
+		if err != nil {
+			rows.Close()
+			return written, fmt.Errorf("Unable to write exported data: %w", err)
+		}
+	}
+	if rows.Error != nil {
+		return written, fmt.Errorf("Unable to export data: %w", rows.Error)
+	}
+	return written, nil
+}
+
+// srcErr (which may be nil) reports any error encountered producing
+// the data. It's called once data is closed.
+func (c *Conn) streamExecute(
+	ctx context.Context, origSQL string, data <-chan []byte, opts ImportOptions, srcErr func() error,
+) (int64, error) {
+	c.inFlight.Add(1)
+	defer c.inFlight.Done()
+
+	var rowsImported int64
+	err := c.withRetry(func() (bool, error) {
+		var bytesWritten int64
+		var err error
+		rowsImported, bytesWritten, err = c.streamExecuteNoRetry(ctx, origSQL, data, opts, srcErr)
+		// If there was an error while writing the data
+		// we've lost the data we've written so we can't retry
+		return bytesWritten == 0 && ctx.Err() == nil, err
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		// Deliberately cancelling isn't worth logging
+		c.error(err.Error())
+	}
+	return rowsImported, err
+}
+
 func (r *Rows) streamQuery(exportSQL string) error {
 	proxy, receiver, err := r.conn.initProxy(exportSQL)
 	if err != nil {
 		return err
 	}
 	r.proxy = proxy
+	r.proxy.RowAligned = r.opts.RowAligned
+	r.proxy.done = r.ctx.Done()
 	defer r.proxy.Shutdown()
 
+	start := time.Now()
+	var rowsExported int64
 	dataErr := make(chan error, 1)
 	respErr := make(chan error, 1)
 	go func() {
 		// This is a blocking reader of the CSV data
 		r.BytesRead, err = r.proxy.Read(r.Data, r.stop)
+		r.conn.stats.add("BytesExported", int(r.BytesRead))
+		if err != nil {
+			r.conn.onError(err)
+		}
 		dataErr <- err
 	}()
 	go func() {
 		// This returns the result of the EXPORT query
-		err := receiver(&response{})
-		respErr <- err
+		res := &execRes{}
+		err := receiver(res)
+		if err == nil {
+			rowsExported = newResult(exportSQL, res.ResponseData).RowsAffected
+			r.conn.warnings(exportSQL, &res.response)
+		}
+		respErr <- remoteProxyError(err)
 	}()
 
-	timeout := make(<-chan time.Time)
-	if r.conn.Conf.QueryTimeout.Seconds() > 0 {
-		timeout = time.After(r.conn.Conf.QueryTimeout)
-	}
+	// Only times out if no data's moved for the timeout
+	idle, stopIdle := r.proxy.idleTimeout(r.conn.readTimeout())
+	defer stopIdle()
 
 	select {
 	case err = <-dataErr:
@@ -232,46 +587,67 @@ func (r *Rows) streamQuery(exportSQL string) error {
 		if err == nil {
 			err = <-dataErr
 		}
-	case <-timeout:
-		err = errors.New("Timed out doing BulkQuery")
+	case <-idle:
+		err = fmt.Errorf("Timed out doing BulkQuery: no data received for %s", r.conn.readTimeout())
+	case <-r.ctx.Done():
+		err = r.ctx.Err()
+	}
+	if err != nil && r.ctx.Err() != nil {
+		err = r.ctx.Err() // Rather than the errors from shutting down the proxy
 	}
 
 	// If we purposefully prematurely closed the connection
 	// we don't want to raise any errors.
 	if err != nil {
-		r.conn.errorf("Unable to bulk export data: %s %s", exportSQL, err)
+		r.conn.errorf("Unable to bulk export data: %s %s", r.conn.logSQL(exportSQL), err)
+	} else {
+		r.RowsExported = rowsExported
+		r.conn.onQuery(exportSQL, time.Since(start), rowsExported)
 	}
 
 	return err
 }
 
-func (c *Conn) streamExecuteNoRetry(origSQL string, data <-chan []byte) (
-	bytesWritten int64, err error,
-) {
+func (c *Conn) streamExecuteNoRetry(
+	ctx context.Context, origSQL string, data <-chan []byte, opts ImportOptions, srcErr func() error,
+) (rowsImported, bytesWritten int64, err error) {
 	proxy, receiver, err := c.initProxy(origSQL)
 	if err != nil {
-		return 0, fmt.Errorf("Unable to import or export data: %s\n%s", origSQL, err)
+		return 0, 0, fmt.Errorf("Unable to import or export data: %s\n%w", c.logSQL(origSQL), err)
 	}
+	proxy.srcErr = srcErr
+	proxy.progress = opts.Progress
+	proxy.done = ctx.Done()
 	defer proxy.Shutdown()
 
+	start := time.Now()
+	var rows int64
 	dataErr := make(chan error, 1)
 	respErr := make(chan error, 1)
 	go func() {
 		// This is a blocking writer of the CSV data
 		var e error
 		bytesWritten, e = proxy.Write(data)
+		c.stats.add("BytesImported", int(bytesWritten))
+		if e != nil {
+			c.onError(e)
+		}
 		dataErr <- e
 	}()
 	go func() {
 		// This returns the result of the IMPORT query
-		e := receiver(&response{})
-		respErr <- e
+		res := &execRes{}
+		e := receiver(res)
+		if e == nil {
+			rows = newResult(origSQL, res.ResponseData).RowsAffected
+			c.warnings(origSQL, &res.response)
+		}
+		respErr <- remoteProxyError(e)
 	}()
 
-	timeout := make(<-chan time.Time)
-	if c.Conf.QueryTimeout.Seconds() > 0 {
-		timeout = time.After(c.Conf.QueryTimeout)
-	}
+	// Only times out if no data's moved for the timeout
+	idle, stopIdle := proxy.idleTimeout(c.readTimeout())
+	defer stopIdle()
 
 	select {
 	case err = <-dataErr:
@@ -282,35 +658,56 @@ func (c *Conn) streamExecuteNoRetry(origSQL string, data <-chan []byte) (
 		if err == nil {
 			err = <-dataErr
 		}
-	case <-timeout:
-		err = fmt.Errorf("Timed out doing StreamExecute")
+	case <-idle:
+		err = fmt.Errorf("Timed out doing StreamExecute: no data sent for %s", c.readTimeout())
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err() // Rather than the errors from shutting down the proxy
 	}
 
 	if err != nil {
-		err = fmt.Errorf("Unable to import or export data: %s\n%s", origSQL, err)
+		err = fmt.Errorf("Unable to import or export data: %s\n%w", c.logSQL(origSQL), err)
+	} else {
+		rowsImported = rows
+		c.onQuery(origSQL, time.Since(start), rowsImported)
 	}
 
-	return bytesWritten, err
+	return rowsImported, bytesWritten, err
 }
 
 func (c *Conn) initProxy(sql string) (*Proxy, func(interface{}) error, error) {
-	proxy, err := NewProxy(c.Conf.Host, c.Conf.Port, &bufPool, c.log)
+	if c.isClosed() {
+		// Rather than setting up a proxy only for asyncSend to fail
+		return nil, nil, ErrConnClosed
+	}
+	host, port := c.host, c.Conf.Port
+	if c.Conf.ProxyHost != "" {
+		host = c.Conf.ProxyHost
+	}
+	if c.Conf.ProxyPort != 0 {
+		port = c.Conf.ProxyPort
+	}
+	proxy, err := newProxy(c.Conf.ProxyDialer, c.Conf.ProxyNetwork, host, port, &bufPool, c.log)
 	if err != nil {
+		c.onError(err)
 		c.error(err.Error())
 		return nil, nil, err
 	}
 
-	proxyURL := fmt.Sprintf("http://%s:%d", proxy.Host, proxy.Port)
-	sql = fmt.Sprintf(sql, proxyURL)
+	// Bracketed if Exasol's listening on an IPv6 address
+	proxyURL := "http://" + net.JoinHostPort(proxy.Host, strconv.Itoa(int(proxy.Port)))
+	sql = c.tagSQL(fmt.Sprintf(sql, proxyURL), "")
 
 	req := &execReq{
 		Command: "execute",
 		SqlText: sql,
 	}
-	c.log.Debug("Stream sql: ", sql)
+	c.logFields(LogDebug, "Stream", "sql", c.logSQL(sql))
 	receiver, err := c.asyncSend(req)
 	if err != nil {
-		c.errorf("Unable to stream sql: %s %s", sql, err)
+		c.errorf("Unable to stream sql: %s %s", c.logSQL(sql), err)
 		proxy.Shutdown()
 		return nil, nil, err
 	}
@@ -318,25 +715,157 @@ func (c *Conn) initProxy(sql string) (*Proxy, func(interface{}) error, error) {
 	return proxy, receiver, nil
 }
 
+// How Exasol words failing to connect to its end of the proxy. This is
+// only matched in remoteProxyError (and retryableError for errors that
+// haven't been through it) so callers can check for a ProxyDialError.
+var isRemoteDialError = regexp.MustCompile(`(?i)failed after 0 bytes.+connection (refused|reset)`)
+var isConnResetError = regexp.MustCompile(`(?i)connection reset by peer`)
+
+// Returns the IMPORT/EXPORT's error as a ProxyDialError if Exasol
+// reported it couldn't connect to its end of the proxy
+func remoteProxyError(err error) error {
+	if err != nil && errors.Is(err, errServer) && isRemoteDialError.MatchString(err.Error()) {
+		return &ProxyDialError{Remote: true, Err: err}
+	}
+	return err
+}
+
 func retryableError(err error) bool {
-	retryableError := regexp.MustCompile(`failed after 0 bytes.+Connection refused`)
-	if err != nil &&
-		retryableError.MatchString(err.Error()) {
+	if err == nil {
+		return false
+	}
+	var dialErr *ProxyDialError
+	if errors.As(err, &dialErr) {
+		return dialErr.Remote || errors.Is(err, syscall.ECONNRESET)
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
 		return true
 	}
-	return false
+	msg := err.Error()
+	return isRemoteDialError.MatchString(msg) || isConnResetError.MatchString(msg)
 }
 
-func (c *Conn) getTableImportSQL(schema, table string) string {
-	return fmt.Sprintf(
-		"IMPORT INTO %s.%s FROM CSV AT '%%s' FILE 'data.csv'",
-		c.QuoteIdent(schema), c.QuoteIdent(table),
-	)
+// Calls try until it succeeds, fails with an error that isn't
+// retryable or we run out of attempts. try reports whether
+// it's safe to be retried should it fail.
+func (c *Conn) withRetry(try func() (bool, error)) error {
+	policy := c.Conf.RetryPolicy
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = retryableError
+	}
+
+	for attempt := 1; ; attempt++ {
+		canRetry, err := try()
+		if err == nil || !policy.Retryable(err) {
+			return err
+		}
+		if !canRetry {
+			c.error("Data already sent can't retry...")
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			return err
+		}
+		c.error("Retrying...")
+		time.Sleep(policy.Backoff)
+	}
+}
+
+func (c *Conn) getTableImportSQL(schema, table string, opts ...ImportOptions) string {
+	o := importOpts(opts)
+	target := c.QuoteIdent(schema) + "." + c.QuoteIdent(table) + c.columnList(o.Columns)
+	sql := fmt.Sprintf("IMPORT INTO %s FROM CSV AT '%%s' FILE 'data.csv'", escapeFormat(target))
+	if len(o.ColumnFormats) > 0 {
+		sql += " " + escapeFormat(csvColumns(o.ColumnFormats))
+	}
+	if o.Skip > 0 {
+		sql += fmt.Sprintf(" SKIP = %d", o.Skip)
+	}
+	if o.ErrorsInto != "" {
+		sql += " ERRORS INTO " + escapeFormat(o.ErrorsInto)
+	}
+	if o.RejectLimit > 0 {
+		sql += fmt.Sprintf(" REJECT LIMIT %d", o.RejectLimit)
+	} else if o.RejectLimit < 0 {
+		sql += " REJECT LIMIT UNLIMITED"
+	}
+	return sql
+}
+
+func (c *Conn) getTableExportSQL(schema, table string, opts ...ExportOptions) string {
+	var o ExportOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	var sql string
+	if o.Where == "" && o.OrderBy == "" && o.Limit <= 0 {
+		source := c.QuoteIdent(schema) + "." + c.QuoteIdent(table) + c.columnList(o.Columns)
+		sql = fmt.Sprintf("EXPORT %s INTO CSV AT '%%s' FILE 'data.csv'", escapeFormat(source))
+	} else {
+		// Which needs a subquery
+		cols := "*"
+		if len(o.Columns) > 0 {
+			cols = c.quotedColumns(o.Columns)
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s.%s", cols, c.QuoteIdent(schema), c.QuoteIdent(table))
+		if o.Where != "" {
+			query += " WHERE " + o.Where
+		}
+		if o.OrderBy != "" {
+			query += " ORDER BY " + o.OrderBy
+		}
+		if o.Limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", o.Limit)
+		}
+		sql = fmt.Sprintf("EXPORT (%s) INTO CSV AT '%%s' FILE 'data.csv'", escapeFormat(query))
+	}
+	if o.WithColumnNames {
+		sql += " WITH COLUMN NAMES"
+	}
+	return sql
+}
+
+// The bulk SQL is a format string for the proxy's URL (see initProxy)
+// so any % in the caller's identifiers and options has to be escaped
+func escapeFormat(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
 }
 
-func (c *Conn) getTableExportSQL(schema, table string) string {
-	return fmt.Sprintf(
-		"EXPORT %s.%s INTO CSV AT '%%s' FILE 'data.csv'",
-		c.QuoteIdent(schema), c.QuoteIdent(table),
-	)
+func importOpts(opts []ImportOptions) ImportOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return ImportOptions{}
+}
+
+// Returns the IMPORT's CSV column list, e.g. (1, 2 FORMAT='DD.MM.YYYY')
+func csvColumns(formats []string) string {
+	cols := make([]string, len(formats))
+	for i, format := range formats {
+		cols[i] = strconv.Itoa(i + 1)
+		if format != "" {
+			cols[i] += " FORMAT='" + strings.ReplaceAll(format, "'", "''") + "'"
+		}
+	}
+	return "(" + strings.Join(cols, ", ") + ")"
+}
+
+// Returns the IMPORT/EXPORT column list, e.g. " (id, [SELECT])"
+func (c *Conn) columnList(cols []string) string {
+	if len(cols) == 0 {
+		return ""
+	}
+	return " (" + c.quotedColumns(cols) + ")"
+}
+
+// Returns the quoted column names joined for a SELECT, e.g. "id, [SELECT]"
+func (c *Conn) quotedColumns(cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = c.QuoteIdent(col)
+	}
+	return strings.Join(quoted, ", ")
 }
@@ -2,7 +2,17 @@ package exasol
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func (s *testSuite) TestBulkInsert() {
@@ -12,14 +22,15 @@ func (s *testSuite) TestBulkInsert() {
 	data := bytes.NewBufferString("1,a\n2,b\n3,c")
 	s.exaConn.Conf.SuppressError = true
 	// Should fail
-	err := exa.BulkInsert(s.qschema, "ASDF", data)
+	_, err := exa.BulkInsert(s.qschema, "ASDF", data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "ASDF")
 	}
 
 	// Should succeed
-	err = exa.BulkInsert(s.qschema, "FOO", data)
+	n, err := exa.BulkInsert(s.qschema, "FOO", data)
 	s.Nil(err)
+	s.Equal(int64(3), n, "Rows imported")
 
 	got, err := exa.FetchSlice("SELECT * FROM foo ORDER BY id")
 	if s.NoError(err) {
@@ -39,14 +50,15 @@ func (s *testSuite) TestBulkExecute() {
 	data := bytes.NewBufferString("1,\"a\"\n2,\"b\"\n3,\"c\"")
 	s.exaConn.Conf.SuppressError = true
 	// Should fail
-	err := exa.BulkExecute("ASDF", data)
+	_, err := exa.BulkExecute("ASDF", data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "ASDF")
 	}
 
 	// Should succeed
-	err = exa.BulkExecute("IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'", data)
+	n, err := exa.BulkExecute("IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'", data)
 	s.Nil(err)
+	s.Equal(int64(3), n, "Rows imported")
 
 	got, err := exa.FetchSlice("SELECT * FROM foo ORDER BY id")
 	if s.NoError(err) {
@@ -59,6 +71,140 @@ func (s *testSuite) TestBulkExecute() {
 	}
 }
 
+func (s *testSuite) TestImportOptions() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1), other INT DEFAULT 9 )")
+
+	sql := exa.getTableImportSQL("s", "t", ImportOptions{
+		Columns: []string{"id", "val"}, Skip: 1, ErrorsInto: "s.errs", RejectLimit: -1,
+	})
+	s.Equal(`IMPORT INTO s.t (id, val) FROM CSV AT '%s' FILE 'data.csv'`+
+		` SKIP = 1 ERRORS INTO s.errs REJECT LIMIT UNLIMITED`, sql)
+
+	// Should fail on the invalid row
+	exa.Conf.SuppressError = true
+	csv := "id,val\n1,a\nx,b\n3,c\n"
+	opts := ImportOptions{Columns: []string{"val", "id"}, Skip: 1}
+	_, err := exa.BulkInsert(s.qschema, "FOO", bytes.NewBufferString("a,1\nb,x\n"), opts)
+	s.Error(err)
+
+	// Should succeed, skipping the header and rejecting the invalid row
+	opts = ImportOptions{
+		Columns:     []string{"id", "val"},
+		Skip:        1,
+		ErrorsInto:  s.qschema + ".foo_errs",
+		RejectLimit: 1,
+	}
+	_, err = exa.BulkInsert(s.qschema, "FOO", bytes.NewBufferString(csv), opts)
+	s.Nil(err)
+	got, err := exa.FetchSlice("SELECT * FROM foo ORDER BY id")
+	if s.NoError(err) {
+		expect := [][]interface{}{
+			{float64(1), "a", float64(9)},
+			{float64(3), "c", float64(9)},
+		}
+		s.Equal(expect, got)
+	}
+	got = s.fetch("SELECT COUNT(*) FROM foo_errs")
+	s.Equal(float64(1), got[0][0], "Rejected row was logged")
+}
+
+func (s *testSuite) TestImportColumnFormats() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, d DATE, ts TIMESTAMP )")
+
+	ts := DataType{Type: "TIMESTAMP", Precision: 3}
+	opts := ImportOptions{ColumnFormats: []string{"", "DD.MM.YYYY", CSVFormat(ts)}}
+	s.Equal(`IMPORT INTO s.t FROM CSV AT '%s' FILE 'data.csv'`+
+		` (1, 2 FORMAT='DD.MM.YYYY', 3 FORMAT='YYYY-MM-DD HH24:MI:SS.FF3')`,
+		exa.getTableImportSQL("s", "t", opts))
+
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	csv := "1,31.12.2019," + FormatTimestamp(ts, when) + "\n"
+	_, err := exa.BulkInsert(s.qschema, "FOO", bytes.NewBufferString(csv), opts)
+	s.Require().NoError(err)
+	got := s.fetch("SELECT TO_CHAR(d, 'YYYY-MM-DD'), ts FROM foo")
+	s.Equal([][]interface{}{{"2019-12-31", "2020-01-02 03:04:05.000000"}}, got)
+}
+
+func TestTableImportSQLEscaping(t *testing.T) {
+	c := &Conn{} // The identifiers are already quoted so nothing's looked up
+	sql := c.getTableImportSQL("[s%]", `"t%"`, ImportOptions{
+		Columns:       []string{`"a%"`},
+		ColumnFormats: []string{"DD%MM"},
+		ErrorsInto:    "LOCAL CSV FILE 'errs%d.csv'",
+	})
+	assert.Equal(t, `IMPORT INTO [s%]."t%" ("a%") FROM CSV AT 'http://proxy' FILE 'data.csv'`+
+		` (1 FORMAT='DD%MM') ERRORS INTO LOCAL CSV FILE 'errs%d.csv'`,
+		fmt.Sprintf(sql, "http://proxy"))
+}
+
+func TestTableExportSQL(t *testing.T) {
+	c := &Conn{} // The identifiers are already quoted so nothing's looked up
+	cols := []string{`"(a"`, `"b%)"`}
+	sql := c.getTableExportSQL("[s%]", `"t%"`, ExportOptions{Columns: cols})
+	assert.Equal(t, `EXPORT [s%]."t%" ("(a", "b%)") INTO CSV AT 'http://proxy' FILE 'data.csv'`,
+		fmt.Sprintf(sql, "http://proxy"))
+
+	sql = c.getTableExportSQL("[s%]", `"t%"`, ExportOptions{Columns: cols, Limit: 1})
+	assert.Equal(t, `EXPORT (SELECT "(a", "b%)" FROM [s%]."t%" LIMIT 1) INTO CSV AT 'http://proxy' FILE 'data.csv'`,
+		fmt.Sprintf(sql, "http://proxy"), "Parentheses in the identifiers are kept")
+}
+
+func (s *testSuite) TestExportOptions() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	exa.Execute("INSERT INTO foo VALUES (1,'a'),(2,'b')")
+
+	data := &bytes.Buffer{}
+	_, err := exa.BulkSelect(s.qschema, "FOO", data, ExportOptions{Columns: []string{"val", "id"}})
+	if s.NoError(err) {
+		s.ElementsMatch([]string{"a,1", "b,2"}, strings.Fields(data.String()))
+	}
+
+	data.Reset()
+	_, err = exa.BulkSelect(s.qschema, "FOO", data, ExportOptions{Columns: []string{"id"}, WithColumnNames: true})
+	if s.NoError(err) {
+		lines := strings.Fields(data.String())
+		if s.Len(lines, 3) {
+			s.Equal("ID", lines[0], "Header row")
+			s.ElementsMatch([]string{"1", "2"}, lines[1:])
+		}
+	}
+
+	var csv string
+	rows := exa.StreamSelect(s.qschema, "FOO", ExportOptions{WithColumnNames: true})
+	for d := range rows.Data {
+		csv += string(d)
+	}
+	s.NoError(rows.Error)
+	s.True(strings.HasPrefix(csv, "ID,VAL\n"), "Header row")
+}
+
+func (s *testSuite) TestBulkNilArgs() {
+	_, err := s.exaConn.BulkInsert(s.qschema, "FOO", nil)
+	s.ErrorIs(err, ErrNilBuffer)
+	_, err = s.exaConn.BulkExecute("IMPORT INTO foo FROM CSV AT '%s' FILE 'data.csv'", nil)
+	s.ErrorIs(err, ErrNilBuffer)
+	_, err = s.exaConn.BulkSelect(s.qschema, "FOO", nil)
+	s.ErrorIs(err, ErrNilBuffer)
+	_, err = s.exaConn.BulkQuery("EXPORT foo INTO CSV AT '%s' FILE 'data.csv'", nil)
+	s.ErrorIs(err, ErrNilBuffer)
+	_, err = s.exaConn.StreamInsert(s.qschema, "FOO", nil)
+	s.ErrorIs(err, ErrNilChan)
+	_, err = s.exaConn.StreamExecute("IMPORT INTO foo FROM CSV AT '%s' FILE 'data.csv'", nil)
+	s.ErrorIs(err, ErrNilChan)
+}
+
+func TestReaderWriterNilArgs(t *testing.T) {
+	c := newFakeConn(t, ConnConf{Logger: customTestLogger("fatal")}, &fakeWSHandler{})
+	defer c.Disconnect()
+	_, err := c.ReaderExecute("IMPORT INTO foo FROM CSV AT '%s' FILE 'data.csv'", nil)
+	assert.ErrorIs(t, err, ErrNilReader)
+	_, err = c.WriterQuery("EXPORT foo INTO CSV AT '%s' FILE 'data.csv'", nil)
+	assert.ErrorIs(t, err, ErrNilWriter)
+}
+
 func (s *testSuite) TestBulkSelect() {
 	exa := s.exaConn
 	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
@@ -68,15 +214,16 @@ func (s *testSuite) TestBulkSelect() {
 	s.exaConn.Conf.SuppressError = true
 
 	// Should fail
-	err := exa.BulkSelect(s.qschema, "ASDF", data)
+	_, err := exa.BulkSelect(s.qschema, "ASDF", data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "not found")
 	}
 
 	// Should succeed
-	err = exa.BulkSelect(s.qschema, "FOO", data)
+	n, err := exa.BulkSelect(s.qschema, "FOO", data)
 	if s.NoError(err) {
 		s.Equal("1,a\n2,b\n3,c\n", data.String())
+		s.Equal(int64(data.Len()), n, "Bytes written")
 	}
 }
 
@@ -85,16 +232,19 @@ func (s *testSuite) TestBulkQuery() {
 	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
 	exa.Execute("INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')")
 
-	data := &bytes.Buffer{}
+	data := bytes.NewBufferString("keep\n")
 	s.exaConn.Conf.SuppressError = true
 	// Should fail
-	err := exa.BulkQuery("ASDF", data)
+	n, err := exa.BulkQuery("ASDF", data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "syntax error")
 	}
+	s.Equal(int64(0), n)
+	s.Equal("keep\n", data.String(), "Existing data is left as it was")
 
 	// Should succeed
-	err = exa.BulkQuery(`
+	data.Reset()
+	n, err = exa.BulkQuery(`
 		EXPORT (
 			SELECT id, val
 			FROM foo
@@ -104,9 +254,140 @@ func (s *testSuite) TestBulkQuery() {
 	`, data)
 	if s.NoError(err) {
 		s.Equal("1,a\n2,b\n3,c\n", data.String())
+		s.Equal(int64(12), n, "Bytes written")
+	}
+}
+
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func (s *testSuite) TestReaderInsert() {
+	s.execute(`CREATE TABLE foo ( id INT, val CHAR(1) )`)
+
+	// Should fail
+	s.exaConn.Conf.SuppressError = true
+	r := &failingReader{strings.NewReader("1,a\n2,b\n"), errors.New("Disk on fire")}
+	_, err := s.exaConn.ReaderInsert(s.qschema, "FOO", r)
+	if s.Error(err) {
+		s.Contains(err.Error(), "Disk on fire")
+	}
+	got := s.fetch(`SELECT COUNT(*) FROM foo`)
+	s.Equal(float64(0), got[0][0], "Partial data wasn't imported")
+
+	// Should succeed
+	csv := strings.Repeat("1,a\n2,b\n3,c\n", 1e4)
+	_, err = s.exaConn.ReaderInsert(s.qschema, "FOO", strings.NewReader(csv))
+	s.Nil(err)
+	got = s.fetch(`SELECT COUNT(*), SUM(id) FROM foo`)
+	s.Equal([][]interface{}{{float64(3e4), float64(6e4)}}, got, "Correctly reader-inserted")
+}
+
+type failingWriter struct{ written int }
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.written > 0 {
+		return 0, errors.New("Disk full")
+	}
+	f.written += len(p)
+	return len(p), nil
+}
+
+func (s *testSuite) TestWriterSelect() {
+	s.execute(`CREATE TABLE foo ( id INT, val CHAR(1) )`)
+	s.execute(`INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')`)
+
+	// Should fail
+	s.exaConn.Conf.SuppressError = true
+	_, err := s.exaConn.WriterSelect(s.qschema, "ASDF", &bytes.Buffer{})
+	if s.Error(err) {
+		s.Contains(err.Error(), "not found")
+	}
+
+	// Should succeed
+	data := &bytes.Buffer{}
+	n, err := s.exaConn.WriterSelect(s.qschema, "FOO", data)
+	if s.NoError(err) {
+		s.Equal("1,a\n2,b\n3,c\n", data.String())
+		s.Equal(int64(12), n)
+	}
+
+	// The writer failing
+	s.execute(`INSERT INTO foo SELECT level, 'x' FROM dual CONNECT BY level <= 1e5`)
+	_, err = s.exaConn.WriterSelect(s.qschema, "FOO", &failingWriter{})
+	if s.Error(err) {
+		s.Contains(err.Error(), "Disk full")
+	}
+}
+
+func (s *testSuite) TestWriterQuery() {
+	s.execute(`CREATE TABLE foo ( id INT, val CHAR(1) )`)
+	s.execute(`INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')`)
+
+	// Should fail
+	s.exaConn.Conf.SuppressError = true
+	data := &bytes.Buffer{}
+	_, err := s.exaConn.WriterQuery("ASDF", data)
+	if s.Error(err) {
+		s.Contains(err.Error(), "syntax error")
+	}
+	_, err = s.exaConn.WriterQuery("ASDF", nil)
+	s.Error(err)
+
+	// Should succeed
+	n, err := s.exaConn.WriterQuery(fmt.Sprintf(`
+		EXPORT ( SELECT id, val FROM %s.foo WHERE id > 1 ORDER BY id DESC )
+		INTO CSV AT '%%s' FILE 'data.csv'
+	`, s.qschema), data)
+	if s.NoError(err) {
+		s.Equal("3,c\n2,b\n", data.String())
+		s.Equal(int64(8), n)
 	}
 }
 
+func (s *testSuite) TestImportExportFile() {
+	s.execute(`CREATE TABLE foo ( id INT, val CHAR(1) )`)
+	s.execute(`INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')`)
+	dir := s.T().TempDir()
+
+	for _, name := range []string{"foo.csv", "foo.csv.gz"} {
+		path := filepath.Join(dir, name)
+		n, err := s.exaConn.ExportFile(s.qschema, "FOO", path)
+		if s.NoError(err, name) {
+			s.Equal(int64(3), n, name)
+		}
+		raw, _ := os.ReadFile(path)
+		s.Equal(strings.HasSuffix(name, ".gz"), bytes.HasPrefix(raw, []byte{0x1f, 0x8b}), "Gzipped "+name)
+
+		s.execute("TRUNCATE TABLE foo")
+		n, err = s.exaConn.ImportFile(s.qschema, "FOO", path)
+		if s.NoError(err, name) {
+			s.Equal(int64(3), n, name)
+		}
+		got := s.fetch("SELECT * FROM foo ORDER BY id")
+		s.Equal([][]interface{}{{float64(1), "a"}, {float64(2), "b"}, {float64(3), "c"}}, got, name)
+	}
+
+	s.exaConn.Conf.SuppressError = true
+	_, err := s.exaConn.ImportFile(s.qschema, "FOO", filepath.Join(dir, "missing.csv"))
+	s.Error(err)
+
+	path := filepath.Join(dir, "failed.csv")
+	_, err = s.exaConn.ExportFile(s.qschema, "ASDF", path)
+	s.Error(err)
+	_, err = os.Stat(path)
+	s.True(os.IsNotExist(err), "Partial file removed")
+}
+
 func (s *testSuite) TestStreamInsert() {
 	s.execute(`CREATE TABLE foo ( id INT, val VARCHAR(10) )`)
 	numRows := 1000
@@ -118,19 +399,58 @@ func (s *testSuite) TestStreamInsert() {
 
 	// Should fail
 	s.exaConn.Conf.SuppressError = true
-	err := s.exaConn.StreamInsert(s.qschema, "asdf", data)
+	_, err := s.exaConn.StreamInsert(s.qschema, "asdf", data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "not found")
 	}
 
 	// Should succeed
-	err = s.exaConn.StreamInsert(s.qschema, "foo", data)
+	n, err := s.exaConn.StreamInsert(s.qschema, "foo", data)
 	s.Nil(err)
+	s.Equal(int64(numRows), n, "Rows imported")
 	got := s.fetch(`SELECT COUNT(*), MIN(id), MAX(id) FROM foo`)
 	expect := [][]interface{}{{float64(numRows), float64(1), float64(numRows)}}
 	s.Equal(expect, got, "Correctly stream-inserted")
 }
 
+func (s *testSuite) TestStreamInsertColumns() {
+	s.execute(`CREATE TABLE foo ( id INT, other INT DEFAULT 9, val VARCHAR(10) )`)
+	data := make(chan []byte, 2)
+	data <- []byte("a,1\n")
+	data <- []byte("b,2\n")
+	close(data)
+
+	// The CSV has a subset of the columns in a different order
+	n, err := s.exaConn.StreamInsert(s.qschema, "foo", data, ImportOptions{Columns: []string{"val", "id"}})
+	s.NoError(err)
+	s.Equal(int64(2), n)
+	got := s.fetch(`SELECT * FROM foo ORDER BY id`)
+	s.Equal([][]interface{}{{float64(1), float64(9), "a"}, {float64(2), float64(9), "b"}}, got)
+}
+
+func (s *testSuite) TestStreamInsertProgress() {
+	s.execute(`CREATE TABLE foo ( id INT )`)
+	data := make(chan []byte) // Unbuffered so the producer is throttled
+	go func() {
+		for i := 1; i <= 100; i++ {
+			data <- []byte(fmt.Sprintf("%03d\n", i))
+		}
+		close(data)
+	}()
+
+	var calls []int64
+	_, err := s.exaConn.StreamInsert(s.qschema, "foo", data, ImportOptions{
+		Progress: func(bytesWritten int64) { calls = append(calls, bytesWritten) },
+	})
+	s.Nil(err)
+	if s.Len(calls, 101, "Called per chunk plus once on completion") {
+		s.Equal(int64(4), calls[0])
+		s.Equal(int64(400), calls[100], "Final total")
+	}
+	got := s.fetch(`SELECT COUNT(*) FROM foo`)
+	s.Equal(float64(100), got[0][0])
+}
+
 func (s *testSuite) TestStreamExecute() {
 	s.execute(`CREATE TABLE foo ( id INT, val VARCHAR(10) )`)
 	numRows := 1000
@@ -142,19 +462,182 @@ func (s *testSuite) TestStreamExecute() {
 
 	// Should fail
 	s.exaConn.Conf.SuppressError = true
-	err := s.exaConn.StreamExecute(`ASDF`, data)
+	_, err := s.exaConn.StreamExecute(`ASDF`, data)
 	if s.Error(err) {
 		s.Contains(err.Error(), "syntax error")
 	}
 
 	// Should succeed
-	err = s.exaConn.StreamExecute("IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'", data)
+	n, err := s.exaConn.StreamExecute("IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'", data)
 	s.Nil(err)
+	s.Equal(int64(numRows), n, "Rows imported")
 	got := s.fetch(`SELECT COUNT(*), MIN(id), MAX(id) FROM foo`)
 	expect := [][]interface{}{{float64(numRows), float64(1), float64(numRows)}}
 	s.Equal(expect, got, "Correctly stream-inserted")
 }
 
+func (s *testSuite) TestStreamExecuteContext() {
+	s.execute(`CREATE TABLE foo ( id INT )`)
+	data := make(chan []byte)
+	go func() {
+		data <- []byte("1\n2\n")
+		// Then stall without closing the chan
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+	s.exaConn.Conf.SuppressError = true
+	n, err := s.exaConn.StreamExecuteContext(ctx, "IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'", data)
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(int64(0), n)
+
+	got := s.fetch(`SELECT COUNT(*) FROM foo`)
+	s.Equal(float64(0), got[0][0], "Nothing imported")
+}
+
+func (s *testSuite) TestStreamWriter() {
+	s.execute(`CREATE TABLE foo ( id INT )`)
+
+	w := s.exaConn.StreamInsertWriter(s.qschema, "FOO")
+	buf := make([]byte, 0, 8)
+	for i := 1; i <= 100; i++ {
+		buf = append(buf[:0], fmt.Sprintf("%d\n", i)...)
+		_, err := w.Write(buf) // Reusing buf is fine as Write copies it
+		s.Require().NoError(err)
+	}
+	s.NoError(w.Close())
+	s.Equal(int64(100), w.RowsImported)
+	got := s.fetch(`SELECT COUNT(*), SUM(id) FROM foo`)
+	s.Equal([][]interface{}{{float64(100), float64(5050)}}, got)
+
+	// Cancelled midway
+	s.execute(`TRUNCATE TABLE foo`)
+	w = s.exaConn.StreamExecuteWriter("IMPORT INTO [test].FOO FROM CSV AT '%s' FILE 'data.csv'")
+	_, err := w.Write([]byte("1\n2\n"))
+	s.NoError(err)
+	time.AfterFunc(200*time.Millisecond, w.Cancel)
+	for err == nil {
+		_, err = w.Write([]byte("3\n"))
+	}
+	s.ErrorIs(err, context.Canceled, "Writes fail once cancelled")
+	s.ErrorIs(w.Close(), context.Canceled)
+	got = s.fetch(`SELECT COUNT(*) FROM foo`)
+	s.Equal(float64(0), got[0][0], "Nothing imported")
+
+	// Failed midway
+	s.exaConn.Conf.SuppressError = true
+	w = s.exaConn.StreamInsertWriter(s.qschema, "FOO")
+	_, err = w.Write([]byte("x\n"))
+	s.NoError(err)
+	s.Error(w.Close())
+	_, err = w.Write([]byte("1\n"))
+	s.Error(err, "After the IMPORT has ended")
+}
+
+func (s *testSuite) TestStreamQueryContext() {
+	s.execute(`CREATE TABLE foo ( id INT )`)
+	s.execute(`INSERT INTO foo SELECT level FROM dual CONNECT BY level <= 1e6`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.exaConn.Conf.SuppressError = true
+	rows := s.exaConn.StreamQueryContext(ctx, "EXPORT [test].foo INTO CSV AT '%s' FILE 'data.csv'")
+	chunks := 0
+	for d := range rows.Data {
+		chunks++
+		rows.Recycle(d)
+		if chunks == 1 {
+			cancel()
+		}
+	}
+	rows.Close()
+	s.ErrorIs(rows.Error, context.Canceled)
+	s.Less(rows.BytesRead, int64(6888896), "Stopped before the end")
+
+	got := s.fetch("SELECT 123")
+	s.Equal(float64(123), got[0][0], "Still usable")
+}
+
+func (s *testSuite) TestRetryPolicy() {
+	exa := s.exaConn
+	exa.Conf.SuppressError = true
+	refused := errors.New("Connection to proxy failed after 0 bytes: Connection refused")
+
+	attempts := 0
+	err := exa.withRetry(func() (bool, error) {
+		attempts++
+		return true, refused
+	})
+	s.Equal(refused, err, "Default policy gives up")
+	s.Equal(3, attempts, "Default policy makes 3 attempts")
+
+	attempts = 0
+	err = exa.withRetry(func() (bool, error) {
+		attempts++
+		return false, refused
+	})
+	s.Equal(refused, err)
+	s.Equal(1, attempts, "Doesn't retry once data is sent")
+
+	attempts = 0
+	err = exa.withRetry(func() (bool, error) {
+		attempts++
+		return true, errors.New("Connection reset by peer")
+	})
+	s.Error(err)
+	s.Equal(3, attempts, "Retries connection resets")
+
+	exa.Conf.RetryPolicy = RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     time.Millisecond,
+		Retryable:   func(err error) bool { return err.Error() == "flaky" },
+	}
+	defer func() { exa.Conf.RetryPolicy = RetryPolicy{} }()
+	attempts = 0
+	err = exa.withRetry(func() (bool, error) {
+		attempts++
+		if attempts < 4 {
+			return true, errors.New("flaky")
+		}
+		return true, nil
+	})
+	s.NoError(err, "Eventually succeeds")
+	s.Equal(4, attempts)
+
+	attempts = 0
+	err = exa.withRetry(func() (bool, error) {
+		attempts++
+		return true, refused
+	})
+	s.Equal(refused, err)
+	s.Equal(1, attempts, "Custom predicate is used")
+}
+
+func (s *testSuite) TestProxyHost() {
+	s.execute(`CREATE TABLE foo ( id INT )`)
+	s.execute(`INSERT INTO foo VALUES (1),(2)`)
+
+	conf := s.connConf()
+	conf.ProxyHost = conf.Host
+	conf.ProxyPort = conf.Port
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	data := &bytes.Buffer{}
+	_, err = c.BulkSelect(s.qschema, "FOO", data)
+	if s.NoError(err) {
+		s.Equal("1\n2\n", data.String())
+	}
+
+	c.Conf.ProxyPort = 1
+	c.Conf.SuppressError = true
+	_, err = c.BulkSelect(s.qschema, "FOO", &bytes.Buffer{})
+	if s.Error(err) {
+		s.Contains(err.Error(), "Unable to setup proxy")
+	}
+}
+
 func (s *testSuite) TestStreamSelect() {
 	s.execute(`CREATE TABLE foo ( id INT, val CLOB )`)
 	s.execute(`INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')`)
@@ -185,6 +668,38 @@ func (s *testSuite) TestStreamSelect() {
 	s.Equal(int64(12), rows.BytesRead)
 }
 
+func (s *testSuite) TestStreamSelectWhere() {
+	s.execute(`CREATE TABLE foo ( id INT, val VARCHAR(10), "SELECT" INT )`)
+	s.execute(`INSERT INTO foo VALUES (1,'a%',7),(2,'b',8),(3,'c',9)`)
+
+	read := func(opts ExportOptions) string {
+		rows := s.exaConn.StreamSelect(s.qschema, "FOO", opts)
+		var csv string
+		for d := range rows.Data {
+			csv += string(d)
+			rows.Recycle(d)
+		}
+		s.NoError(rows.Error)
+		return csv
+	}
+	s.Equal("1,a%,7\n2,b,8\n", read(ExportOptions{Where: "id < 3 OR val LIKE '%z%'", OrderBy: "id"}))
+	s.Equal("9,3\n8,2\n", read(ExportOptions{
+		Columns: []string{"select", "id"}, // Quoted as [SELECT]
+		Where:   "id > 1",
+		OrderBy: "id DESC",
+	}), "Keyword columns are quoted")
+	s.Equal("3,c\n2,b\n1,a%\n", read(ExportOptions{
+		Columns: []string{"id", "val"},
+		OrderBy: "val DESC",
+	}), "Just ordered")
+	s.Equal("ID,VAL\n1,a%\n", read(ExportOptions{
+		Columns:         []string{"id", "val"},
+		OrderBy:         "id",
+		Limit:           1,
+		WithColumnNames: true,
+	}))
+}
+
 func (s *testSuite) TestStreamQuery() {
 	s.execute(`CREATE TABLE foo ( id INT, val INT )`)
 	// Inserts 300K rows
@@ -213,7 +728,7 @@ func (s *testSuite) TestStreamQuery() {
 
 	for d := range rows.Data {
 		csv += string(d)
-		rows.Pool.Put(d)
+		rows.Recycle(d)
 	}
 	rows.Close()
 
@@ -221,3 +736,60 @@ func (s *testSuite) TestStreamQuery() {
 	s.Equal("2\x002\x00\n1\x001\x00\n", csv[len(csv)-10:], "End ok")
 	s.Equal(int64(4277790), rows.BytesRead)
 }
+
+func (s *testSuite) TestStreamQueryRowAligned() {
+	s.execute(`CREATE TABLE foo ( id INT, val VARCHAR(100) )`)
+	// Inserts 100K rows some of which contain quoted newlines
+	s.execute(`
+		INSERT INTO foo
+		SELECT row_number() over() c, CASE WHEN MOD(local.c, 7) = 0 THEN 'a' || CHR(10) || 'b' ELSE 'ab' END
+		FROM dual CONNECT BY LEVEL <= 1e5
+	`)
+
+	rows := s.exaConn.StreamQuery(fmt.Sprintf(`
+		EXPORT ( SELECT id, val FROM %s.foo ) INTO CSV AT '%%s' FILE 'data.csv'
+	`, s.qschema), ExportOptions{RowAligned: true})
+
+	var csv string
+	for d := range rows.Data {
+		chunk := string(d)
+		s.True(strings.HasSuffix(chunk, "\n"), "Chunk ends on a row boundary")
+		s.Equal(0, strings.Count(chunk, `"`)%2, "Chunk doesn't split a quoted field")
+		csv += chunk
+		rows.Recycle(d)
+	}
+	rows.Close()
+	s.Nil(rows.Error)
+
+	numRows := strings.Count(csv, "\n") - strings.Count(csv, "a\nb")
+	s.Equal(100000, numRows, "Got all the rows")
+	s.Equal(int64(len(csv)), rows.BytesRead)
+}
+
+func (s *testSuite) TestBulkMerge() {
+	s.execute(`CREATE TABLE foo ( id INT, "desc" VARCHAR(10), n INT )`)
+	s.execute(`INSERT INTO foo VALUES (1,'a',1),(2,'b',2)`)
+	stagingTables := func() interface{} {
+		return s.fetch(`SELECT COUNT(*) FROM exa_all_tables WHERE table_name LIKE 'GO\_EXASOL\_MERGE\_%' ESCAPE '\'`)[0][0]
+	}
+
+	err := s.exaConn.BulkMerge(s.qschema, "foo", []string{"id"}, bytes.NewBufferString("2,B,20\n3,c,3\n"))
+	s.Require().NoError(err)
+	s.Equal([][]interface{}{
+		{float64(1), "a", float64(1)},
+		{float64(2), "B", float64(20)},
+		{float64(3), "c", float64(3)},
+	}, s.fetch("SELECT * FROM foo ORDER BY id"))
+	s.Equal(float64(0), stagingTables(), "The staging table was dropped")
+
+	s.exaConn.Conf.SuppressError = true
+	err = s.exaConn.BulkMerge(s.qschema, "foo", []string{"id"}, bytes.NewBufferString("4,d,4\nx,y,z\n"))
+	s.Error(err, "Invalid CSV")
+	s.Equal(float64(3), s.fetch("SELECT COUNT(*) FROM foo")[0][0], "The merge was rolled back")
+	s.Equal(float64(0), stagingTables(), "The staging table was rolled back")
+
+	err = s.exaConn.BulkMerge(s.qschema, "foo", []string{"asdf"}, bytes.NewBufferString(""))
+	s.EqualError(err, "Unable to BulkMerge: key column asdf not found in [test].foo")
+	err = s.exaConn.BulkMerge(s.qschema, "asdf", []string{"id"}, bytes.NewBufferString(""))
+	s.EqualError(err, "Unable to BulkMerge: table [test].asdf not found")
+}
@@ -26,14 +26,18 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"net/url"
 	"os/user"
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,25 +46,138 @@ import (
 const ExasolAPIVersion = 1
 const DriverVersion = "2"
 
+// Returned by the call whose query was cancelled via Abort
+var ErrQueryAborted = errors.New("Query aborted")
+
+// Returned when using a connection after it has been disconnected
+var ErrConnClosed = errors.New("Connection closed")
+
+// Returned by QueryRow and QueryScalar when the query returns no rows
+var ErrNoRows = errors.New("No rows in result set")
+
+// Returned by FetchSliceLimit when the query returns more rows than the limit
+var ErrRowLimit = errors.New("Query returned more rows than the limit")
+
+// Wrapped by the errors returned when the server's response is malformed
+var ErrProtocol = errors.New("Protocol Error")
+
+// Wraps the exceptions the server responds with
+var errServer = errors.New("Server Error")
+
+// Wraps websocket write failures (the request may or may not have been sent)
+var errSend = errors.New("WebSocket API Error sending")
+
+var errEmptyFetch = fmt.Errorf("%w: fetch returned no rows before the end of the result set", ErrProtocol)
+
 type ConnConf struct {
-	Host           string
-	Port           uint16
+	Host string
+	Port uint16
+	// Only password authentication is supported. The websocket API has no
+	// Kerberos/GSSAPI login flow (only passwords and, from protocol v3, OpenID
+	// tokens) so clusters that mandate Kerberos need Exasol's JDBC/ODBC drivers.
 	Username       string
 	Password       string
 	ClientName     string
 	ClientVersion  string
-	ConnectTimeout time.Duration
-	QueryTimeout   time.Duration
+	ConnectTimeout time.Duration // Bounds dialing each host (including the handshake) then logging in
+	QueryTimeout   time.Duration // Server-side, Exasol cancels statements running longer than this
+	ReadTimeout    time.Duration // Client-side bound on waiting for each response or for bulk data to move (should exceed QueryTimeout, defaults to QueryTimeout+5s)
+	DefaultSchema  string        // Optional schema to open at login
 	TLSConfig      *tls.Config
 	SuppressError  bool // Server errors are logged to Error by default
 	// TODO try compressionEnabled: true
-	Logger         Logger    // Optional for better control over logging
-	WSHandler      WSHandler // Optional for intercepting websocket traffic
+	Logger         Logger      // Optional for better control over logging
+	LevelLogger    LevelLogger // Optional structured alternative to Logger
+	LogLevel       LogLevel    // Minimum level printed by the default logger (defaults to LogWarning)
+	WSHandler      WSHandler   // Optional for intercepting websocket traffic
+	Metrics        Metrics     // Optional for collecting query metrics
 	CachePrepStmts bool
+	FetchBytes     int // Max size of each fetched chunk of a result set (defaults to and is capped at the server's max of up to 64MB)
+	FetchChanSize  int // Buffer size (in rows) of the FetchChan/FetchStream chan (defaults to 1000)
+	StreamChanSize int // Buffer size (in chunks) of the StreamQuery/StreamSelect Rows.Data chan (defaults to 1)
+
+	DisconnectTimeout time.Duration // How long Disconnect waits for in-flight streams (defaults to 10s)
+	ConnectRetries    int           // Optional number of times Connect retries a failed dial or login
+	ConnectBackoff    time.Duration // Delay before the first Connect retry, doubling for each one (defaults to 1s)
+	Keepalive         time.Duration // Optional interval at which to ping Exasol so idle sessions aren't closed
+	AutoReconnect     bool          // Reconnect and retry the statement if the session is lost (e.g. failover) or read-only ones if sending fails
+	PingInterval      time.Duration // Optional interval for websocket pings (default WSHandler) to detect a dead peer
+	ProtocolVersion   uint16        // Websocket API version to request (defaults to 1)
+	RetryPolicy       RetryPolicy   // Optional for controlling the Stream/Bulk retries
+	LogSQLMaxLen      int           // Optional max length of SQL in logs (longer SQL is truncated)
+	RedactBinds       bool          // Replace literal values in logged SQL with ? placeholders
+	// Max number of prepared statements cached (defaults to 1000). The least
+	// recently used is closed to make room for new ones.
+	MaxPreparedStatements int
+	// Optional comment prefixed to every statement as /* QueryComment */, e.g.
+	// a trace ID for correlating with Exasol's auditing and statistics.
+	// ExecConf.Comment overrides it for individual statements.
+	QueryComment string
+	// Optional hook for redacting SQL before it's logged. Regardless of this
+	// IDENTIFIED BY passwords are masked. Bind values are never logged.
+	RedactSQL func(sql string) string
+	// Optional hook called with any (non-fatal) warnings the server reports,
+	// including for the Stream/Bulk methods. They're also logged as warnings.
+	OnWarning func(sql, warning string)
+	// The Stream/Bulk methods dial out to Exasol to set up their proxy.
+	// These override the address dialed (defaults to the
+	// node we connected to), e.g. when going via a websocket-only gateway.
+	// ProxyNetwork may be "tcp4" or "tcp6" to force the address family (for
+	// dual-stack hosts) and ProxyDialer's LocalAddr binds the local address.
+	// Exasol never dials back, the address it listens on is in its reply.
+	ProxyHost    string
+	ProxyPort    uint16
+	ProxyNetwork string
+	ProxyDialer  *net.Dialer
+
+	// Reported to Exasol (e.g. in EXA_DBA_SESSIONS) instead of the auto-detected
+	// OS user and runtime.GOOS. There's no client host in the login protocol,
+	// Exasol records the host the connection comes from.
+	ClientOsUsername string
+	ClientOs         string
+
+	// Dialer options for the default WSHandler (they don't apply to the
+	// Stream/Bulk proxy, see ProxyDialer). HTTPProxy may be an http(s) or socks5 URL. By default
+	// the HTTP_PROXY/HTTPS_PROXY/NO_PROXY env vars are respected unless
+	// IgnoreProxyEnv is set. ConnectTimeout bounds the websocket handshake
+	// (and the login, which custom WSHandlers must bound themselves).
+	Dialer         *net.Dialer // e.g. for binding a local address or custom DNS
+	HTTPProxy      *url.URL
+	IgnoreProxyEnv bool
+
+	// Optional NLS settings for the session (defaults to the server's). They're
+	// applied at login so also affect the Stream/Bulk methods' CSV, e.g. use a
+	// NumericCharacters of ",." for European formatted numbers. All but
+	// FirstDayOfWeek are reported back by GetAttributes.
+	NumericCharacters string // NLS_NUMERIC_CHARACTERS: the decimal then group separator
+	DateFormat        string // NLS_DATE_FORMAT
+	TimestampFormat   string // NLS_TIMESTAMP_FORMAT
+	FirstDayOfWeek    int    // NLS_FIRST_DAY_OF_WEEK: 1 (Monday) to 7 (Sunday)
+
+	// Optional resource management consumer group (Exasol 7.0+) for the
+	// session, e.g. a low priority group for batch loads. It's applied at
+	// login (the user needs to have been granted it), see SetConsumerGroup.
+	ConsumerGroup string
 
 	Timeout uint32 // Deprecated - Use Query/ConnectTimeout instead
 }
 
+// ExecConf can be passed to Execute and the Fetch methods
+// in place of their positional optional args.
+type ExecConf struct {
+	Binds      [][]interface{} // The Fetch methods only accept a single row
+	Schema     string
+	DataTypes  []DataType // Execute only
+	IsColumnar bool       // Execute only
+	FetchBytes int        // Fetch only. Overrides ConnConf.FetchBytes
+	// Execute only. Overrides the session's autocommit for just this statement.
+	// A session has a single transaction so committing the statement also
+	// commits any earlier uncommitted statements.
+	AutoCommit *bool
+	// Overrides ConnConf.QueryComment for just this statement
+	Comment string
+}
+
 // By default we use the gorilla/websocket implementation however you can also
 // specify a custom websocket handler which you can then use to intercept
 // API traffic. This is handy for:
@@ -68,6 +185,10 @@ type ConnConf struct {
 //   2. Emulating Exasol for testing purposes
 //   3. Intercepting and manipulating the traffic (e.g. for buffering, caching etc)
 // See websocket_handler.go for the default implementation.
+// A fake handler lets code using this library be unit tested without Exasol.
+// The simplest approach is for it to JSON marshal the requests written to
+// it and to JSON unmarshal canned responses into those it reads into.
+// See NewConnWithTransport and TestFakeWSHandler in client_test.go.
 // The custom websocket handler must conform to the following interface:
 type WSHandler interface {
 	// tls.Config is optional. If specified SSL should be enabled
@@ -83,22 +204,77 @@ type WSHandler interface {
 type Conn struct {
 	Conf      ConnConf
 	SessionID uint64
-	Stats     map[string]int
 	Metadata  *AuthData
+	// As reported at login, e.g. "7.1.2" for feature gating
+	// (see ServerVersionAtLeast) and bug reports
+	ServerVersion string
+	DatabaseName  string
 
 	log           Logger
+	host          string // The node we connected to (Conf.Host may be an IP range)
 	wsh           WSHandler
-	prepStmtCache map[string]*prepStmt
+	metrics       Metrics
+	stats         connStats // Reported by Snapshot
+	prepStmtCache *stmtCache
+	currentSchema string   // As far as we know, for keying prepStmtCache
+	netConn       net.Conn // From ConnectWith, cleared once used
+	viaNetConn    bool     // Connected via ConnectWith so can't Reconnect
 	mux           sync.Mutex
+	sendMux       sync.Mutex // Held for each request/response pair
+	writeMux      sync.Mutex // Serializes websocket writes so Abort can be sent concurrently
+	aborting      int32      // Set (atomically) by Abort until the aborted response is received
+	closed        int32      // Set (atomically) once Disconnect is called
+	broken        int32      // Set (atomically) by websocket errors until a Reconnect
+	noWSHandler   int32      // Set (atomically) once wsh is cleared (by Disconnect or a failed Reconnect)
+	noAutocommit  int32      // Set (atomically) while we've disabled the session's autocommit
+	inFlight      sync.WaitGroup
+	protoVersion  uint16 // As negotiated with the server
+	stopKeepalive chan bool
+	keepaliveDone chan bool
+}
+
+// Result is the outcome of executing a statement
+type Result struct {
+	NumResults   int   // The number of results the server returned
+	RowsAffected int64 // The number of rows affected (summed across all results)
+	RowsInserted int64 // The same as RowsAffected but only for INSERT & IMPORT statements
+	// For queries the number of rows in the result set. The rows themselves
+	// are discarded; use the Fetch methods to retrieve them.
+	NumRows  int64
+	Warnings []string // Any (non-fatal) warnings the server reported
+	// How long the client waited, from sending the statement to receiving
+	// the full response. For ExecuteBatch it's that of the whole batch.
+	Duration time.Duration
 }
 
 func Connect(conf ConnConf) (*Conn, error) {
+	return connect(conf, nil)
+}
+
+// ConnectWith is the same as Connect but does the websocket handshake over
+// an already established netConn (e.g. an SSH tunnel) instead of dialing
+// ConnConf.Host/Port. Those are still used for the handshake's Host header
+// and the Stream/Bulk methods' proxy (see ConnConf.ProxyHost/ProxyPort).
+// A net.Conn can't be redialed so Reconnect (and AutoReconnect) fail.
+// If TLSConfig is set the TLS handshake is done over netConn too.
+func ConnectWith(conf ConnConf, netConn net.Conn) (*Conn, error) {
+	if netConn == nil {
+		return nil, errors.New("ConnectWith requires a net.Conn")
+	}
+	if conf.WSHandler != nil {
+		return nil, errors.New("ConnectWith can't be used with a custom WSHandler")
+	}
+	return connect(conf, netConn)
+}
+
+func connect(conf ConnConf, netConn net.Conn) (*Conn, error) {
 	c := &Conn{
 		Conf:          conf,
-		Stats:         map[string]int{},
 		log:           conf.Logger,
-		wsh:           conf.WSHandler,
-		prepStmtCache: map[string]*prepStmt{},
+		metrics:       conf.Metrics,
+		prepStmtCache: newStmtCache(conf.MaxPreparedStatements),
+		netConn:       netConn,
+		viaNetConn:    netConn != nil,
 	}
 
 	if c.Conf.Timeout > 0 {
@@ -107,56 +283,277 @@ func Connect(conf ConnConf) (*Conn, error) {
 	}
 
 	if c.log == nil {
-		c.log = newDefaultLogger()
+		if conf.LevelLogger != nil {
+			c.log = &levelLogger{ll: conf.LevelLogger, conn: c}
+		} else {
+			c.log = newDefaultLogger(conf.LogLevel)
+		}
 	}
 
-	if c.wsh == nil {
-		c.wsh = newDefaultWSHandler()
+	if n, err := c.fetchBytes(0); err != nil {
+		return nil, c.errorf("Invalid ConnConf: %s", err)
+	} else if c.Conf.FetchBytes > n {
+		c.Conf.FetchBytes = n // So it's only warned about once
+	}
+	if c.Conf.ReadTimeout > 0 && c.Conf.ReadTimeout <= c.Conf.QueryTimeout {
+		c.log.Warning("exasol.ConnConf.ReadTimeout should exceed QueryTimeout so the server can cancel queries first")
+	}
+	if c.Conf.FirstDayOfWeek < 0 || c.Conf.FirstDayOfWeek > 7 {
+		return nil, c.errorf("Invalid ConnConf: FirstDayOfWeek must be 1 to 7 not %d", c.Conf.FirstDayOfWeek)
 	}
 
-	err := c.wsConnect()
-	if err != nil {
-		return nil, c.errorf("Unable to connect to Exasol: %w", err)
+	c.wsh = c.newWSHandler()
+
+	if c.metrics == nil {
+		c.metrics = newDefaultMetrics()
 	}
 
-	err = c.login()
+	err := c.connectAndLogin()
 	if err != nil {
-		return nil, c.errorf("Unable to login to Exasol: %s", err)
+		return nil, c.logError(err)
+	}
+
+	if c.Conf.Keepalive > 0 {
+		c.stopKeepalive = make(chan bool)
+		c.keepaliveDone = make(chan bool)
+		go c.keepalive(c.Conf.Keepalive)
 	}
 
 	return c, nil
 }
 
+// The same as Connect but talking to Exasol via the given WSHandler
+// in place of a websocket. Mostly useful for injecting a fake in tests.
+func NewConnWithTransport(conf ConnConf, wsh WSHandler) (*Conn, error) {
+	conf.WSHandler = wsh
+	return Connect(conf)
+}
+
+// Clone opens a sibling session with the same ConnConf, e.g. for each
+// worker of a parallel bulk load. It has its own websocket and locks.
+// The schema, autocommit and query timeout are copied from this session
+// as they may have been changed since it logged in. A custom
+// ConnConf.WSHandler can't be shared so use CloneWithTransport instead.
+func (c *Conn) Clone() (*Conn, error) {
+	if c.Conf.WSHandler != nil {
+		return nil, c.error("Unable to Clone a connection with a custom WSHandler, use CloneWithTransport")
+	}
+	return c.clone(nil)
+}
+
+// The same as Clone but the sibling talks to Exasol via the given
+// WSHandler, which must not be this connection's (see NewConnWithTransport).
+func (c *Conn) CloneWithTransport(wsh WSHandler) (*Conn, error) {
+	if wsh == nil || wsh == c.Conf.WSHandler {
+		return nil, c.error("Unable to Clone: CloneWithTransport requires a new WSHandler")
+	}
+	return c.clone(wsh)
+}
+
+// Reconnect closes the connection (if still open) and logs in to a new
+// session with the same ConnConf. The session's prepared statements,
+// attributes and any uncommitted transaction are lost. (Exasol's websocket
+// API has no token for resuming a dropped session so it's always a fresh
+// login, though ConnConf settings such as ConsumerGroup are reapplied.)
+// With ConnConf.AutoReconnect this is done when the session is lost unless
+// autocommit is disabled (e.g. a Tx is open) as the uncommitted work
+// would be silently dropped. An open Tx fails with ErrTxLost after a Reconnect.
+func (c *Conn) Reconnect() error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrConnClosed
+	}
+	if c.viaNetConn {
+		return c.error("Unable to Reconnect a connection made with ConnectWith")
+	}
+	c.log.Info("Reconnecting SessionID:", c.SessionID)
+	c.sendMux.Lock()
+	c.writeMux.Lock()
+	if c.wsh != nil {
+		c.wsh.Close()
+	}
+	c.wsh = c.newWSHandler()
+	err := c.wsConnect()
+	if err != nil {
+		c.wsh = nil
+		atomic.StoreInt32(&c.noWSHandler, 1)
+	} else {
+		atomic.StoreInt32(&c.broken, 0)
+		atomic.StoreInt32(&c.noWSHandler, 0)
+	}
+	c.writeMux.Unlock()
+	c.sendMux.Unlock()
+	if err != nil {
+		return c.errorf("Unable to reconnect to Exasol: %w", err)
+	}
+
+	c.prepStmtCache.reset() // The handles went with the old session
+	c.stats.set("StmtCacheLen", 0)
+	err = c.timedLogin()
+	if err != nil {
+		return c.errorf("Unable to login to Exasol: %s", err)
+	}
+	c.onReconnect()
+	return nil
+}
+
+// Waits (up to ConnConf.DisconnectTimeout) for any in-flight
+// streaming operations to finish before closing the connection.
+// Any subsequent use of the connection returns ErrConnClosed.
 func (c *Conn) Disconnect() {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return // Already disconnected (or disconnecting concurrently)
+	}
 	c.log.Info("Disconnecting SessionID:", c.SessionID)
+	if c.stopKeepalive != nil {
+		close(c.stopKeepalive)
+		<-c.keepaliveDone
+		c.stopKeepalive = nil
+	}
 
-	for _, ps := range c.prepStmtCache {
+	timeout := c.Conf.DisconnectTimeout
+	if timeout == 0 {
+		timeout = defaultDisconnectTimeout
+	}
+	done := make(chan bool)
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		c.log.Warning("Timed out waiting for in-flight streams, disconnecting anyway")
+	}
+
+	for _, ps := range c.prepStmtCache.clear() {
 		c.closePrepStmt(ps.sth)
 	}
 	err := c.send(&request{Command: "disconnect"}, &response{})
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrConnClosed) {
 		c.log.Warning("Unable to disconnect from Exasol: ", err)
 	}
-	c.wsh.Close()
-	c.wsh = nil
+	c.sendMux.Lock()
+	defer c.sendMux.Unlock()
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	if c.wsh != nil {
+		c.wsh.Close()
+		c.wsh = nil
+		atomic.StoreInt32(&c.noWSHandler, 1)
+	}
 }
 
+// GetAttributes is preferred as it distinguishes false/zero attributes from unset ones
 func (c *Conn) GetSessionAttr() (*Attributes, error) {
 	req := &request{Command: "getAttributes"}
 	res := &response{}
-	err := c.send(req, res)
+	err := c.sendIdempotent(req, res)
+	if err != nil {
+		return nil, c.errorf("Unable to get session attributes: %s", err)
+	}
+	return res.Attributes, nil
+}
+
+// Returns the session's currently open schema ("" if none)
+func (c *Conn) CurrentSchema() (string, error) {
+	attrs, err := c.GetSessionAttr()
+	if err != nil {
+		return "", err
+	}
+	return attrs.CurrentSchema, nil
+}
+
+// Whether the server is compressing the websocket messages
+func (c *Conn) CompressionEnabled() (bool, error) {
+	attrs, err := c.GetSessionAttr()
+	if err != nil {
+		return false, err
+	}
+	return attrs.CompressionEnabled, nil
+}
+
+// Whether the connection is encrypted (i.e. ConnConf.TLSConfig was set).
+// This isn't a session attribute so doesn't need a round trip.
+func (c *Conn) Encrypted() bool {
+	return c.Conf.TLSConfig != nil
+}
+
+// UseSchema opens schema as the session's current schema so that subsequent
+// calls can use non-schema-qualified identifiers. Note that passing a schema
+// to an individual call also changes the current schema going forward.
+func (c *Conn) UseSchema(schema string) error {
+	if schema == "" {
+		return c.error("UseSchema requires a schema")
+	}
+	c.log.Info("Using schema ", schema)
+	err := c.setAttributes(&SessionAttributes{CurrentSchema: &schema})
+	if err != nil {
+		return c.errorf("Unable to use schema %s: %s", schema, err)
+	}
+	return nil
+}
+
+// ProtocolVersion returns the websocket API version negotiated with the server
+func (c *Conn) ProtocolVersion() uint16 {
+	return c.protoVersion
+}
+
+// IsAlive reports whether the connection is usable as far as we know
+// without a round trip, i.e. it's not been disconnected and no websocket
+// error has been seen since it was (re)connected. Use Ping to be sure.
+func (c *Conn) IsAlive() bool {
+	return !c.isClosed() && atomic.LoadInt32(&c.broken) == 0
+}
+
+// Ping checks that the connection (and session) is still alive
+func (c *Conn) Ping() error {
+	err := c.send(&sessionAttrReq{Command: "getAttributes"}, &sessionAttrRes{})
+	if err != nil {
+		return c.errorf("Unable to ping Exasol: %w", err)
+	}
+	return nil
+}
+
+// GetAttributes returns all of the session's attributes
+func (c *Conn) GetAttributes() (*SessionAttributes, error) {
+	res := &sessionAttrRes{}
+	err := c.sendIdempotent(&sessionAttrReq{Command: "getAttributes"}, res)
 	if err != nil {
 		return nil, c.errorf("Unable to get session attributes: %s", err)
 	}
+	if res.Attributes == nil {
+		return &SessionAttributes{}, nil // So callers needn't check
+	}
 	return res.Attributes, nil
 }
 
+// SetAttributes sets the session attributes which are non-nil in attrs
+func (c *Conn) SetAttributes(attrs *SessionAttributes) error {
+	err := c.setAttributes(attrs)
+	if err != nil {
+		return c.errorf("Unable to set session attributes: %s", err)
+	}
+	return nil
+}
+
+// SetAttribute sets a single session attribute by its websocket API name,
+// e.g. SetAttribute("feedbackInterval", 10). Unlike SetAttributes this
+// can set attributes SessionAttributes has no field for. False and zero
+// values are sent as is.
+func (c *Conn) SetAttribute(key string, value interface{}) error {
+	if key == "" {
+		return c.error("SetAttribute requires a key")
+	}
+	err := c.setAttribute(key, value)
+	if err != nil {
+		return c.errorf("Unable to set session attribute %s: %s", key, err)
+	}
+	return nil
+}
+
 func (c *Conn) EnableAutoCommit() error {
 	c.log.Info("Enabling AutoCommit")
-	err := c.send(&request{
-		Command:    "setAttributes",
-		Attributes: &Attributes{Autocommit: true},
-	}, &response{})
+	err := c.setAttribute("autocommit", true)
 	if err != nil {
 		return c.errorf("Unable to enable autocommit: %s", err)
 	}
@@ -165,15 +562,7 @@ func (c *Conn) EnableAutoCommit() error {
 
 func (c *Conn) DisableAutoCommit() error {
 	c.log.Info("Disabling AutoCommit")
-	// We have to roll our own map because Attributes
-	// needs to have AutoCommit set to omitempty which
-	// causes autocommit=false not to be sent :-(
-	err := c.send(map[string]interface{}{
-		"command": "setAttributes",
-		"attributes": map[string]interface{}{
-			"autocommit": false,
-		},
-	}, &response{})
+	err := c.setAttribute("autocommit", false)
 	if err != nil {
 		return c.errorf("Unable to disable autocommit: %s", err)
 	}
@@ -198,7 +587,6 @@ func (c *Conn) Commit() error {
 	return nil
 }
 
-// TODO change optional args into an ExecConf struct
 // Optional args are binds, default schema, colDefs, isColumnar flag
 // 1) The binds are data bindings for statements containing placeholders.
 //    You can either specify it as []interface{} if there's only one row
@@ -210,171 +598,724 @@ func (c *Conn) Commit() error {
 //    (https://www.exasol.com/support/browse/EXASOL-2138)
 // 4) The isColumnar boolean indicates whether the binds specified in the
 //    first optional arg are in columnar format (By default the are in row format.)
+// Alternatively you can pass in a single ExecConf in place of the optional args.
+// Execute is for statements that don't return rows. If sql is a query its rows
+// are discarded (and the result set closed) so use the Fetch methods instead.
 func (c *Conn) Execute(sql string, args ...interface{}) (rowsAffected int64, err error) {
-	var binds [][]interface{}
-	if len(args) > 0 && args[0] != nil {
-		switch b := args[0].(type) {
-		case [][]interface{}:
-			binds = b
-		case []interface{}:
-			binds = append(binds, b)
-		default:
-			return 0, c.error("Execute's 2nd param (binds) must be []interface{} or [][]interface{}")
-		}
+	res, err := c.ExecuteResult(sql, args...)
+	if err != nil {
+		return 0, err
 	}
-	var schema string
-	if len(args) > 1 && args[1] != nil {
-		switch s := args[1].(type) {
-		case string:
-			schema = s
-		default:
-			return 0, c.error("Execute's 3nd param (schema) must be a string")
+	return res.RowsAffected, nil
+}
+
+// Exec runs the statement with the given bind values (one per placeholder)
+// returning the number of rows affected, e.g.
+//
+//	n, err := conn.Exec("UPDATE t SET name = ? WHERE id = ?", name, id)
+//
+// Use Execute for binding several rows or for its other options.
+func (c *Conn) Exec(sql string, binds ...interface{}) (int64, error) {
+	if len(binds) == 0 {
+		return c.Execute(sql)
+	}
+	return c.Execute(sql, [][]interface{}{binds})
+}
+
+// The same as Execute but returns the typed result instead of just the row count
+func (c *Conn) ExecuteResult(sql string, args ...interface{}) (*Result, error) {
+	conf, err := c.execArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.AutoCommit != nil {
+		restore, err := c.overrideAutoCommit(*conf.AutoCommit)
+		if err != nil {
+			return nil, c.errorf("Unable to Execute: %w", err)
 		}
+		defer restore()
 	}
-	var dataTypes []DataType
-	if len(args) > 2 && args[2] != nil {
-		switch d := args[2].(type) {
-		case []DataType:
-			dataTypes = d
-		default:
-			return 0, c.error("Execute's 4th param (data types) must be a []DataType")
+
+	sql = c.tagSQL(sql, conf.Comment)
+	start := time.Now()
+	res, err := c.execute(sql, conf.Binds, conf.Schema, conf.DataTypes, conf.IsColumnar)
+	if err != nil {
+		return nil, c.errorf("Unable to Execute: %w", err)
+	}
+	result := newResult(sql, res.ResponseData)
+	result.Duration = time.Since(start)
+	result.Warnings = c.warnings(sql, &res.response)
+	c.discardResultSets(sql, res.ResponseData)
+	c.onQuery(sql, time.Since(start), result.RowsAffected)
+	return result, nil
+}
+
+// Runs multiple statements in a single round trip returning a result per statement.
+// The statements are run in order and the batch is aborted at the first failure
+// in which case a *BatchError is returned. Exasol's error response doesn't say
+// which statement failed so its Index is -1 (use ExecuteStatements if needed).
+func (c *Conn) ExecuteBatch(stmts []string, schema string) ([]*Result, error) {
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+	c.log.Debugf("Execute batch of %d stmts", len(stmts))
+	tagged := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		tagged[i] = c.tagSQL(stmt, "")
+	}
+	req := &execBatchReq{
+		Command:    "executeBatch",
+		Attributes: &Attributes{CurrentSchema: schema},
+		SqlTexts:   tagged,
+	}
+	res := &execRes{}
+	start := time.Now()
+	err := c.send(req, res)
+	duration := time.Since(start)
+	c.trackCurrentSchema(schema, err, stmts...)
+	if err != nil {
+		return nil, c.logError(&BatchError{Index: -1, Err: err})
+	}
+	warnings := c.warnings(strings.Join(stmts, ";\n"), &res.response)
+
+	results := make([]*Result, len(res.ResponseData.Results))
+	for i, r := range res.ResponseData.Results {
+		data := &execData{
+			NumResults: 1,
+			Results:    []result{r},
 		}
+		results[i] = newResult(stmts[i], data)
+		results[i].Warnings = warnings // The server doesn't say which stmt they're for
+		results[i].Duration = duration
+		c.discardResultSets(stmts[i], data)
 	}
-	isColumnar := false // Whether or not the passed-in binds are columnar
-	if len(args) > 3 && args[3] != nil {
-		switch ic := args[3].(type) {
-		case bool:
-			isColumnar = ic
-		default:
-			return 0, c.error("Execute's 5th param (isColumnar) must be a boolean")
+	return results, nil
+}
+
+// Runs the statements of a script (e.g. a migration file) one at a time
+// returning a result (including its RowsAffected) per statement. The script
+// is split as per SplitStatements. Execution stops at the first failure in
+// which case the results so far are returned along with a *BatchError
+// whose Index is that of the failing statement. Unlike ExecuteBatch this
+// takes a round trip per statement.
+func (c *Conn) ExecuteStatements(script string, schema string) ([]*Result, error) {
+	stmts := SplitStatements(script)
+	results := make([]*Result, 0, len(stmts))
+	for i, stmt := range stmts {
+		res, err := c.ExecuteResult(stmt, ExecConf{Schema: schema})
+		if err != nil {
+			return results, &BatchError{Index: i, SQL: stmt, Err: err, method: "ExecuteStatements"}
 		}
+		results = append(results, res)
 	}
+	return results, nil
+}
 
-	res, err := c.execute(sql, binds, schema, dataTypes, isColumnar)
-	if err != nil {
-		return 0, c.errorf("Unable to Execute: %s", err)
-	} else if res.ResponseData.NumResults > 0 {
-		return res.ResponseData.Results[0].RowCount, nil
+// BatchError is returned when a statement within a batch fails
+type BatchError struct {
+	Index int    // The index of the failing statement (-1 if unknown, as for ExecuteBatch)
+	SQL   string // The failing statement (if known)
+	Err   error
+
+	method string // Defaults to ExecuteBatch
+}
+
+func (e *BatchError) Error() string {
+	method := e.method
+	if method == "" {
+		method = "ExecuteBatch"
 	}
-	return 0, nil
+	if e.Index < 0 {
+		return fmt.Sprintf("Unable to %s: %s", method, e.Err)
+	}
+	return fmt.Sprintf("Unable to %s: stmt %d failed: %s\n%s", method, e.Index, e.Err, e.SQL)
 }
 
+func (e *BatchError) Unwrap() error { return e.Err }
+
 // Optional args are binds, and default schema
 // 1) The binds are data bindings for queries containing placeholders.
 //    You can specify it []interface{}
 // 2) Specifying the default schema allows you to use non-schema-qualified
 //    table identifiers in the statement even when you have no schema currently open.
+// Alternatively you can pass in a single ExecConf in place of the optional args.
+// BOOLEAN values are returned as bools and NULLs (of any type) as nil.
+// If an error occurs while fetching the rows it is logged and the chan is
+// closed early. Use FetchStream if you need to check for such errors.
 func (c *Conn) FetchChan(sql string, args ...interface{}) (<-chan []interface{}, error) {
-	var binds []interface{}
-	if len(args) > 0 && args[0] != nil {
-		switch b := args[0].(type) {
-		case []interface{}:
-			binds = b
-		default:
-			return nil, c.error("Fetch's 2nd param (binds) must be []interface{}")
-		}
-	}
-	var schema string
-	if len(args) > 1 && args[1] != nil {
-		switch s := args[1].(type) {
-		case string:
-			schema = s
-		default:
-			return nil, c.error("Fetch's 3nd param (schema) must be a string")
-		}
+	stream, err := c.FetchStream(sql, args...)
+	if err != nil {
+		return nil, err
 	}
+	return stream.ch, nil
+}
 
-	resp, err := c.execute(sql, [][]interface{}{binds}, schema, nil, false)
+// The same as FetchChan but returns a *ResultStream which reports
+// any errors encountered while fetching the rows via its Err method.
+func (c *Conn) FetchStream(sql string, args ...interface{}) (*ResultStream, error) {
+	conf, err := c.fetchArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	fetchBytes, err := c.fetchBytes(conf.FetchBytes)
 	if err != nil {
 		return nil, c.errorf("Unable to Fetch: %s", err)
 	}
-	respData := resp.ResponseData
-	if respData.NumResults != 1 {
-		return nil, c.errorf("Unexpected numResults: %v", respData.NumResults)
+
+	rs, err := c.query(sql, conf)
+	if err != nil {
+		return nil, err
 	}
-	result := respData.Results[0]
-	if result.ResultType != resultSetType {
-		return nil, c.errorf("Unexpected result type: %v", result.ResultType)
+
+	stream := &ResultStream{
+		columns: rs.Columns,
+		ch:      make(chan []interface{}, chanSize(c.Conf.FetchChanSize, defaultFetchChanSize)),
+		stop:    make(chan bool),
 	}
-	if result.ResultSet == nil {
-		return nil, c.error("Missing websocket API resultset")
+	c.inFlight.Add(1)
+	go c.resultsToStream(rs, stream, fetchBytes)
+
+	return stream, nil
+}
+
+// Returns the column names and the data in Exasol's native column-major
+// layout (i.e. data[col][row]) which avoids transposing it into rows.
+// The optional args are the same as for FetchChan.
+func (c *Conn) FetchColumns(sql string, args ...interface{}) ([]string, [][]interface{}, error) {
+	conf, err := c.fetchArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	fetchBytes, err := c.fetchBytes(conf.FetchBytes)
+	if err != nil {
+		return nil, nil, c.errorf("Unable to Fetch: %s", err)
+	}
+	rs, err := c.query(sql, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rs.ResultSetHandle != 0 {
+		defer c.closeResultSet(rs.ResultSetHandle)
 	}
 
-	ch := make(chan []interface{}, 1000)
-	go c.resultsToChan(result.ResultSet, ch)
+	names := make([]string, len(rs.Columns))
+	data := make([][]interface{}, len(rs.Columns))
+	for i, col := range rs.Columns {
+		names[i] = col.Name
+		data[i] = make([]interface{}, 0, rs.NumRows)
+	}
+	appendCols := func(cols [][]interface{}) {
+		for i := range data {
+			if i < len(cols) {
+				data[i] = append(data[i], cols[i]...)
+			}
+		}
+	}
+	appendCols(rs.Data)
+	if rs.ResultSetHandle == 0 {
+		return names, data, nil
+	}
 
-	return ch, nil
+	rowsRetrieved := uint64(0)
+	if len(rs.Data) > 0 {
+		rowsRetrieved = uint64(len(rs.Data[0]))
+	}
+	for rowsRetrieved < rs.NumRows {
+		fetched, err := c.fetchRows(rs.ResultSetHandle, rowsRetrieved, fetchBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		rowsRetrieved += fetched.NumRows
+		appendCols(fetched.Data)
+	}
+	return names, data, nil
 }
 
 // For large datasets use FetchChan to avoid buffering all the data in memory
 func (c *Conn) FetchSlice(sql string, args ...interface{}) (res [][]interface{}, err error) {
-	resChan, err := c.FetchChan(sql, args...)
+	stream, err := c.FetchStream(sql, args...)
 	if err != nil {
 		return nil, err
 	}
-	for row := range resChan {
+	for row, ok := stream.Next(); ok; row, ok = stream.Next() {
 		res = append(res, row)
 	}
+	if stream.Err() != nil {
+		return nil, stream.Err()
+	}
 	return res, nil
 }
 
-func (c *Conn) SetTimeout(timeout uint32) error {
-	err := c.send(&request{
-		Command:    "setAttributes",
-		Attributes: &Attributes{QueryTimeout: timeout},
-	}, &response{})
+// The same as FetchSlice but to bound memory use at most limit rows are
+// fetched. If the query returns more than that the result set is closed
+// and the first limit rows are returned along with ErrRowLimit.
+func (c *Conn) FetchSliceLimit(sql string, limit int, args ...interface{}) (res [][]interface{}, err error) {
+	if limit <= 0 {
+		return nil, c.errorf("FetchSliceLimit's limit must be positive: %d", limit)
+	}
+	stream, err := c.FetchStream(sql, args...)
 	if err != nil {
-		return c.errorf("Unable to set timeout: %s", err)
+		return nil, err
 	}
-	return nil
+	for row, ok := stream.Next(); ok; row, ok = stream.Next() {
+		if len(res) == limit {
+			stream.CloseEarly()
+			return res, ErrRowLimit
+		}
+		res = append(res, row)
+	}
+	if stream.Err() != nil {
+		return nil, stream.Err()
+	}
+	return res, nil
 }
 
-// Gets a sync.Mutext lock on the handle.
-// Allows coordinating use of the handle across multiple Go routines
-func (c *Conn) Lock()   { c.mux.Lock() }
-func (c *Conn) Unlock() { c.mux.Unlock() }
-
-/*--- Private Routines ---*/
-
-func (c *Conn) login() error {
-	loginReq := &loginReq{
-		Command:         "login",
-		ProtocolVersion: ExasolAPIVersion,
-	}
-	loginRes := &loginRes{}
-	err := c.send(loginReq, loginRes)
+// FetchAll is for statements (e.g. scripts) returning several results.
+// It returns each result set (with all its rows) or row count in order.
+// The optional args are the same as for FetchChan.
+func (c *Conn) FetchAll(sql string, args ...interface{}) ([]*ResultSet, error) {
+	conf, err := c.fetchArgs(args)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	pubKeyMod, _ := hex.DecodeString(loginRes.ResponseData.PublicKeyModulus)
-	var modulus big.Int
-	modulus.SetBytes(pubKeyMod)
-
-	pubKeyExp, _ := strconv.ParseUint(loginRes.ResponseData.PublicKeyExponent, 16, 32)
-
-	pubKey := rsa.PublicKey{
-		N: &modulus,
-		E: int(pubKeyExp),
+	fetchBytes, err := c.fetchBytes(conf.FetchBytes)
+	if err != nil {
+		return nil, c.errorf("Unable to Fetch: %s", err)
 	}
-	password := []byte(c.Conf.Password)
-	encPass, err := rsa.EncryptPKCS1v15(rand.Reader, &pubKey, password)
+	sql = c.tagSQL(sql, conf.Comment)
+	start := time.Now()
+	resp, err := c.execute(sql, conf.Binds, conf.Schema, nil, false)
 	if err != nil {
-		return fmt.Errorf("Password encryption error: %s", err)
+		return nil, c.errorf("Unable to Fetch: %w", err)
 	}
-	b64Pass := base64.StdEncoding.EncodeToString(encPass)
-
-	osUser, _ := user.Current()
+	c.warnings(sql, &resp.response)
 
-	authReq := &authReq{
-		Username:         c.Conf.Username,
-		Password:         b64Pass,
-		UseCompression:   false, // TODO: See if we can get compression working
-		ClientName:       c.Conf.ClientName,
-		ClientVersion:    c.Conf.ClientVersion, // The version of the calling application
+	results := resp.ResponseData.Results
+	res := make([]*ResultSet, len(results))
+	var numRows int64
+	for i, r := range results {
+		if r.ResultType != resultSetType || r.ResultSet == nil {
+			res[i] = &ResultSet{RowCount: r.RowCount}
+			continue
+		}
+		rs := r.ResultSet
+		res[i] = &ResultSet{
+			Columns:  make([]string, len(rs.Columns)),
+			Types:    columnTypes(rs.Columns),
+			Rows:     make([][]interface{}, 0, rs.NumRows),
+			RowCount: int64(rs.NumRows),
+		}
+		for j, col := range rs.Columns {
+			res[i].Columns[j] = col.Name
+		}
+		stream := &ResultStream{
+			ch:   make(chan []interface{}, chanSize(c.Conf.FetchChanSize, defaultFetchChanSize)),
+			stop: make(chan bool),
+		}
+		c.inFlight.Add(1)
+		go c.resultsToStream(rs, stream, fetchBytes)
+		for row, ok := stream.Next(); ok; row, ok = stream.Next() {
+			res[i].Rows = append(res[i].Rows, row)
+		}
+		if stream.Err() != nil {
+			// Free the result sets we won't get to
+			for _, r := range results[i+1:] {
+				if r.ResultSet != nil && r.ResultSet.ResultSetHandle != 0 {
+					c.closeResultSet(r.ResultSet.ResultSetHandle)
+				}
+			}
+			return nil, stream.Err()
+		}
+		numRows += res[i].RowCount
+	}
+	c.onQuery(sql, time.Since(start), numRows)
+	return res, nil
+}
+
+// Returns the first row of the query (any other rows are discarded).
+// The optional args are the same as for FetchChan.
+// If the query returns no rows then ErrNoRows is returned.
+func (c *Conn) QueryRow(sql string, args ...interface{}) ([]interface{}, error) {
+	stream, err := c.FetchStream(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	row, ok := stream.Next()
+	stream.CloseEarly()
+	if !ok {
+		if stream.Err() != nil {
+			return nil, stream.Err()
+		}
+		return nil, ErrNoRows
+	}
+	return row, nil
+}
+
+// Returns the first column of the first row of the query.
+// The optional args are the same as for FetchChan.
+// If the query returns no rows then ErrNoRows is returned.
+func (c *Conn) QueryScalar(sql string, args ...interface{}) (interface{}, error) {
+	row, err := c.QueryRow(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(row) == 0 {
+		return nil, c.error("QueryScalar's query returned no columns")
+	}
+	return row[0], nil
+}
+
+// Returns the last value the table's IDENTITY column generator handed out.
+// Exasol doesn't report generated identities in the insert response so this
+// reads the generator from EXA_ALL_COLUMNS (which holds the next value to be
+// used) less one. That's only this session's last insert if nothing else
+// changes the generator in between, i.e. no other session inserts into the
+// table and there's no ALTER TABLE ... SET IDENTITY.
+// Unquoted schema and table names are uppercased like in SQL.
+func (c *Conn) CurrentIdentity(schema, table string) (int64, error) {
+	sql := `SELECT column_identity FROM sys.exa_all_columns
+		WHERE column_schema = ? AND column_table = ? AND column_identity IS NOT NULL`
+	val, err := c.QueryScalar(sql, []interface{}{identName(schema), identName(table)})
+	if errors.Is(err, ErrNoRows) {
+		return 0, c.errorf("Unable to get CurrentIdentity: %s.%s has no IDENTITY column", schema, table)
+	} else if err != nil {
+		return 0, err
+	}
+	var next int64
+	switch v := val.(type) {
+	case string:
+		next, err = strconv.ParseInt(v, 10, 64)
+	case float64:
+		next = int64(v)
+	default:
+		err = fmt.Errorf("unexpected type %T", val)
+	}
+	if err != nil {
+		return 0, c.errorf("Unable to get CurrentIdentity: invalid column_identity %v: %s", val, err)
+	}
+	return next - 1, nil
+}
+
+// RawCommand sends an arbitrary websocket API command (e.g. "getHosts") for
+// those the library doesn't wrap. The payload holds the command's other
+// fields. The raw response is returned (or an error if its status isn't ok).
+func (c *Conn) RawCommand(cmd string, payload map[string]interface{}) (map[string]interface{}, error) {
+	req := map[string]interface{}{}
+	for k, v := range payload {
+		req[k] = v
+	}
+	req["command"] = cmd
+	res := &rawResponse{}
+	err := c.send(req, res)
+	if err != nil {
+		return res.data, c.errorf("Unable to run %s: %w", cmd, err)
+	}
+	return res.data, nil
+}
+
+// Returns the DataTypes of the sql's parameters (placeholders) and of its
+// result set columns without executing it. The latter is empty if the
+// statement doesn't return a result set.
+func (c *Conn) Describe(sql string) ([]DataType, []DataType, error) {
+	ps, err := c.createPrepStmt("", sql)
+	if err != nil {
+		return nil, nil, c.errorf("Unable to describe %s: %s", c.logSQL(sql), err)
+	}
+	defer c.closePrepStmt(ps.sth)
+	return columnTypes(ps.columns), columnTypes(ps.resultCols), nil
+}
+
+// A table column as described by DescribeTable
+type ColumnInfo struct {
+	Name     string
+	Type     string // As in SQL, e.g. DECIMAL(18,0) or VARCHAR(100) UTF8
+	Nullable bool
+	Default  *string // The default's SQL expression (nil if none)
+	Identity bool
+	Comment  string
+}
+
+// Returns the table's (or view's) columns in order as per EXA_ALL_COLUMNS.
+// Unquoted schema and table names are uppercased like in SQL.
+func (c *Conn) DescribeTable(schema, table string) ([]ColumnInfo, error) {
+	cols, err := c.describeTable(schema, table)
+	if err != nil {
+		return nil, c.errorf("Unable to DescribeTable: %w", err)
+	}
+	if len(cols) == 0 {
+		return nil, c.errorf("Unable to DescribeTable: %s.%s not found", schema, table)
+	}
+	return cols, nil
+}
+
+// One of the results returned by FetchAll. For result sets Rows holds all
+// the rows and RowCount their number. For the results of statements that
+// don't return rows Columns, Types and Rows are nil and RowCount is the
+// number of rows affected.
+type ResultSet struct {
+	Columns  []string
+	Types    []DataType
+	Rows     [][]interface{}
+	RowCount int64
+}
+
+// ResultStream iterates over the rows of a query in the style of bufio.Scanner:
+//
+//	for row, ok := stream.Next(); ok; row, ok = stream.Next() { ... }
+//	if stream.Err() != nil { ... }
+type ResultStream struct {
+	columns  []column
+	ch       chan []interface{}
+	err      error // Only set by the fetching goroutine before it closes ch
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+// Returns the next row. The bool is false once all rows
+// have been returned or an error occurred.
+func (rs *ResultStream) Next() ([]interface{}, bool) {
+	row, ok := <-rs.ch
+	return row, ok
+}
+
+// Returns the result set's column types, e.g. for choosing which of
+// ParseTimestamp, ParseIntervalDayToSecond etc to parse values with
+func (rs *ResultStream) Types() []DataType { return columnTypes(rs.columns) }
+
+// Returns the error, if any, that stopped the stream.
+// This is only meaningful once Next has returned false.
+func (rs *ResultStream) Err() error { return rs.err }
+
+// Stops fetching any remaining rows and closes the result set on the server.
+// Call this if you stop reading from the stream before it's exhausted
+// otherwise the fetching goroutine is left blocked. Any unread rows are discarded.
+func (rs *ResultStream) CloseEarly() {
+	rs.stopOnce.Do(func() { close(rs.stop) })
+	for range rs.ch {
+		// Wait for the fetching goroutine to wrap up
+	}
+}
+
+// Aborts the query currently running on this connection.
+// This is meant to be called from a different goroutine than the one that's
+// blocked waiting on the query; that call will return an ErrQueryAborted error.
+// The session remains usable afterwards so subsequent queries will work.
+func (c *Conn) Abort() error {
+	c.log.Info("Aborting query")
+	atomic.StoreInt32(&c.aborting, 1)
+	// The server doesn't send a response to abortQuery
+	err := c.write(&request{Command: "abortQuery"})
+	if err != nil {
+		atomic.StoreInt32(&c.aborting, 0)
+		return c.errorf("Unable to abort query: %s", err)
+	}
+	return nil
+}
+
+func (c *Conn) SetTimeout(timeout uint32) error {
+	err := c.setAttribute("queryTimeout", timeout)
+	if err != nil {
+		return c.errorf("Unable to set timeout: %s", err)
+	}
+	return nil
+}
+
+// Moves the session into the resource management consumer group, e.g. to
+// run a heavy load at a lower priority. It's kept in ConnConf.ConsumerGroup
+// so it's reapplied if the connection reconnects.
+func (c *Conn) SetConsumerGroup(group string) error {
+	_, err := c.execute(consumerGroupSQL(group), nil, "", nil, false)
+	if err != nil {
+		return c.errorf("Unable to set consumer group: %s", err)
+	}
+	c.Conf.ConsumerGroup = group
+	return nil
+}
+
+// Whether the server's version is at least version, e.g. "7.1".
+// Versions are compared numerically component by component
+// with any missing components being treated as 0.
+func (c *Conn) ServerVersionAtLeast(version string) bool {
+	have := versionParts(c.ServerVersion)
+	want := versionParts(version)
+	for i, w := range want {
+		h := 0
+		if i < len(have) {
+			h = have[i]
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
+
+// Gets a sync.Mutext lock on the handle.
+// Individual calls are already safe to make concurrently. This allows
+// coordinating a sequence of calls (e.g. a transaction) across multiple Go routines
+func (c *Conn) Lock()   { c.mux.Lock() }
+func (c *Conn) Unlock() { c.mux.Unlock() }
+
+/*--- Private Routines ---*/
+
+// Pings Exasol every interval until Disconnect. The pings go through
+// send so they queue behind any request that's in progress.
+func (c *Conn) keepalive(interval time.Duration) {
+	defer close(c.keepaliveDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopKeepalive:
+			return
+		case <-ticker.C:
+			c.log.Debug("Sending keepalive")
+			err := c.send(&sessionAttrReq{Command: "getAttributes"}, &sessionAttrRes{})
+			if err != nil {
+				c.log.Warning("Keepalive failed: ", err)
+			}
+		}
+	}
+}
+
+// Whether the connection has been disconnected (or lost by a failed Reconnect).
+// This doesn't take sendMux so it doesn't wait for in-flight requests.
+func (c *Conn) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.noWSHandler) == 1
+}
+
+// Sets the session's autocommit returning a func that restores the
+// original setting (both are no-ops if it's already as requested).
+// If the server doesn't report the original setting it's left as set.
+func (c *Conn) overrideAutoCommit(autocommit bool) (func(), error) {
+	attrs, err := c.GetAttributes()
+	if err != nil {
+		return nil, err
+	}
+	if attrs.Autocommit != nil && *attrs.Autocommit == autocommit {
+		return func() {}, nil
+	}
+	err = c.setAttributes(&SessionAttributes{Autocommit: &autocommit})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to override autocommit: %w", err)
+	}
+	if attrs.Autocommit == nil {
+		c.log.Warning("Unable to restore autocommit afterwards: its original setting is unknown")
+		return func() {}, nil
+	}
+	return func() {
+		orig := !autocommit
+		if err := c.setAttributes(&SessionAttributes{Autocommit: &orig}); err != nil {
+			c.log.Warning("Unable to restore autocommit: ", err)
+		}
+	}, nil
+}
+
+func (c *Conn) setAttributes(attrs *SessionAttributes) error {
+	err := c.sendIdempotent(&sessionAttrReq{
+		Command:    "setAttributes",
+		Attributes: attrs,
+	}, &response{})
+	if err == nil && attrs.CurrentSchema != nil {
+		c.currentSchema = *attrs.CurrentSchema
+	}
+	if err == nil && attrs.Autocommit != nil {
+		c.trackAutocommit(*attrs.Autocommit)
+	}
+	return err
+}
+
+func (c *Conn) setAttribute(key string, value interface{}) error {
+	err := c.sendIdempotent(&sessionAttrReq{
+		Command:    "setAttributes",
+		Attributes: map[string]interface{}{key: value},
+	}, &response{})
+	if schema, ok := value.(string); err == nil && ok && key == "currentSchema" {
+		c.currentSchema = schema
+	}
+	if on, ok := value.(bool); err == nil && ok && key == "autocommit" {
+		c.trackAutocommit(on)
+	}
+	return err
+}
+
+func (c *Conn) trackAutocommit(on bool) {
+	if on {
+		atomic.StoreInt32(&c.noAutocommit, 0)
+	} else {
+		atomic.StoreInt32(&c.noAutocommit, 1)
+	}
+}
+
+// Whether the session may have uncommitted work (i.e. autocommit is
+// disabled) that would be lost by reconnecting to a new session
+func (c *Conn) inTransaction() bool {
+	return atomic.LoadInt32(&c.noAutocommit) == 1
+}
+
+func (c *Conn) login() error {
+	version := c.Conf.ProtocolVersion
+	if version == 0 {
+		version = ExasolAPIVersion
+	}
+	sendLogin := func(version uint16) (*loginRes, error) {
+		res := &loginRes{}
+		return res, c.send(&loginReq{
+			Command:         "login",
+			ProtocolVersion: version,
+		}, res)
+	}
+	loginRes, err := sendLogin(version)
+	if err != nil && version != ExasolAPIVersion {
+		// Older servers may reject versions they don't know about
+		c.log.Warningf("Unable to login with protocol version %d, falling back to %d: %s",
+			version, ExasolAPIVersion, err)
+		version = ExasolAPIVersion
+		loginRes, err = sendLogin(version)
+	}
+	if err != nil {
+		return err
+	}
+
+	pubKeyMod, _ := hex.DecodeString(loginRes.ResponseData.PublicKeyModulus)
+	var modulus big.Int
+	modulus.SetBytes(pubKeyMod)
+
+	pubKeyExp, _ := strconv.ParseUint(loginRes.ResponseData.PublicKeyExponent, 16, 32)
+
+	pubKey := rsa.PublicKey{
+		N: &modulus,
+		E: int(pubKeyExp),
+	}
+	password := []byte(c.Conf.Password)
+	encPass, err := rsa.EncryptPKCS1v15(rand.Reader, &pubKey, password)
+	if err != nil {
+		return fmt.Errorf("Password encryption error: %s", err)
+	}
+	b64Pass := base64.StdEncoding.EncodeToString(encPass)
+
+	clientOsUsername := c.Conf.ClientOsUsername
+	if clientOsUsername == "" {
+		// This fails in containers without a passwd entry for the user
+		if osUser, err := user.Current(); err == nil {
+			clientOsUsername = osUser.Username
+		}
+	}
+	clientOs := c.Conf.ClientOs
+	if clientOs == "" {
+		clientOs = runtime.GOOS
+	}
+
+	authReq := &authReq{
+		Username:         c.Conf.Username,
+		Password:         b64Pass,
+		UseCompression:   false, // TODO: See if we can get compression working
+		ClientName:       c.Conf.ClientName,
+		ClientVersion:    c.Conf.ClientVersion, // The version of the calling application
 		DriverName:       "go-exasol-client v" + DriverVersion,
-		ClientOs:         runtime.GOOS,
-		ClientOsUsername: osUser.Username,
+		ClientOs:         clientOs,
+		ClientOsUsername: clientOsUsername,
 		ClientRuntime:    runtime.Version(),
 		Attributes:       &Attributes{Autocommit: true}, // Default AutoCommit to on
 	}
@@ -382,32 +1323,81 @@ func (c *Conn) login() error {
 	if c.Conf.QueryTimeout.Seconds() > 0 {
 		authReq.Attributes.QueryTimeout = uint32(c.Conf.QueryTimeout.Seconds())
 	}
+	authReq.Attributes.CurrentSchema = c.Conf.DefaultSchema
+	authReq.Attributes.NumericCharacters = c.Conf.NumericCharacters
+	authReq.Attributes.DateFormat = c.Conf.DateFormat
+	authReq.Attributes.DatetimeFormat = c.Conf.TimestampFormat
 
 	authResp := &authResp{}
 	err = c.send(authReq, authResp)
 	if err != nil {
-		return fmt.Errorf("Unable to authenticate: %s", err)
+		return fmt.Errorf("Unable to authenticate: %w", err)
 	}
 
+	// This comes straight from the login response so no extra query
+	// (e.g. SELECT CURRENT_SESSION) is run that restricted users or
+	// read replicas might reject. The only optional one is for FirstDayOfWeek.
 	c.SessionID = authResp.ResponseData.SessionID
 	c.Metadata = authResp.ResponseData
+	c.ServerVersion = c.Metadata.ReleaseVersion
+	c.DatabaseName = c.Metadata.DatabaseName
+	c.currentSchema = c.Conf.DefaultSchema
+	c.trackAutocommit(true)
+	// The server replies with the version it's actually using
+	// which may be lower than requested if it doesn't support it
+	c.protoVersion = uint16(c.Metadata.ProtocolVersion)
+	if c.protoVersion == 0 {
+		c.protoVersion = version
+	}
 	c.log.Info("Connected SessionID:", c.SessionID)
 	c.wsh.EnableCompression(false)
 
+	if c.Conf.FirstDayOfWeek > 0 {
+		// This isn't a session attribute
+		sql := fmt.Sprintf("ALTER SESSION SET NLS_FIRST_DAY_OF_WEEK = %d", c.Conf.FirstDayOfWeek)
+		if _, err := c.execute(sql, nil, "", nil, false); err != nil {
+			return fmt.Errorf("Unable to set FirstDayOfWeek: %s", err)
+		}
+	}
+	if c.Conf.ConsumerGroup != "" {
+		if _, err := c.execute(consumerGroupSQL(c.Conf.ConsumerGroup), nil, "", nil, false); err != nil {
+			return fmt.Errorf("Unable to set ConsumerGroup: %s", err)
+		}
+	}
+
 	return nil
 }
 
+// Unquoted names are uppercased as usual
+func consumerGroupSQL(group string) string {
+	return "ALTER SESSION SET CONSUMER_GROUP = " + quoteName(identName(group))
+}
+
 func (c *Conn) execute(
 	sql string,
 	binds [][]interface{},
 	schema string,
 	dataTypes []DataType,
 	isColumnar bool,
+) (res *execRes, err error) {
+	err = c.withReconnect(schema, sql, func() error {
+		res, err = c.executeOnce(sql, binds, schema, dataTypes, isColumnar)
+		return err
+	})
+	return res, err
+}
+
+func (c *Conn) executeOnce(
+	sql string,
+	binds [][]interface{},
+	schema string,
+	dataTypes []DataType,
+	isColumnar bool,
 ) (*execRes, error) {
 	// Just a simple execute (no prepare) if there are no binds
 	if binds == nil || len(binds) == 0 ||
 		binds[0] == nil || len(binds[0]) == 0 {
-		c.log.Debug("Execute: ", sql)
+		c.logFields(LogDebug, "Execute", "sql", c.logSQL(sql))
 		req := &execReq{
 			Command:    "execute",
 			Attributes: &Attributes{CurrentSchema: schema},
@@ -415,6 +1405,7 @@ func (c *Conn) execute(
 		}
 		res := &execRes{}
 		err := c.send(req, res)
+		c.trackCurrentSchema(schema, err, sql)
 		return res, err
 	} else {
 		return c.executePrepStmt(sql, binds, schema, dataTypes, isColumnar)
@@ -428,22 +1419,39 @@ func (c *Conn) executePrepStmt(
 	dataTypes []DataType,
 	isColumnar bool,
 ) (*execRes, error) {
+	// Rather than Exasol rejecting (or Transpose padding) ragged binds
+	what := "row"
+	if isColumnar {
+		what = "column"
+	}
+	if err := checkRagged(binds, what); err != nil {
+		return nil, err
+	}
+
 	// There are binds so we need to send data so do a prepare + execute
-	ps, err := c.getPrepStmt(schema, sql)
+	ps, release, err := c.getPrepStmt(schema, sql)
 	if err != nil {
 		return nil, err
 	}
 
 	// This is to workaround this bug: https://www.exasol.com/support/browse/EXASOL-2138
+	// (copying the columns as a cached statement may be in use concurrently)
+	columns := ps.columns
 	if dataTypes != nil {
+		columns = append([]column(nil), ps.columns...)
 		for i, dt := range dataTypes {
-			ps.columns[i].DataType = dt
+			columns[i].DataType = dt
 		}
 	}
 
 	if !isColumnar {
 		binds = Transpose(binds)
 	}
+	binds, err = c.convertBinds(binds, columns)
+	if err != nil {
+		release(false)
+		return nil, err
+	}
 	numCols := len(binds)
 	numRows := len(binds[0])
 
@@ -453,40 +1461,393 @@ func (c *Conn) executePrepStmt(
 		StatementHandle: int(ps.sth),
 		NumColumns:      numCols,
 		NumRows:         numRows,
-		Columns:         ps.columns,
+		Columns:         columns,
 		Data:            binds,
 	}
 	res := &execRes{}
 	err = c.send(req, res)
 
-	if err != nil &&
-		regexp.MustCompile("Statement handle not found").MatchString(err.Error()) {
+	// withReconnect takes care of statement handles that have gone away
+	release(isStmtNotFound(err))
+	return res, err
+}
+
+var isStmtNotFoundError = regexp.MustCompile(`Statement handle not found`)
+var isSessionNotFoundError = regexp.MustCompile(`(?i)session not found`)
+
+func isStmtNotFound(err error) bool {
+	return err != nil && isStmtNotFoundError.MatchString(err.Error())
+}
+
+// Calls try and if it fails because the statement handle or (after a
+// failover) the session has gone away then re-prepares the statement or
+// (if AutoReconnect is set and autocommit isn't disabled, so there's no
+// uncommitted work to lose) reconnects and calls try once more. With
+// AutoReconnect read-only queries are also retried if sending them fails.
+// Other statements aren't as the server may have received them, so
+// resending could apply them twice.
+func (c *Conn) withReconnect(schema, sql string, try func() error) error {
+	err := try()
+	switch {
+	case err == nil:
+		return nil
+	case isStmtNotFound(err):
 		// Not sure what causes this but I've seen it happen. So just try again.
-		c.log.Warning("Statement handle not found:", ps.sth)
-		delete(c.prepStmtCache, sql)
-		ps, err := c.getPrepStmt(schema, sql)
+		c.log.Warning("Statement handle not found, retrying: ", err)
+		c.prepStmtCache.remove(c.stmtKey(schema, sql))
+	case c.Conf.AutoReconnect && isSessionNotFoundError.MatchString(err.Error()):
+		if c.inTransaction() {
+			c.log.Warning("Session not found, not reconnecting as autocommit is disabled: ", err)
+			return err
+		}
+		c.log.Warning("Session not found, reconnecting: ", err)
+		if e := c.Reconnect(); e != nil {
+			c.log.Warning(e)
+			return err
+		}
+	case c.Conf.AutoReconnect && errors.Is(err, errSend) && isReadOnlySQL.MatchString(sql):
+		if c.inTransaction() {
+			c.log.Warning("Unable to send query, not reconnecting as autocommit is disabled: ", err)
+			return err
+		}
+		c.log.Warning("Unable to send query, reconnecting: ", err)
+		if e := c.Reconnect(); e != nil {
+			c.log.Warning(e)
+			return err
+		}
+	default:
+		return err
+	}
+	return try()
+}
+
+// Dials and logs in, retrying with backoff up to ConnConf.ConnectRetries
+// times. Errors from the server (e.g. bad credentials) aren't retried.
+func (c *Conn) connectAndLogin() error {
+	backoff := c.Conf.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.wsConnect()
 		if err != nil {
-			return nil, err
+			err = fmt.Errorf("Unable to connect to Exasol: %w", err)
+		} else if err = c.timedLogin(); err != nil {
+			c.wsh.Close()
+			err = fmt.Errorf("Unable to login to Exasol: %w", err)
+		}
+		if err == nil || attempt >= c.Conf.ConnectRetries ||
+			c.viaNetConn || errors.Is(err, errServer) {
+			return err
 		}
-		c.log.Warning("Retrying with:", ps.sth)
-		req.StatementHandle = int(ps.sth)
-		err = c.send(req, res)
+		c.log.Warningf("%s (retrying in %s)", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		c.wsh = c.newWSHandler()
 	}
-	if !c.Conf.CachePrepStmts {
-		c.closePrepStmt(ps.sth)
+}
+
+// Implemented by WSHandlers (like the default) able to time out blocked I/O
+type deadliner interface {
+	setDeadline(t time.Time)
+}
+
+// Bounds login by ConnConf.ConnectTimeout so a server that accepted
+// the connection but never responds doesn't hang us indefinitely
+func (c *Conn) timedLogin() error {
+	if d, ok := c.wsh.(deadliner); ok && c.Conf.ConnectTimeout > 0 {
+		d.setDeadline(time.Now().Add(c.Conf.ConnectTimeout))
+		defer d.setDeadline(time.Time{})
 	}
-	return res, err
+	return c.login()
+}
+
+func (c *Conn) clone(wsh WSHandler) (*Conn, error) {
+	if c.viaNetConn {
+		return nil, c.error("Unable to Clone a connection made with ConnectWith")
+	}
+	attrs, err := c.GetAttributes()
+	if err != nil {
+		return nil, c.errorf("Unable to Clone: %w", err)
+	}
+	conf := c.Conf
+	conf.WSHandler = wsh
+	if attrs.CurrentSchema != nil {
+		conf.DefaultSchema = *attrs.CurrentSchema
+	}
+	clone, err := Connect(conf)
+	if err != nil {
+		return nil, err
+	}
+	err = clone.setAttributes(&SessionAttributes{
+		Autocommit:   attrs.Autocommit,
+		QueryTimeout: attrs.QueryTimeout,
+	})
+	if err != nil {
+		clone.Disconnect()
+		return nil, c.errorf("Unable to Clone: %w", err)
+	}
+	return clone, nil
 }
 
-func (c *Conn) resultsToChan(rs *resultSet, ch chan<- []interface{}) {
+func (c *Conn) newWSHandler() WSHandler {
+	if c.Conf.WSHandler != nil {
+		return c.Conf.WSHandler
+	}
+	wsh := newDefaultWSHandler()
+	wsh.configure(c.Conf)
+	if c.netConn != nil {
+		wsh.useNetConn(c.netConn)
+		c.netConn = nil
+	}
+	return wsh
+}
+
+// Returns no columns if the table doesn't exist
+func (c *Conn) describeTable(schema, table string) ([]ColumnInfo, error) {
+	sql := `SELECT column_name, column_type, column_is_nullable, column_default,
+			column_identity IS NOT NULL, column_comment
+		FROM sys.exa_all_columns
+		WHERE column_schema = ? AND column_table = ? ORDER BY column_ordinal_position`
+	rows, err := c.FetchSlice(sql, []interface{}{identName(schema), identName(table)})
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]ColumnInfo, len(rows))
+	for i, row := range rows {
+		col := &cols[i]
+		col.Name, _ = row[0].(string)
+		col.Type, _ = row[1].(string)
+		col.Nullable, _ = row[2].(bool)
+		if def, ok := row[3].(string); ok {
+			col.Default = &def
+		}
+		col.Identity, _ = row[4].(bool)
+		col.Comment, _ = row[5].(string)
+	}
+	return cols, nil
+}
+
+// Executes the query returning its result set
+func (c *Conn) query(sql string, conf *ExecConf) (*resultSet, error) {
+	sql = c.tagSQL(sql, conf.Comment)
+	start := time.Now()
+	resp, err := c.execute(sql, conf.Binds, conf.Schema, nil, false)
+	if err != nil {
+		return nil, c.errorf("Unable to Fetch: %w", err)
+	}
+	respData := resp.ResponseData
+	if respData.NumResults != 1 || len(respData.Results) != 1 {
+		return nil, c.errorf("Unexpected numResults: %v (use FetchAll for statements returning several results)",
+			respData.NumResults)
+	}
+	result := respData.Results[0]
+	if result.ResultType != resultSetType {
+		return nil, c.errorf("Unexpected result type: %v", result.ResultType)
+	}
+	if result.ResultSet == nil {
+		return nil, c.error("Missing websocket API resultset")
+	}
+	c.onQuery(sql, time.Since(start), int64(result.ResultSet.NumRows))
+	return result.ResultSet, nil
+}
+
+func (c *Conn) execArgs(args []interface{}) (*ExecConf, error) {
+	conf := &ExecConf{}
+	if len(args) > 0 && args[0] != nil {
+		switch b := args[0].(type) {
+		case ExecConf:
+			return &b, nil
+		case *ExecConf:
+			return b, nil
+		case [][]interface{}:
+			conf.Binds = b
+		case []interface{}:
+			conf.Binds = append(conf.Binds, b)
+		default:
+			return nil, c.error("Execute's 2nd param (binds) must be []interface{} or [][]interface{}")
+		}
+	}
+	if len(args) > 1 && args[1] != nil {
+		switch s := args[1].(type) {
+		case string:
+			conf.Schema = s
+		default:
+			return nil, c.error("Execute's 3nd param (schema) must be a string")
+		}
+	}
+	if len(args) > 2 && args[2] != nil {
+		switch d := args[2].(type) {
+		case []DataType:
+			conf.DataTypes = d
+		default:
+			return nil, c.error("Execute's 4th param (data types) must be a []DataType")
+		}
+	}
+	if len(args) > 3 && args[3] != nil {
+		switch ic := args[3].(type) {
+		case bool:
+			conf.IsColumnar = ic // Whether or not the passed-in binds are columnar
+		default:
+			return nil, c.error("Execute's 5th param (isColumnar) must be a boolean")
+		}
+	}
+	return conf, nil
+}
+
+func (c *Conn) fetchArgs(args []interface{}) (*ExecConf, error) {
+	conf := &ExecConf{}
+	if len(args) > 0 && args[0] != nil {
+		switch b := args[0].(type) {
+		case ExecConf:
+			conf = &b
+		case *ExecConf:
+			copied := *b // So the schema arg doesn't change the caller's
+			conf = &copied
+		case []interface{}:
+			conf.Binds = [][]interface{}{b}
+		default:
+			return nil, c.error("Fetch's 2nd param (binds) must be []interface{}")
+		}
+	}
+	if len(args) > 1 && args[1] != nil {
+		switch s := args[1].(type) {
+		case string:
+			conf.Schema = s
+		default:
+			return nil, c.error("Fetch's 3nd param (schema) must be a string")
+		}
+	}
+	if len(conf.Binds) > 1 {
+		return nil, c.error("Fetch only accepts a single row of binds")
+	}
+	return conf, nil
+}
+
+// The maximum numBytes the fetch command accepts
+const maxFetchBytes = 64 * 1024 * 1024
+
+func (c *Conn) fetchBytes(override int) (int, error) {
+	numBytes := c.Conf.FetchBytes
+	if override != 0 {
+		numBytes = override
+	}
+	limit := c.defaultFetchBytes()
+	if numBytes == 0 {
+		return limit, nil
+	}
+	if numBytes < 0 {
+		return 0, fmt.Errorf("FetchBytes must be between 1 and %d: %d", limit, numBytes)
+	}
+	if numBytes > limit {
+		// Rather than the server failing the fetch with an opaque error
+		c.log.Warningf("FetchBytes %d exceeds the server's max so using %d", numBytes, limit)
+		return limit, nil
+	}
+	return numBytes, nil
+}
+
+// Exasol reports the largest data message it sends at login so there's
+// no point asking for more than that per fetch (and it's capped at 64MB)
+func (c *Conn) defaultFetchBytes() int {
+	if c.Metadata != nil && c.Metadata.MaxDataMessageSize > 0 &&
+		c.Metadata.MaxDataMessageSize < maxFetchBytes {
+		return int(c.Metadata.MaxDataMessageSize)
+	}
+	return maxFetchBytes
+}
+
+// Leading /* */ comments, e.g. from ConnConf.QueryComment
+const leadingComments = `(?:\s*/\*.*?\*/)*`
+
+var isInsertSQL = regexp.MustCompile(`(?is)^` + leadingComments + `\s*(INSERT|IMPORT)\b`)
+
+// Queries that are safe to resend
+var isReadOnlySQL = regexp.MustCompile(`(?is)^` + leadingComments + `\s*(SELECT|WITH|DESCRIBE)\b`)
+
+// Prefixes sql with the comment (defaulting to ConnConf.QueryComment)
+// so that it shows up alongside the SQL in Exasol's auditing and
+// statistics (e.g. EXA_DBA_AUDIT_SQL) for correlating with tracing.
+func (c *Conn) tagSQL(sql, comment string) string {
+	if comment == "" {
+		comment = c.Conf.QueryComment
+	}
+	if comment == "" {
+		return sql
+	}
+	// The comment mustn't be able to end early
+	return "/* " + strings.ReplaceAll(comment, "*/", "* /") + " */ " + sql
+}
+
+func newResult(sql string, data *execData) *Result {
+	res := &Result{}
+	if data == nil {
+		return res
+	}
+	res.NumResults = int(data.NumResults)
+	for _, r := range data.Results {
+		if r.ResultType == rowCountType {
+			res.RowsAffected += r.RowCount
+		} else if r.ResultType == resultSetType && r.ResultSet != nil {
+			res.NumRows += int64(r.ResultSet.NumRows)
+		}
+	}
+	if isInsertSQL.MatchString(sql) {
+		res.RowsInserted = res.RowsAffected
+	}
+	return res
+}
+
+// Reports the text of any warnings in the response
+func (c *Conn) warnings(sql string, res *response) []string {
+	if len(res.Warnings) == 0 {
+		return nil
+	}
+	warnings := make([]string, len(res.Warnings))
+	for i, w := range res.Warnings {
+		warnings[i] = w.Text
+		c.logFields(LogWarning, "Server warning", "sql", c.logSQL(sql), "warning", w.Text)
+		if c.Conf.OnWarning != nil {
+			c.Conf.OnWarning(sql, w.Text)
+		}
+	}
+	return warnings
+}
+
+// Closes any result sets left open by a query run via Execute
+// otherwise they'd linger on the server until the session ends.
+func (c *Conn) discardResultSets(sql string, data *execData) {
+	if data == nil {
+		return
+	}
+	for _, r := range data.Results {
+		if r.ResultType != resultSetType || r.ResultSet == nil {
+			continue
+		}
+		c.log.Warningf("Discarding %d rows returned to Execute (use the Fetch methods for queries): %s",
+			r.ResultSet.NumRows, c.logSQL(sql))
+		if r.ResultSet.ResultSetHandle != 0 {
+			c.closeResultSet(r.ResultSet.ResultSetHandle)
+		}
+	}
+}
+
+func (c *Conn) resultsToStream(rs *resultSet, stream *ResultStream, fetchBytes int) {
+	ch := stream.ch
+	defer c.inFlight.Done()
 	defer close(ch)
 
 	// If the resultset < 1000 rows and < 64MB then rs.Data is defined and rs.ResultSetHandle is not
 	// If the resultset < 1000 rows and > 64MB then both rs.Data and rs.ResultSetHandle are defined
 	// If the resultset > 1000 rows then rs.Data is not defined and rs.ResultSetHandle is
+	if rs.ResultSetHandle != 0 {
+		defer c.closeResultSet(rs.ResultSetHandle)
+	}
 	rowsRetrieved := uint64(0)
 	if rs.Data != nil && len(rs.Data) > 0 {
-		transposeToChan(ch, rs.Data)
+		if !transposeToChan(ch, rs.Data, stream.stop) {
+			return
+		}
 		rowsRetrieved = uint64(len(rs.Data[0]))
 	}
 	if rs.ResultSetHandle == 0 {
@@ -494,29 +1855,109 @@ func (c *Conn) resultsToChan(rs *resultSet, ch chan<- []interface{}) {
 	}
 
 	for rowsRetrieved < rs.NumRows {
-		fetchReq := &fetchReq{
-			Command:         "fetch",
-			ResultSetHandle: rs.ResultSetHandle,
-			StartPosition:   rowsRetrieved,
-			NumBytes:        64 * 1024 * 1024, // Max allowed
-		}
-		fetchRes := &fetchRes{}
-		err := c.send(fetchReq, fetchRes)
+		fetched, err := c.fetchRows(rs.ResultSetHandle, rowsRetrieved, fetchBytes)
 		if err != nil {
-			// Panic because this routine is async so no good
-			// way to tell the caller that something bad happened
-			panic(err)
+			stream.err = err
+			return
+		}
+		rowsRetrieved += fetched.NumRows
+		if !transposeToChan(ch, fetched.Data, stream.stop) {
+			return
 		}
-		rowsRetrieved += fetchRes.ResponseData.NumRows
-		transposeToChan(ch, fetchRes.ResponseData.Data)
 	}
+}
+
+// Fetches the (column-major) rows of the open result set from the
+// 0-based start row, up to numBytes worth of them.
+func (c *Conn) fetchRows(handle int, start uint64, numBytes int) (*fetchData, error) {
+	fetchReq := &fetchReq{
+		Command:         "fetch",
+		ResultSetHandle: handle,
+		StartPosition:   start,
+		NumBytes:        numBytes,
+	}
+	fetchRes := &fetchRes{}
+	err := c.send(fetchReq, fetchRes)
+	if err != nil {
+		return nil, c.errorf("Unable to fetch results: %s", err)
+	}
+	fetched := fetchRes.ResponseData
+	if fetched.NumRows == 0 {
+		// Otherwise callers looping until they have all the rows would loop forever
+		return nil, c.errorf("Unable to fetch results: %w", errEmptyFetch)
+	}
+	// Callers advance by NumRows so it must match the rows actually sent
+	for _, col := range fetched.Data {
+		if uint64(len(col)) != fetched.NumRows {
+			return nil, c.errorf("Unable to fetch results: %w: numRows is %d but a column has %d rows",
+				ErrProtocol, fetched.NumRows, len(col))
+		}
+	}
+	return fetched, nil
+}
 
+const defaultDisconnectTimeout = 10 * time.Second
+
+const defaultConnectBackoff = time.Second
+
+const defaultFetchChanSize = 1000
+const defaultStreamChanSize = 1
+
+func chanSize(size, defaultSize int) int {
+	if size <= 0 {
+		return defaultSize
+	}
+	return size
+}
+
+// How long to wait on closing a result set if there's no read timeout
+const closeResultSetTimeout = 10 * time.Second
+
+// How much longer than the server's QueryTimeout we wait by default
+const defaultReadTimeoutGrace = 5 * time.Second
+
+// Returns how long to wait for the server to respond (0 for no limit).
+// Without a ReadTimeout this allows for the server to respond to the
+// QueryTimeout with its cancellation before we give up.
+func (c *Conn) readTimeout() time.Duration { return c.Conf.readTimeout() }
+
+func (conf ConnConf) readTimeout() time.Duration {
+	if conf.ReadTimeout > 0 {
+		return conf.ReadTimeout
+	}
+	if conf.QueryTimeout > 0 {
+		return conf.QueryTimeout + defaultReadTimeoutGrace
+	}
+	return 0
+}
+
+func columnTypes(cols []column) []DataType {
+	types := make([]DataType, len(cols))
+	for i, col := range cols {
+		types[i] = col.DataType
+	}
+	return types
+}
+
+func (c *Conn) closeResultSet(handle int) {
 	closeRSReq := &closeResultSet{
 		Command:          "closeResultSet",
-		ResultSetHandles: []int{rs.ResultSetHandle},
+		ResultSetHandles: []int{handle},
 	}
-	err := c.send(closeRSReq, &response{})
-	if err != nil {
-		c.log.Warning("Unable to close result set:", err)
+	timeout := closeResultSetTimeout
+	if t := c.readTimeout(); t > 0 {
+		timeout = t
+	}
+
+	// Don't hang around forever if the connection is wedged
+	done := make(chan error, 1)
+	go func() { done <- c.send(closeRSReq, &response{}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			c.log.Warning("Unable to close result set:", err)
+		}
+	case <-time.After(timeout):
+		c.log.Warning("Timed out closing result set:", handle)
 	}
 }
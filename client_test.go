@@ -1,17 +1,36 @@
 package exasol
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test various connection options:
@@ -32,6 +51,53 @@ func (s *testSuite) TestConnClientName() {
 	c.Disconnect()
 }
 
+func (s *testSuite) TestConnNLS() {
+	conf := s.connConf()
+	conf.NumericCharacters = ",."
+	conf.DateFormat = "DD.MM.YYYY"
+	conf.TimestampFormat = "DD.MM.YYYY HH24:MI:SS"
+	conf.FirstDayOfWeek = 1
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	attrs, err := c.GetAttributes()
+	if s.NoError(err) {
+		s.Equal(",.", *attrs.NumericCharacters)
+		s.Equal("DD.MM.YYYY", *attrs.DateFormat)
+		s.Equal("DD.MM.YYYY HH24:MI:SS", *attrs.DatetimeFormat)
+	}
+	got, _ := c.FetchSlice(`
+		SELECT TO_CHAR(1234.5, '9G999D9'), TO_CHAR(DATE '2020-01-02'),
+			SESSION_PARAMETER(CURRENT_SESSION, 'NLS_FIRST_DAY_OF_WEEK')
+	`)
+	s.Equal([][]interface{}{{" 1.234,5", "02.01.2020", "1"}}, got)
+
+	conf.FirstDayOfWeek = 8
+	conf.SuppressError = true
+	_, err = Connect(conf)
+	s.EqualError(err, "Invalid ConnConf: FirstDayOfWeek must be 1 to 7 not 8")
+}
+
+func (s *testSuite) TestConnectWith() {
+	addr := net.JoinHostPort(*testHost, fmt.Sprint(*testPort))
+	netConn, err := net.Dial("tcp", addr)
+	s.Require().NoError(err)
+
+	conf := s.connConf()
+	conf.Host = "tunnelled" // Only used for the Host header
+	c, err := ConnectWith(conf, netConn)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	got, err := c.QueryScalar("SELECT 1")
+	if s.NoError(err) {
+		s.Equal(float64(1), got)
+	}
+
+	c.Conf.SuppressError = true
+	s.EqualError(c.Reconnect(), "Unable to Reconnect a connection made with ConnectWith")
+}
+
 func (s *testSuite) TestQueryTimeout() {
 	conf := s.connConf()
 	conf.SuppressError = true
@@ -63,6 +129,295 @@ func (s *testSuite) TestQueryTimeout() {
 	// No need to disconnect because the server killed the connection
 }
 
+func (s *testSuite) TestAbort() {
+	conf := s.connConf()
+	conf.SuppressError = true
+	c, err := Connect(conf)
+	s.Nil(err, "No connection errors")
+	defer c.Disconnect()
+	c.Execute("OPEN SCHEMA " + s.qschema)
+	c.Execute(`
+		CREATE SCRIPT sleep(sec) AS
+		local ntime = os.time() + sec
+		repeat until os.time() > ntime
+		exit({rows_affected=123})
+	`)
+
+	go func() {
+		time.Sleep(time.Second)
+		c.Abort()
+	}()
+	timeIn := time.Now()
+	got, err := c.Execute(`EXECUTE SCRIPT sleep(10)`)
+	s.ErrorIs(err, ErrQueryAborted, "Got aborted")
+	s.Equal(int64(0), got)
+	s.Less(time.Since(timeIn).Seconds(), float64(5), "Didn't wait for the script")
+
+	res, err := c.FetchSlice("SELECT 123")
+	if s.NoError(err, "Still usable after aborting") {
+		s.Equal(float64(123), res[0][0])
+	}
+}
+
+func (s *testSuite) TestConcurrentQueries() {
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := s.exaConn.FetchSlice(fmt.Sprintf("SELECT %d", i))
+			if err == nil && got[0][0] != float64(i) {
+				err = fmt.Errorf("Query %d got another query's response: %v", i, got)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		s.NoError(err)
+	}
+}
+
+func (s *testSuite) TestUseSchema() {
+	s.execute("CREATE TABLE foo ( id INT )")
+	s.execute("INSERT INTO foo VALUES (1)")
+	conf := s.connConf()
+	conf.SuppressError = true
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	_, err = c.FetchSlice("SELECT * FROM foo")
+	s.Error(err, "No schema open")
+	s.Error(c.UseSchema(""))
+
+	if s.NoError(c.UseSchema(s.schema)) {
+		got, err := c.FetchSlice("SELECT * FROM foo")
+		if s.NoError(err) {
+			s.Equal([][]interface{}{{float64(1)}}, got)
+		}
+	}
+
+	conf.DefaultSchema = s.schema
+	c2, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c2.Disconnect()
+	got, err := c2.FetchSlice("SELECT * FROM foo")
+	if s.NoError(err, "DefaultSchema opened at login") {
+		s.Equal([][]interface{}{{float64(1)}}, got)
+	}
+}
+
+func (s *testSuite) TestDisconnect() {
+	c, err := Connect(s.connConf())
+	s.Nil(err, "No connection errors")
+	c.Conf.SuppressError = true
+
+	stream, err := c.FetchStream("SELECT level FROM dual CONNECT BY level <= 1e5")
+	s.Require().NoError(err)
+	done := make(chan bool)
+	go func() {
+		c.Disconnect()
+		close(done)
+	}()
+	numRows := 0
+	for _, ok := stream.Next(); ok; _, ok = stream.Next() {
+		numRows++
+	}
+	<-done
+	s.NoError(stream.Err(), "Stream wasn't cut off")
+	s.Equal(100000, numRows, "Streamed all rows before disconnecting")
+
+	_, err = c.Execute("SELECT 1")
+	s.ErrorIs(err, ErrConnClosed, "Unusable after disconnecting")
+	c.Disconnect() // Should be a no-op
+}
+
+func (s *testSuite) TestPing() {
+	c, err := Connect(s.connConf())
+	s.Require().NoError(err)
+	s.NoError(c.Ping())
+
+	c.Conf.SuppressError = true
+	c.Disconnect()
+	s.ErrorIs(c.Ping(), ErrConnClosed)
+}
+
+// Counts the keepalive pings passing through the default handler
+type pingCountingWSHandler struct {
+	WSHandler
+	pings int32
+}
+
+func (wsh *pingCountingWSHandler) WriteJSON(req interface{}) error {
+	if r, ok := req.(*sessionAttrReq); ok && r.Command == "getAttributes" {
+		atomic.AddInt32(&wsh.pings, 1)
+	}
+	return wsh.WSHandler.WriteJSON(req)
+}
+
+func (s *testSuite) TestKeepalive() {
+	conf := s.connConf()
+	conf.Keepalive = 50 * time.Millisecond
+	wsh := &pingCountingWSHandler{WSHandler: newDefaultWSHandler()}
+	conf.WSHandler = wsh
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+
+	// Queries interleave with the pings without issue
+	for i := 0; i < 10; i++ {
+		got, err := c.FetchSlice(fmt.Sprintf("SELECT %d", i))
+		if s.NoError(err) {
+			s.Equal(float64(i), got[0][0])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.GreaterOrEqual(atomic.LoadInt32(&wsh.pings), int32(2), "Pinged while idle")
+
+	c.Disconnect()
+	pings := atomic.LoadInt32(&wsh.pings)
+	time.Sleep(150 * time.Millisecond)
+	s.Equal(pings, atomic.LoadInt32(&wsh.pings), "Stopped on Disconnect")
+}
+
+// Forwards TCP traffic to Exasol until paused, after which the
+// connection goes silent like a dead peer's
+type pausableForwarder struct {
+	net.Listener
+	paused int32
+}
+
+func newPausableForwarder(target string) (*pausableForwarder, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	f := &pausableForwarder{Listener: l}
+	go func() {
+		for {
+			src, err := l.Accept()
+			if err != nil {
+				return
+			}
+			dst, err := net.Dial("tcp", target)
+			if err != nil {
+				src.Close()
+				continue
+			}
+			go f.copy(dst, src)
+			go f.copy(src, dst)
+		}
+	}()
+	return f, nil
+}
+
+func (f *pausableForwarder) copy(dst, src net.Conn) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if err != nil {
+			dst.Close()
+			return
+		}
+		if atomic.LoadInt32(&f.paused) == 0 {
+			dst.Write(buf[:n])
+		}
+	}
+}
+
+func (s *testSuite) TestPingInterval() {
+	f, err := newPausableForwarder(fmt.Sprintf("%s:%d", *testHost, *testPort))
+	s.Require().NoError(err)
+	defer f.Close()
+
+	conf := s.connConf()
+	conf.SuppressError = true
+	conf.PingInterval = 100 * time.Millisecond
+	addr := f.Addr().(*net.TCPAddr)
+	conf.Host = addr.IP.String()
+	conf.Port = uint16(addr.Port)
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	// Pongs keep the connection alive during slow queries
+	_, err = c.Execute("SELECT COUNT(*) FROM (SELECT level FROM dual CONNECT BY level <= 1e7)")
+	s.NoError(err)
+	time.Sleep(300 * time.Millisecond) // Idle for longer than the pong wait
+	_, err = c.Execute("SELECT 1")
+	s.NoError(err, "Idling doesn't trip the deadline")
+
+	atomic.StoreInt32(&f.paused, 1)
+	timeIn := time.Now()
+	_, err = c.Execute("SELECT 1")
+	if s.Error(err) {
+		s.Contains(err.Error(), "no pong received")
+	}
+	s.Less(time.Since(timeIn).Seconds(), float64(2), "Detected the dead peer promptly")
+}
+
+// Fails the next execute as if the session had been lost in a failover
+type sessionLossWSHandler struct {
+	WSHandler
+	lose int32
+}
+
+func (wsh *sessionLossWSHandler) ReadJSON(resp interface{}) error {
+	err := wsh.WSHandler.ReadJSON(resp)
+	if res, ok := resp.(*execRes); ok && err == nil && atomic.CompareAndSwapInt32(&wsh.lose, 1, 0) {
+		res.Status = "error"
+		res.Exception = &exception{Text: "Connection exception - session not found"}
+	}
+	return err
+}
+
+func (s *testSuite) TestAutoReconnect() {
+	conf := s.connConf()
+	conf.SuppressError = true
+	wsh := &sessionLossWSHandler{WSHandler: newDefaultWSHandler()}
+	conf.WSHandler = wsh
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	atomic.StoreInt32(&wsh.lose, 1)
+	_, err = c.FetchSlice("SELECT 1")
+	if s.Error(err, "Fatal by default") {
+		s.Contains(err.Error(), "session not found")
+	}
+
+	c.Conf.AutoReconnect = true
+	sessionID := c.SessionID
+	atomic.StoreInt32(&wsh.lose, 1)
+	got, err := c.FetchSlice("SELECT 123")
+	if s.NoError(err, "Retried after reconnecting") {
+		s.Equal(float64(123), got[0][0])
+	}
+	s.NotEqual(sessionID, c.SessionID, "New session")
+
+	// Prepared statements are re-prepared in the new session
+	c.Conf.CachePrepStmts = true
+	_, err = c.FetchSlice("SELECT ?", []interface{}{1})
+	s.NoError(err)
+	atomic.StoreInt32(&wsh.lose, 1)
+	got, err = c.FetchSlice("SELECT ?", []interface{}{2})
+	if s.NoError(err) {
+		s.Equal(float64(2), got[0][0])
+	}
+}
+
+func (s *testSuite) TestReconnect() {
+	c, err := Connect(s.connConf())
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	sessionID := c.SessionID
+	s.NoError(c.Reconnect())
+	s.NotEqual(sessionID, c.SessionID)
+	s.NoError(c.Ping())
+}
+
 func (s *testSuite) TestConnectTimeout() {
 	conf := s.connConf()
 	conf.SuppressError = true
@@ -87,6 +442,38 @@ func (s *testSuite) TestConnectTimeout() {
 	s.Greater(time.Since(timeIn).Seconds(), conf.ConnectTimeout.Seconds()-1, "It did hang")
 }
 
+func (s *testSuite) TestConnDialer() {
+	conf := s.connConf()
+	var dialed int32
+	conf.Dialer = &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			atomic.AddInt32(&dialed, 1)
+			return nil
+		},
+	}
+	c, err := Connect(conf)
+	if s.NoError(err) {
+		s.Equal(int32(1), atomic.LoadInt32(&dialed), "Used the custom dialer")
+		c.Disconnect()
+	}
+
+	// Nothing's listening on port 1
+	conf = s.connConf()
+	conf.SuppressError = true
+	conf.HTTPProxy = &url.URL{Scheme: "http", Host: "127.0.0.1:1"}
+	_, err = Connect(conf)
+	if s.Error(err, "Went via the proxy") {
+		s.Contains(err.Error(), "127.0.0.1:1")
+	}
+
+	conf.HTTPProxy = nil
+	conf.IgnoreProxyEnv = true
+	c, err = Connect(conf)
+	if s.NoError(err) {
+		c.Disconnect()
+	}
+}
+
 func (s *testSuite) TestConnSuppressError() {
 	conf := s.connConf()
 	output := &bytes.Buffer{}
@@ -164,6 +551,111 @@ func (s *testSuite) TestConnLogger() {
 	c.Disconnect()
 }
 
+type testLevelLogger struct {
+	levels  []LogLevel
+	msgs    []string
+	keyvals [][]interface{}
+}
+
+func (l *testLevelLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.levels = append(l.levels, level)
+	l.msgs = append(l.msgs, msg)
+	l.keyvals = append(l.keyvals, keyvals)
+}
+
+func (s *testSuite) TestLevelLogger() {
+	conf := s.connConf()
+	conf.Logger = nil
+	logger := &testLevelLogger{}
+	conf.LevelLogger = logger
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	logger.msgs = nil
+	logger.keyvals = nil
+	logger.levels = nil
+	_, err = c.FetchSlice("SELECT 1")
+	s.NoError(err)
+
+	found := false
+	for i, msg := range logger.msgs {
+		if msg != "Query completed" {
+			continue
+		}
+		found = true
+		s.Equal(LogDebug, logger.levels[i])
+		fields := map[interface{}]interface{}{}
+		kv := logger.keyvals[i]
+		for j := 0; j+1 < len(kv); j += 2 {
+			fields[kv[j]] = kv[j+1]
+		}
+		s.Equal(c.SessionID, fields["session_id"])
+		s.Equal("SELECT 1", fields["sql"])
+		s.IsType(time.Duration(0), fields["duration"])
+		s.Equal(int64(1), fields["rows"])
+	}
+	s.True(found, "Logged the query with fields")
+	s.Equal("warning", LogWarning.String())
+}
+
+func (s *testSuite) TestLogSQL() {
+	c := &Conn{}
+	s.Equal(`CREATE USER bob IDENTIFIED BY ***`,
+		c.logSQL(`CREATE USER bob IDENTIFIED BY "s3cr3t pass"`), "Passwords are always masked")
+	s.Equal(`SELECT * FROM t WHERE name = 'bob' AND id = 12`,
+		c.logSQL(`SELECT * FROM t WHERE name = 'bob' AND id = 12`), "Not redacted by default")
+
+	c.Conf.RedactBinds = true
+	s.Equal(`SELECT * FROM t1 WHERE name = ? AND id = ? AND x = ?`,
+		c.logSQL(`SELECT * FROM t1 WHERE name = 'o''bob' AND id = 12 AND x = 1.5`))
+
+	c.Conf.LogSQLMaxLen = 10
+	s.Equal(`SELECT * F...(21 more bytes)`, c.logSQL(`SELECT * FROM t1 WHERE name = 'bob'`))
+
+	c.Conf = ConnConf{RedactSQL: strings.ToLower}
+	s.Equal(`insert into t values ('x') identified by ***`,
+		c.logSQL(`INSERT INTO t VALUES ('X') IDENTIFIED BY pass`))
+}
+
+type testMetrics struct {
+	queries []string
+	rows    []int64
+	errors  []error
+}
+
+func (m *testMetrics) OnQuery(sql string, d time.Duration, rows int64) {
+	m.queries = append(m.queries, sql)
+	m.rows = append(m.rows, rows)
+}
+func (m *testMetrics) OnError(err error) { m.errors = append(m.errors, err) }
+func (m *testMetrics) OnReconnect()      {}
+
+func (s *testSuite) TestConnMetrics() {
+	conf := s.connConf()
+	conf.SuppressError = true
+	metrics := &testMetrics{}
+	conf.Metrics = metrics
+	c, err := Connect(conf)
+	s.Nil(err, "No connection errors")
+	defer c.Disconnect()
+
+	c.Execute("CREATE TABLE " + s.qschema + ".foo ( id INT )")
+	c.Execute("INSERT INTO "+s.qschema+".foo VALUES (?)", [][]interface{}{{1}, {2}})
+	c.FetchSlice("SELECT * FROM " + s.qschema + ".foo")
+	c.Execute("ASDF")
+
+	s.Equal([]string{
+		"CREATE TABLE " + s.qschema + ".foo ( id INT )",
+		"INSERT INTO " + s.qschema + ".foo VALUES (?)",
+		"SELECT * FROM " + s.qschema + ".foo",
+	}, metrics.queries, "Queries recorded")
+	s.Equal([]int64{0, 2, 2}, metrics.rows, "Row counts recorded")
+	if s.Len(metrics.errors, 1, "Error recorded") {
+		s.Contains(metrics.errors[0].Error(), "syntax error")
+	}
+}
+
 func (s *testSuite) TestConnCachePrepStmt() {
 	conf := s.connConf()
 
@@ -173,8 +665,8 @@ func (s *testSuite) TestConnCachePrepStmt() {
 
 	got, _ := c.FetchSlice("SELECT 123 FROM dual WHERE true = ?", []interface{}{true})
 	s.Equal(got[0][0].(float64), float64(123), "Everything OK")
-	s.Equal(c.Stats["StmtCacheLen"], 0, "Cache is empty")
-	s.Equal(c.Stats["StmtCacheMiss"], 0, "Cache miss not recorded")
+	s.Equal(c.Snapshot()["StmtCacheLen"], 0, "Cache is empty")
+	s.Equal(c.Snapshot()["StmtCacheMiss"], 0, "Cache miss not recorded")
 
 	c.Disconnect()
 
@@ -184,17 +676,42 @@ func (s *testSuite) TestConnCachePrepStmt() {
 
 	got, _ = c.FetchSlice("SELECT 123 FROM dual WHERE true = ?", []interface{}{true})
 	s.Equal(got[0][0].(float64), float64(123), "Everything OK")
-	s.Equal(c.Stats["StmtCacheLen"], 1, "Cache is not empty")
-	s.Equal(c.Stats["StmtCacheMiss"], 1, "Cache miss recorded")
+	s.Equal(c.Snapshot()["StmtCacheLen"], 1, "Cache is not empty")
+	s.Equal(c.Snapshot()["StmtCacheMiss"], 1, "Cache miss recorded")
 
 	got, _ = c.FetchSlice("SELECT 123 FROM dual WHERE true = ?", []interface{}{true})
 	s.Equal(got[0][0].(float64), float64(123), "Everything OK")
-	s.Equal(c.Stats["StmtCacheLen"], 1, "Cache is not empty")
-	s.Equal(c.Stats["StmtCacheMiss"], 1, "Cache miss not recorded")
+	s.Equal(c.Snapshot()["StmtCacheLen"], 1, "Cache is not empty")
+	s.Equal(c.Snapshot()["StmtCacheMiss"], 1, "Cache miss not recorded")
 
 	c.Disconnect()
 }
 
+func (s *testSuite) TestClearPreparedStatements() {
+	conf := s.connConf()
+	conf.CachePrepStmts = true
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	s.Equal(0, c.PreparedStatementCount())
+	c.FetchSlice("SELECT 1 FROM dual WHERE true = ?", []interface{}{true})
+	c.FetchSlice("SELECT 2 FROM dual WHERE true = ?", []interface{}{true})
+	c.FetchSlice("SELECT 1 FROM dual WHERE true = ?", []interface{}{true})
+	s.Equal(2, c.PreparedStatementCount())
+
+	c.ClearPreparedStatements()
+	s.Equal(0, c.PreparedStatementCount())
+	s.Equal(0, c.Snapshot()["StmtCacheLen"])
+
+	got, err := c.FetchSlice("SELECT 1 FROM dual WHERE true = ?", []interface{}{true})
+	if s.NoError(err, "Re-prepared") {
+		s.Equal([][]interface{}{{float64(1)}}, got)
+	}
+	s.Equal(1, c.PreparedStatementCount())
+	s.Equal(3, c.Snapshot()["StmtCacheMiss"])
+}
+
 func (s *testSuite) TestConnEncryption() {
 	conf := s.connConf()
 
@@ -285,6 +802,110 @@ func (s *testSuite) TestAutoCommit() {
 	s.Equal(true, got.Autocommit, "Autocommit still enabled")
 }
 
+func (s *testSuite) TestExecConfAutoCommit() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id INT )")
+	c, err := Connect(s.connConf())
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	countFoo := func() interface{} {
+		got, _ := c.QueryScalar("SELECT COUNT(*) FROM [test].foo")
+		return got
+	}
+
+	exa.DisableAutoCommit()
+	defer exa.EnableAutoCommit()
+	_, err = exa.Execute("INSERT INTO foo VALUES (1)")
+	s.NoError(err)
+	s.Equal(float64(0), countFoo(), "Not committed")
+
+	on := true
+	_, err = exa.Execute("INSERT INTO foo VALUES (2)", ExecConf{AutoCommit: &on})
+	s.NoError(err)
+	s.Equal(float64(2), countFoo(), "Committed")
+	attrs, _ := exa.GetAttributes()
+	s.False(*attrs.Autocommit, "Restored")
+
+	exa.EnableAutoCommit()
+	off := false
+	_, err = exa.Execute("INSERT INTO foo VALUES (3)", ExecConf{AutoCommit: &off})
+	s.NoError(err)
+	s.Equal(float64(2), countFoo(), "Not committed")
+	attrs, _ = exa.GetAttributes()
+	s.True(*attrs.Autocommit, "Restored")
+}
+
+func TestGetAttributesNone(t *testing.T) {
+	c := newFakeConn(t, ConnConf{}, &fakeWSHandler{}) // Responds without any
+	defer c.Disconnect()
+	attrs, err := c.GetAttributes()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &SessionAttributes{}, attrs)
+	}
+}
+
+func TestExecConfAutoCommitUnknown(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"getAttributes": `{"status":"ok","attributes":{}}`,
+	}}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+
+	on := true
+	_, err := c.Execute("INSERT INTO foo VALUES (1)", ExecConf{AutoCommit: &on})
+	assert.NoError(t, err)
+	var sets []interface{}
+	for _, r := range fake.requests {
+		if r["command"] == "setAttributes" {
+			sets = append(sets, r["attributes"])
+		}
+	}
+	assert.Equal(t, []interface{}{map[string]interface{}{"autocommit": true}}, sets,
+		"Not restored to a guess")
+}
+
+func (s *testSuite) TestSessionAttributes() {
+	c, err := Connect(s.connConf())
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	got, err := c.GetAttributes()
+	if s.NoError(err) && s.NotNil(got.Autocommit) {
+		s.True(*got.Autocommit, "Autocommit defaults to true")
+		s.NotNil(got.NumericCharacters)
+	}
+
+	autocommit := false
+	timeout := uint32(0)
+	dateFormat := "DD.MM.YYYY"
+	err = c.SetAttributes(&SessionAttributes{
+		Autocommit:   &autocommit,
+		QueryTimeout: &timeout,
+		DateFormat:   &dateFormat,
+	})
+	s.NoError(err)
+	got, err = c.GetAttributes()
+	if s.NoError(err) && s.NotNil(got.Autocommit) && s.NotNil(got.DateFormat) {
+		s.False(*got.Autocommit, "Autocommit disabled")
+		s.Equal(dateFormat, *got.DateFormat)
+	}
+	res, _ := c.FetchSlice("SELECT TO_CHAR(DATE '2020-01-31')")
+	s.Equal([][]interface{}{{"31.01.2020"}}, res)
+
+	autocommit = true
+	s.NoError(c.SetAttributes(&SessionAttributes{Autocommit: &autocommit}))
+	got, _ = c.GetAttributes()
+	s.Equal(true, *got.Autocommit, "Autocommit re-enabled")
+	s.Equal(dateFormat, *got.DateFormat, "Other attributes left alone")
+
+	s.NoError(c.SetAttribute("feedbackInterval", 10))
+	s.NoError(c.SetAttribute("snapshotTransactionsEnabled", false))
+	got, err = c.GetAttributes()
+	if s.NoError(err) && s.NotNil(got.FeedbackInterval) {
+		s.Equal(uint32(10), *got.FeedbackInterval)
+	}
+}
+
 func (s *testSuite) TestCommitAndRollback() {
 	exa := s.exaConn
 	exa.DisableAutoCommit()
@@ -316,6 +937,15 @@ func (s *testSuite) TestSessionID() {
 	s.Equal(sesh[0][0].(string), fmt.Sprintf("%d", exa.Metadata.SessionID), "SessionID in metadata is correct")
 }
 
+func TestSessionIDPrecision(t *testing.T) {
+	// Make sure large session IDs aren't mangled via float64
+	res := &authResp{}
+	err := json.Unmarshal([]byte(`{"status":"ok","responseData":{"sessionId":12345678901234567890}}`), res)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(12345678901234567890), res.ResponseData.SessionID)
+	}
+}
+
 func (s *testSuite) TestExecute() {
 	exa := s.exaConn
 	exa.Conf.SuppressError = true
@@ -386,78 +1016,442 @@ func (s *testSuite) TestExecute() {
 	s.Equal(int64(3), got)
 }
 
-func (s *testSuite) TestFetchChan() {
+func (s *testSuite) TestExecuteResult() {
 	exa := s.exaConn
 	exa.Conf.SuppressError = true
 	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
-	exa.Execute(
-		"INSERT INTO foo VALUES (?,?)",
-		[][]interface{}{{1, 2, 3}, {"a", "b", "c"}},
-		nil, nil, true,
-	)
 
-	// First an error
-	got, err := exa.FetchChan("ASDF")
+	// Generate an error
+	got, err := exa.ExecuteResult("ASDF")
 	if s.Error(err) {
 		s.Contains(err.Error(), "syntax error")
 	}
 	s.Nil(got)
 
-	// Successful, no binds
-	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < 3 ORDER BY id")
+	got, err = exa.ExecuteResult("INSERT INTO foo VALUES (?,?)", [][]interface{}{{1, "a"}, {2, "b"}})
 	if s.NoError(err) {
-		var res [][]interface{}
-		for row := range got {
-			res = append(res, row)
-		}
-		expect := [][]interface{}{
-			{float64(1), "a"},
-			{float64(2), "b"},
-		}
-		s.Equal(expect, res)
+		s.Greater(int64(got.Duration), int64(0), "Timed")
+		got.Duration = 0
+		s.Equal(&Result{NumResults: 1, RowsAffected: 2, RowsInserted: 2}, got)
 	}
 
-	// Successful, with binds
-	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < ? ORDER BY id", []interface{}{3})
+	got, err = exa.ExecuteResult("UPDATE foo SET val = 'c'")
 	if s.NoError(err) {
-		var res [][]interface{}
-		for row := range got {
-			res = append(res, row)
-		}
-		expect := [][]interface{}{
-			{float64(1), "a"},
-			{float64(2), "b"},
-		}
-		s.Equal(expect, res)
+		got.Duration = 0
+		s.Equal(&Result{NumResults: 1, RowsAffected: 2, RowsInserted: 0}, got)
 	}
+}
 
-	// Successful, with schema
-	_, err = exa.Execute("OPEN SCHEMA sys")
-	s.Nil(err)
-	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < ? ORDER BY id", []interface{}{3}, s.schema)
+func (s *testSuite) TestExecuteQuery() {
+	conf := s.connConf()
+	output := &bytes.Buffer{}
+	logger := customTestLogger("warning")
+	logger.SetOutput(output)
+	conf.Logger = logger
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	// Large enough to get a result set handle
+	got, err := c.ExecuteResult("SELECT level FROM dual CONNECT BY level <= 5000")
 	if s.NoError(err) {
-		var res [][]interface{}
-		for row := range got {
-			res = append(res, row)
-		}
-		expect := [][]interface{}{
-			{float64(1), "a"},
-			{float64(2), "b"},
-		}
-		s.Equal(expect, res)
+		s.Equal(int64(5000), got.NumRows)
+		s.Equal(int64(0), got.RowsAffected)
+	}
+	s.Contains(output.String(), "Discarding 5000 rows", "Warned")
+
+	rows, err := c.Execute("SELECT 1")
+	s.NoError(err)
+	s.Equal(int64(0), rows)
+	res, err := c.FetchSlice("SELECT 123")
+	if s.NoError(err, "Still usable") {
+		s.Equal(float64(123), res[0][0])
 	}
 }
 
-func (s *testSuite) TestFetchSlice() {
-	exa := s.exaConn
-	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
-	exa.Execute(
-		"INSERT INTO foo VALUES (?,?)",
-		[][]interface{}{{1, 2, 3}, {"a", "b", "c"}},
-		nil, nil, true,
-	)
+// Adds a warning to every execute response
+type warningWSHandler struct {
+	WSHandler
+}
 
-	// First an error
+func (wsh *warningWSHandler) ReadJSON(resp interface{}) error {
+	err := wsh.WSHandler.ReadJSON(resp)
+	if res, ok := resp.(*execRes); ok && err == nil {
+		res.Warnings = append(res.Warnings, exception{Text: "123 rows rejected"})
+	}
+	return err
+}
+
+func (s *testSuite) TestWarnings() {
+	conf := s.connConf()
+	conf.WSHandler = &warningWSHandler{WSHandler: newDefaultWSHandler()}
+	var warned []string
+	conf.OnWarning = func(sql, warning string) {
+		warned = append(warned, sql+": "+warning)
+	}
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	got, err := c.ExecuteResult("CREATE TABLE [test].foo ( id INT )")
+	if s.NoError(err, "Warnings aren't errors") {
+		s.Equal([]string{"123 rows rejected"}, got.Warnings)
+	}
+	s.Equal([]string{"CREATE TABLE [test].foo ( id INT ): 123 rows rejected"}, warned)
+
+	warned = nil
+	_, err = c.BulkInsert(s.qschema, "FOO", bytes.NewBufferString("1\n"))
+	s.NoError(err)
+	s.Len(warned, 1, "Reported for imports")
+
+	got, err = s.exaConn.ExecuteResult("INSERT INTO foo VALUES (2)")
+	if s.NoError(err) {
+		s.Nil(got.Warnings, "None by default")
+	}
+}
+
+func (s *testSuite) TestExecuteBatch() {
+	exa := s.exaConn
+	exa.Conf.SuppressError = true
+
+	got, err := exa.ExecuteBatch([]string{
+		"CREATE TABLE foo ( id INT )",
+		"INSERT INTO foo VALUES (1),(2)",
+		"UPDATE foo SET id = id + 1 WHERE id = 1",
+	}, s.schema)
+	if s.NoError(err) {
+		s.Greater(int64(got[0].Duration), int64(0), "Timed")
+		for _, res := range got {
+			s.Equal(got[0].Duration, res.Duration, "The whole batch's")
+			res.Duration = 0
+		}
+		s.Equal([]*Result{
+			{NumResults: 1, RowsAffected: 0},
+			{NumResults: 1, RowsAffected: 2, RowsInserted: 2},
+			{NumResults: 1, RowsAffected: 1},
+		}, got)
+	}
+
+	got, err = exa.ExecuteBatch([]string{
+		"INSERT INTO foo VALUES (3)",
+		"ASDF",
+	}, s.schema)
+	s.Nil(got)
+	var batchErr *BatchError
+	if s.ErrorAs(err, &batchErr) {
+		s.Contains(batchErr.Error(), "syntax error")
+		s.Equal(-1, batchErr.Index, "Exasol doesn't say which failed")
+		s.Equal("", batchErr.SQL)
+	}
+}
+
+func (s *testSuite) TestExecuteStatements() {
+	exa := s.exaConn
+	exa.Conf.SuppressError = true
+	got, err := exa.ExecuteStatements(`
+		CREATE TABLE foo ( id INT, val VARCHAR(10) );
+		-- Semicolons in strings; and comments are fine
+		INSERT INTO foo VALUES (1, 'a;b'), (2, 'c');
+		CREATE OR REPLACE LUA SCRIPT double_it(n) RETURNS ROWCOUNT AS
+			local x = n * 2;
+			exit({rows_affected = x})
+		/
+		UPDATE foo SET val = 'x' WHERE id = 2
+	`, s.schema)
+	if s.NoError(err) && s.Len(got, 4) {
+		s.Equal(int64(2), got[1].RowsAffected)
+		s.Equal(int64(1), got[3].RowsAffected)
+	}
+	s.Equal([][]interface{}{{float64(1), "a;b"}, {float64(2), "x"}}, s.fetch("SELECT * FROM foo ORDER BY id"))
+
+	got, err = exa.ExecuteStatements("INSERT INTO foo VALUES (3, 'd'); ASDF; INSERT INTO foo VALUES (4, 'e')", s.schema)
+	s.Len(got, 1, "The results before the failure")
+	var batchErr *BatchError
+	if s.ErrorAs(err, &batchErr) {
+		s.Equal(1, batchErr.Index)
+		s.Equal("ASDF", batchErr.SQL)
+		s.Contains(batchErr.Error(), "Unable to ExecuteStatements: stmt 1 failed")
+	}
+	s.Equal([][]interface{}{{float64(3)}}, s.fetch("SELECT MAX(id) FROM foo"), "Stopped at the failure")
+}
+
+func (s *testSuite) TestNullBinds() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, s VARCHAR(10), i INT, f DOUBLE, t TIMESTAMP )")
+
+	_, err := exa.Execute("INSERT INTO foo VALUES (?,?,?,?,?)", [][]interface{}{
+		{1, nil, nil, nil, nil},
+		{2, sql.NullString{}, sql.NullInt64{}, sql.NullFloat64{}, sql.NullTime{}},
+		{3, sql.NullString{String: "a", Valid: true}, sql.NullInt64{Int64: 4, Valid: true},
+			sql.NullFloat64{Float64: 1.5, Valid: true}, nil},
+	})
+	s.Nil(err)
+
+	got, err := exa.FetchSlice("SELECT * FROM foo ORDER BY id")
+	if s.NoError(err) {
+		s.Equal([][]interface{}{
+			{float64(1), nil, nil, nil, nil},
+			{float64(2), nil, nil, nil, nil},
+			{float64(3), "a", float64(4), float64(1.5), nil},
+		}, got)
+	}
+
+	// NULL binds in a query
+	got, err = exa.FetchSlice("SELECT id FROM foo WHERE s IS NULL AND ? IS NULL ORDER BY id", []interface{}{sql.NullString{}})
+	if s.NoError(err) {
+		s.Equal([][]interface{}{{float64(1)}, {float64(2)}}, got)
+	}
+}
+
+func (s *testSuite) TestExec() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id INT, name VARCHAR(10) )")
+	s.execute("INSERT INTO foo VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+
+	n, err := exa.Exec("UPDATE foo SET name = ? WHERE id >= ?", "x", 2)
+	s.NoError(err)
+	s.Equal(int64(2), n, "Rows affected")
+	n, err = exa.Exec("DELETE FROM foo WHERE id = 1")
+	s.NoError(err)
+	s.Equal(int64(1), n, "Without binds")
+	n, err = exa.Exec("INSERT INTO foo VALUES (?, ?)", 4, nil)
+	s.NoError(err)
+	s.Equal(int64(1), n)
+	s.Equal([][]interface{}{{float64(2), "x"}, {float64(3), "x"}, {float64(4), nil}},
+		s.fetch("SELECT * FROM foo ORDER BY id"))
+}
+
+func (s *testSuite) TestBooleanBinds() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, b BOOLEAN )")
+
+	_, err := exa.Execute("INSERT INTO foo VALUES (?,?)", [][]interface{}{
+		{1, true},
+		{2, false},
+		{3, nil},
+		{4, sql.NullBool{}},
+		{5, sql.NullBool{Bool: true, Valid: true}},
+		{6, "f"},
+	})
+	s.Require().NoError(err)
+
+	expect := [][]interface{}{
+		{float64(1), true}, {float64(2), false}, {float64(3), nil},
+		{float64(4), nil}, {float64(5), true}, {float64(6), false},
+	}
+	got, err := exa.FetchSlice("SELECT * FROM foo ORDER BY id")
+	if s.NoError(err) {
+		s.Equal(expect, got)
+	}
+	stream, err := exa.FetchStream("SELECT * FROM foo ORDER BY id")
+	s.Require().NoError(err)
+	var streamed [][]interface{}
+	for row, ok := stream.Next(); ok; row, ok = stream.Next() {
+		streamed = append(streamed, row)
+	}
+	s.NoError(stream.Err())
+	s.Equal(expect, streamed, "The same via the transposing stream")
+
+	got, err = exa.FetchSlice("SELECT id FROM foo WHERE b = ? ORDER BY id", []interface{}{true})
+	if s.NoError(err) {
+		s.Equal([][]interface{}{{float64(1)}, {float64(5)}}, got)
+	}
+
+	db := sql.OpenDB(NewConnector(s.connConf()))
+	defer db.Close()
+	var nb sql.NullBool
+	s.NoError(db.QueryRow("SELECT b FROM " + s.qschema + ".foo WHERE id = 3").Scan(&nb))
+	s.False(nb.Valid, "NULL")
+	var b bool
+	s.NoError(db.QueryRow("SELECT b FROM " + s.qschema + ".foo WHERE id = 5").Scan(&b))
+	s.True(b)
+}
+
+func (s *testSuite) TestBigIntBinds() {
+	s.execute("CREATE TABLE foo ( id INT, d19 DECIMAL(19,0), d38 DECIMAL(38,0) )")
+
+	big38, _ := new(big.Int).SetString("12345678901234567890123456789012345678", 10)
+	neg38 := new(big.Int).Neg(big38)
+	_, err := s.exaConn.Execute("INSERT INTO foo VALUES (?,?,?)", [][]interface{}{
+		{1, int64(math.MaxInt64), big38},
+		{2, int64(math.MinInt64), neg38},
+		{3, uint64(math.MaxInt64), *big38},
+		{4, sql.NullInt64{Int64: math.MaxInt64, Valid: true}, nil},
+		{5, int64(1<<53 + 1), big.NewInt(1)},
+	})
+	s.Require().NoError(err)
+
+	got := s.fetch("SELECT CAST(d19 AS VARCHAR(40)), CAST(d38 AS VARCHAR(40)) FROM foo ORDER BY id")
+	s.Equal([][]interface{}{
+		{"9223372036854775807", big38.String()},
+		{"-9223372036854775808", neg38.String()},
+		{"9223372036854775807", big38.String()},
+		{"9223372036854775807", nil},
+		{"9007199254740993", "1"},
+	}, got)
+
+	// And in queries
+	got, err = s.exaConn.FetchSlice("SELECT id FROM foo WHERE d38 = ? ORDER BY id", []interface{}{big38})
+	if s.NoError(err) {
+		s.Equal([][]interface{}{{float64(1)}, {float64(3)}}, got)
+	}
+}
+
+func (s *testSuite) TestFetchChan() {
+	exa := s.exaConn
+	exa.Conf.SuppressError = true
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	exa.Execute(
+		"INSERT INTO foo VALUES (?,?)",
+		[][]interface{}{{1, 2, 3}, {"a", "b", "c"}},
+		nil, nil, true,
+	)
+
+	// First an error
+	got, err := exa.FetchChan("ASDF")
+	if s.Error(err) {
+		s.Contains(err.Error(), "syntax error")
+	}
+	s.Nil(got)
+
+	// Successful, no binds
+	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < 3 ORDER BY id")
+	if s.NoError(err) {
+		var res [][]interface{}
+		for row := range got {
+			res = append(res, row)
+		}
+		expect := [][]interface{}{
+			{float64(1), "a"},
+			{float64(2), "b"},
+		}
+		s.Equal(expect, res)
+	}
+
+	// Successful, with binds
+	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < ? ORDER BY id", []interface{}{3})
+	if s.NoError(err) {
+		var res [][]interface{}
+		for row := range got {
+			res = append(res, row)
+		}
+		expect := [][]interface{}{
+			{float64(1), "a"},
+			{float64(2), "b"},
+		}
+		s.Equal(expect, res)
+	}
+
+	// Successful, with schema
+	_, err = exa.Execute("OPEN SCHEMA sys")
+	s.Nil(err)
+	got, err = exa.FetchChan("SELECT * FROM foo WHERE id < ? ORDER BY id", []interface{}{3}, s.schema)
+	if s.NoError(err) {
+		var res [][]interface{}
+		for row := range got {
+			res = append(res, row)
+		}
+		expect := [][]interface{}{
+			{float64(1), "a"},
+			{float64(2), "b"},
+		}
+		s.Equal(expect, res)
+	}
+}
+
+func (s *testSuite) TestFetchStream() {
+	exa := s.exaConn
+	exa.Conf.SuppressError = true
+	exa.Execute("CREATE TABLE foo ( id INT )")
+	exa.Execute("INSERT INTO foo SELECT local.c FROM (SELECT row_number() over() c FROM dual CONNECT BY LEVEL <= 2500)")
+
+	// First an error
+	got, err := exa.FetchStream("ASDF")
+	if s.Error(err) {
+		s.Contains(err.Error(), "syntax error")
+	}
+	s.Nil(got)
+
+	// Large enough to require multiple fetches
+	got, err = exa.FetchStream("SELECT id FROM foo ORDER BY id")
+	if s.NoError(err) {
+		numRows := 0
+		for row, ok := got.Next(); ok; row, ok = got.Next() {
+			numRows++
+			s.Equal(float64(numRows), row[0])
+		}
+		s.Nil(got.Err())
+		s.Equal(2500, numRows)
+	}
+}
+
+func (s *testSuite) TestFetchStreamCloseEarly() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT )")
+	exa.Execute("INSERT INTO foo SELECT local.c FROM (SELECT row_number() over() c FROM dual CONNECT BY LEVEL <= 5000)")
+
+	got, err := exa.FetchStream("SELECT id FROM foo ORDER BY id")
+	if s.NoError(err) {
+		for i := 0; i < 10; i++ {
+			_, ok := got.Next()
+			s.True(ok)
+		}
+		got.CloseEarly()
+		_, ok := got.Next()
+		s.False(ok, "No more rows after closing")
+		s.Nil(got.Err())
+	}
+
+	// The connection is still usable
+	res, err := exa.FetchSlice("SELECT COUNT(*) FROM foo")
+	if s.NoError(err) {
+		s.Equal(float64(5000), res[0][0])
+	}
+}
+
+func (s *testSuite) TestFetchBytes() {
+	conf := s.connConf()
+	conf.SuppressError = true
+	conf.FetchBytes = -1
+	c, err := Connect(conf)
+	s.Nil(c)
+	if s.Error(err) {
+		s.Contains(err.Error(), "FetchBytes must be between")
+	}
+
+	conf.FetchBytes = 1024
+	c, err = Connect(conf)
+	s.Nil(err, "No connection errors")
+	defer c.Disconnect()
+
+	sql := "SELECT local.c FROM (SELECT row_number() over() c FROM dual CONNECT BY LEVEL <= 2500) ORDER BY 1"
+	got, err := c.FetchSlice(sql)
+	if s.NoError(err) {
+		s.Len(got, 2500, "Got all rows in small chunks")
+		s.Equal(float64(2500), got[2499][0])
+	}
+
+	// Per-call override
+	got, err = c.FetchSlice(sql, ExecConf{FetchBytes: maxFetchBytes})
+	if s.NoError(err) {
+		s.Len(got, 2500, "Got all rows in large chunks")
+	}
+	got, err = c.FetchSlice(sql, ExecConf{FetchBytes: maxFetchBytes + 1})
+	if s.NoError(err, "Clamped to the server's max") {
+		s.Len(got, 2500)
+	}
+}
+
+func (s *testSuite) TestFetchSlice() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	exa.Execute(
+		"INSERT INTO foo VALUES (?,?)",
+		[][]interface{}{{1, 2, 3}, {"a", "b", "c"}},
+		nil, nil, true,
+	)
+
+	// First an error
 	exa.Conf.SuppressError = true
 	got, err := exa.FetchSlice("ASDF")
 	if s.Error(err) {
@@ -482,6 +1476,262 @@ func (s *testSuite) TestFetchSlice() {
 	}
 }
 
+func (s *testSuite) TestChanSizes() {
+	conf := s.connConf()
+	conf.FetchChanSize = 5
+	conf.StreamChanSize = 3
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	stream, err := c.FetchStream("SELECT level FROM dual CONNECT BY level <= 100")
+	if s.NoError(err) {
+		s.Equal(5, cap(stream.ch))
+		numRows := 0
+		for _, ok := stream.Next(); ok; _, ok = stream.Next() {
+			numRows++
+		}
+		s.Equal(100, numRows)
+	}
+
+	rows := c.StreamQuery("EXPORT (SELECT 1 FROM dual) INTO CSV AT '%s' FILE 'data.csv'")
+	s.Equal(3, cap(rows.Data))
+	for range rows.Data {
+	}
+	s.NoError(rows.Error)
+
+	stream, err = s.exaConn.FetchStream("SELECT 1")
+	if s.NoError(err) {
+		s.Equal(1000, cap(stream.ch), "Defaults")
+		stream.CloseEarly()
+	}
+}
+
+func (s *testSuite) TestFetchSliceLimit() {
+	exa := s.exaConn
+	sql := "SELECT level FROM dual CONNECT BY level <= ?"
+
+	got, err := exa.FetchSliceLimit(sql, 3, []interface{}{3})
+	s.NoError(err, "Within the limit")
+	s.Len(got, 3)
+
+	got, err = exa.FetchSliceLimit(sql, 3, []interface{}{1e5})
+	s.ErrorIs(err, ErrRowLimit)
+	s.Equal([][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}}, got, "Truncated")
+
+	res, err := exa.FetchSlice("SELECT 123")
+	if s.NoError(err, "Still usable") {
+		s.Equal(float64(123), res[0][0])
+	}
+
+	exa.Conf.SuppressError = true
+	_, err = exa.FetchSliceLimit(sql, 0, []interface{}{1})
+	s.Error(err)
+}
+
+func (s *testSuite) TestFetchColumns() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	s.execute("INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')")
+
+	names, data, err := exa.FetchColumns("SELECT id, val FROM foo WHERE id > ? ORDER BY id", []interface{}{1})
+	if s.NoError(err) {
+		s.Equal([]string{"ID", "VAL"}, names)
+		s.Equal([][]interface{}{{float64(2), float64(3)}, {"b", "c"}}, data)
+	}
+
+	// Spanning multiple fetches
+	names, data, err = exa.FetchColumns(
+		"SELECT level AS n FROM dual CONNECT BY level <= 5000",
+		ExecConf{FetchBytes: 1000},
+	)
+	if s.NoError(err) {
+		s.Equal([]string{"N"}, names)
+		if s.Len(data, 1) && s.Len(data[0], 5000) {
+			s.Equal(float64(1), data[0][0])
+			s.Equal(float64(5000), data[0][4999])
+		}
+	}
+
+	exa.Conf.SuppressError = true
+	_, _, err = exa.FetchColumns("ASDF")
+	s.Error(err)
+}
+
+func (s *testSuite) TestQueryRow() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	exa.Execute("INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')")
+
+	got, err := exa.QueryRow("SELECT * FROM foo WHERE id >= ? ORDER BY id", []interface{}{2})
+	if s.NoError(err) {
+		s.Equal([]interface{}{float64(2), "b"}, got)
+	}
+
+	got, err = exa.QueryRow("SELECT * FROM foo WHERE FALSE")
+	s.ErrorIs(err, ErrNoRows)
+	s.Nil(got)
+
+	exa.Conf.SuppressError = true
+	got, err = exa.QueryRow("ASDF")
+	if s.Error(err) {
+		s.Contains(err.Error(), "syntax error")
+	}
+	s.Nil(got)
+}
+
+func (s *testSuite) TestQueryScalar() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	exa.Execute("INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')")
+
+	got, err := exa.QueryScalar("SELECT COUNT(*) FROM foo")
+	if s.NoError(err) {
+		s.Equal(float64(3), got)
+	}
+
+	got, err = exa.QueryScalar("SELECT val FROM foo WHERE id = ?", []interface{}{3})
+	if s.NoError(err) {
+		s.Equal("c", got)
+	}
+
+	got, err = exa.QueryScalar("SELECT val FROM foo WHERE FALSE")
+	s.ErrorIs(err, ErrNoRows)
+	s.Nil(got)
+}
+
+func (s *testSuite) TestCurrentIdentity() {
+	s.execute("CREATE TABLE foo ( id INT IDENTITY, val CHAR(1) )")
+	s.execute("CREATE TABLE bar ( id INT )")
+	s.execute("INSERT INTO foo (val) VALUES ('a')")
+	s.execute("INSERT INTO foo (val) VALUES ('b'),('c')")
+
+	got, err := s.exaConn.CurrentIdentity(s.qschema, "foo")
+	if s.NoError(err) {
+		s.Equal(int64(3), got)
+	}
+	expect := s.fetch("SELECT MAX(id) FROM foo")[0][0]
+	s.Equal(expect, float64(got))
+
+	s.exaConn.Conf.SuppressError = true
+	_, err = s.exaConn.CurrentIdentity(s.qschema, "bar")
+	s.EqualError(err, "Unable to get CurrentIdentity: [test].bar has no IDENTITY column")
+}
+
+func (s *testSuite) TestDescribeTable() {
+	s.execute(`CREATE TABLE foo (
+		id INT IDENTITY NOT NULL, name VARCHAR(100) UTF8 DEFAULT 'x' COMMENT IS 'The name'
+	)`)
+	def := "'x'"
+	got, err := s.exaConn.DescribeTable(s.qschema, "foo")
+	if s.NoError(err) {
+		s.Equal([]ColumnInfo{
+			{Name: "ID", Type: "DECIMAL(18,0)", Identity: true},
+			{Name: "NAME", Type: "VARCHAR(100) UTF8", Nullable: true, Default: &def, Comment: "The name"},
+		}, got)
+	}
+
+	s.exaConn.Conf.SuppressError = true
+	_, err = s.exaConn.DescribeTable(s.qschema, "missing")
+	s.EqualError(err, "Unable to DescribeTable: [test].missing not found")
+}
+
+func (s *testSuite) TestDescribeTypes() {
+	s.execute(`CREATE TABLE foo (
+		c_dec DECIMAL(18,2), c_dbl DOUBLE, c_vc VARCHAR(100) UTF8, c_ch CHAR(10) ASCII,
+		c_dt DATE, c_ts TIMESTAMP, c_tsl TIMESTAMP WITH LOCAL TIME ZONE, c_b BOOLEAN,
+		c_geo GEOMETRY(4326), c_iym INTERVAL YEAR(3) TO MONTH,
+		c_ids INTERVAL DAY(4) TO SECOND(2), c_h HASHTYPE(16 BYTE)
+	)`)
+	_, cols, err := s.exaConn.Describe("SELECT * FROM [test].foo")
+	s.Require().NoError(err)
+	expect := []DataType{
+		{Type: "DECIMAL", Precision: 18, Scale: 2},
+		{Type: "DOUBLE"},
+		{Type: "VARCHAR", Size: 100, CharacterSet: "UTF8"},
+		{Type: "CHAR", Size: 10, CharacterSet: "ASCII"},
+		{Type: "DATE"},
+		{Type: "TIMESTAMP"},
+		{Type: "TIMESTAMP", WithLocalTimeZone: true},
+		{Type: "BOOLEAN"},
+		{Type: "GEOMETRY", SRId: 4326},
+		{Type: "INTERVAL YEAR TO MONTH", Precision: 3},
+		{Type: "INTERVAL DAY TO SECOND", Precision: 4, Fraction: 2},
+		{Type: "HASHTYPE"},
+	}
+	if s.Len(cols, len(expect)) {
+		for i, e := range expect {
+			got := cols[i]
+			s.Equal(e.Type, got.Type)
+			s.Equal(e.Precision, got.Precision, e.Type)
+			s.Equal(e.Scale, got.Scale, e.Type)
+			s.Equal(e.CharacterSet, got.CharacterSet, e.Type)
+			s.Equal(e.WithLocalTimeZone, got.WithLocalTimeZone, e.Type)
+			s.Equal(e.Fraction, got.Fraction, e.Type)
+			s.Equal(e.SRId, got.SRId, e.Type)
+			if e.Size != 0 {
+				s.Equal(e.Size, got.Size, e.Type)
+			}
+		}
+		s.NotZero(cols[11].Size, "HASHTYPE size")
+	}
+}
+
+func (s *testSuite) TestRawCommand() {
+	exa := s.exaConn
+	res, err := exa.RawCommand("getHosts", map[string]interface{}{"hostIp": *testHost})
+	if s.NoError(err) {
+		s.Equal("ok", res["status"])
+		data, _ := res["responseData"].(map[string]interface{})
+		s.NotZero(data["numNodes"], "Got the hosts")
+	}
+
+	res, err = exa.RawCommand("getAttributes", nil)
+	if s.NoError(err) {
+		attrs, _ := res["attributes"].(map[string]interface{})
+		s.Contains(attrs, "autocommit")
+	}
+
+	exa.Conf.SuppressError = true
+	res, err = exa.RawCommand("execute", map[string]interface{}{"sqlText": "ASDF"})
+	if s.Error(err) {
+		s.Contains(err.Error(), "syntax error")
+	}
+	s.Equal("error", res["status"], "Still returns the response")
+	got := s.fetch("SELECT 1")
+	s.Equal(float64(1), got[0][0], "Still usable")
+}
+
+func (s *testSuite) TestDescribe() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id DECIMAL(18,0), val VARCHAR(10) )")
+
+	params, cols, err := exa.Describe("SELECT id, val FROM [test].foo WHERE id = ?")
+	if s.NoError(err) {
+		if s.Len(params, 1) {
+			s.Equal("DECIMAL", params[0].Type)
+			s.Equal(18, params[0].Precision)
+		}
+		if s.Len(cols, 2) {
+			s.Equal("DECIMAL", cols[0].Type)
+			s.Equal("VARCHAR", cols[1].Type)
+			s.Equal(10, cols[1].Size)
+		}
+	}
+
+	params, cols, err = exa.Describe("INSERT INTO [test].foo VALUES (?, ?)")
+	if s.NoError(err) {
+		s.Len(params, 2)
+		s.Empty(cols, "No result set")
+	}
+	got := s.fetch("SELECT COUNT(*) FROM foo")
+	s.Equal(float64(0), got[0][0], "Not executed")
+
+	exa.Conf.SuppressError = true
+	_, _, err = exa.Describe("SELECT * FROM [test].nonexistent")
+	s.Error(err)
+}
+
 func (s *testSuite) TestLargeFetch() {
 	// This results in a payload > 64MB but < 1000 rows which triggers
 	// result handles but still has data in the initial response
@@ -516,6 +1766,39 @@ func (s *testSuite) TestLargeFetch() {
 	}
 }
 
+func (s *testSuite) TestProtocolVersion() {
+	s.Equal(uint16(1), s.exaConn.ProtocolVersion(), "Defaults to 1")
+
+	conf := s.connConf()
+	conf.ProtocolVersion = 99
+	c, err := Connect(conf)
+	if s.NoError(err, "Falls back to a supported version") {
+		defer c.Disconnect()
+		v := c.ProtocolVersion()
+		s.True(v >= 1 && v < 99, "Negotiated version %d", v)
+		got, err := c.FetchSlice("SELECT 1")
+		if s.NoError(err) {
+			s.Equal([][]interface{}{{float64(1)}}, got)
+		}
+	}
+}
+
+func (s *testSuite) TestClientOsOverrides() {
+	conf := s.connConf()
+	conf.ClientOsUsername = "etl-service"
+	conf.ClientOs = "k8s"
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	got, err := c.FetchSlice(`
+		SELECT os_user, os_name FROM exa_dba_sessions WHERE session_id = CURRENT_SESSION
+	`)
+	if s.NoError(err) {
+		s.Equal([][]interface{}{{"etl-service", "k8s"}}, got)
+	}
+}
+
 func (s *testSuite) TestSetTimeout() {
 	conf := s.connConf()
 	conf.QueryTimeout = 5 * time.Second
@@ -551,3 +1834,845 @@ func (s *testSuite) TestWSHandler() {
 		s.Contains(err.Error(), "Connecting in test handler", "Got error")
 	}
 }
+
+// A fake Exasol that needs no server. Requests are JSON marshalled
+// and responses are JSON unmarshalled into the structs from api.go
+// exactly as they would be going over the wire.
+type fakeWSHandler struct {
+	key      *rsa.PrivateKey // Defaults to fakeRSAKey
+	requests []map[string]interface{}
+	next     string            // The JSON response to the last request
+	override map[string]string // Canned responses by command
+}
+
+func (f *fakeWSHandler) Connect(u url.URL, t *tls.Config, d time.Duration) error { return nil }
+func (f *fakeWSHandler) EnableCompression(e bool)                                {}
+func (f *fakeWSHandler) Close()                                                  {}
+
+func (f *fakeWSHandler) WriteJSON(req interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r := map[string]interface{}{}
+	json.Unmarshal(b, &r)
+	f.requests = append(f.requests, r)
+
+	if resp, ok := f.override[fmt.Sprint(r["command"])]; ok {
+		f.next = resp
+		return nil
+	}
+
+	switch r["command"] {
+	case "login":
+		if f.key == nil {
+			f.key = fakeRSAKey()
+		}
+		f.next = fmt.Sprintf(
+			`{"status":"ok","responseData":{"publicKeyModulus":"%x","publicKeyExponent":"%x"}}`,
+			f.key.N, f.key.E,
+		)
+	case nil: // The credentials
+		f.next = `{"status":"ok","responseData":{"sessionId":123,"protocolVersion":1}}`
+	case "execute":
+		f.next = `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"numColumns":2,"numRows":2,"numRowsInMessage":2,
+			"columns":[{"name":"ID","dataType":{"type":"DECIMAL","precision":18}},
+				{"name":"VAL","dataType":{"type":"VARCHAR","size":10}}],
+			"data":[[1,2],["a","b"]]}}]}}`
+	default:
+		f.next = `{"status":"ok"}`
+	}
+	return nil
+}
+
+func (f *fakeWSHandler) ReadJSON(resp interface{}) error {
+	return json.Unmarshal([]byte(f.next), resp)
+}
+
+var fakeKey struct {
+	once sync.Once
+	key  *rsa.PrivateKey
+}
+
+// The key the fakes log in with. It's shared as generating one is slow.
+func fakeRSAKey() *rsa.PrivateKey {
+	fakeKey.once.Do(func() {
+		var err error
+		fakeKey.key, err = rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			panic(err)
+		}
+	})
+	return fakeKey.key
+}
+
+// Connects to Exasol via wsh (e.g. a *fakeWSHandler). The Host, Port
+// and Logger default to "fake", 8563 and one logging only errors.
+func newFakeConn(t *testing.T, conf ConnConf, wsh WSHandler) *Conn {
+	t.Helper()
+	if conf.Host == "" {
+		conf.Host = "fake"
+	}
+	if conf.Port == 0 {
+		conf.Port = 8563
+	}
+	if conf.Logger == nil {
+		conf.Logger = customTestLogger("error")
+	}
+	c, err := NewConnWithTransport(conf, wsh)
+	require.NoError(t, err)
+	return c
+}
+
+func TestFakeWSHandler(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{
+		Username: "sys",
+		Password: "secret",
+	}, fake)
+	assert.Equal(t, uint64(123), c.SessionID)
+
+	// The password is sent encrypted
+	encPass, _ := base64.StdEncoding.DecodeString(fake.requests[1]["password"].(string))
+	pass, err := rsa.DecryptPKCS1v15(nil, fake.key, encPass)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "secret", string(pass))
+	}
+
+	got, err := c.FetchSlice("SELECT id, val FROM t")
+	if assert.NoError(t, err) {
+		assert.Equal(t, [][]interface{}{{float64(1), "a"}, {float64(2), "b"}}, got)
+	}
+	assert.Equal(t, "SELECT id, val FROM t", fake.requests[2]["sqlText"])
+
+	c.Disconnect()
+	assert.Equal(t, "disconnect", fake.requests[len(fake.requests)-1]["command"])
+}
+
+func TestMalformedResponses(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{
+		Username:      "sys",
+		Password:      "secret",
+		SuppressError: true,
+	}, fake)
+	defer c.Disconnect()
+	var err error
+
+	tests := []struct {
+		name     string
+		execute  string
+		fetch    string
+		protocol bool
+	}{
+		{name: "error without exception", execute: `{"status":"error"}`, protocol: true},
+		{name: "missing status", execute: `{}`, protocol: true},
+		{name: "ok without responseData", execute: `{"status":"ok"}`, protocol: true},
+		{name: "wrong shape", execute: `[1,2,3]`},
+		{name: "results missing", execute: `{"status":"ok","responseData":{"numResults":1}}`},
+		{name: "resultSet missing", execute: `{"status":"ok","responseData":{"numResults":1,"results":[{"resultType":"resultSet"}]}}`},
+		{
+			name: "empty fetch",
+			execute: `{"status":"ok","responseData":{"numResults":1,"results":[{"resultType":"resultSet",
+				"resultSet":{"resultSetHandle":1,"numColumns":1,"numRows":5,"numRowsInMessage":0,
+				"columns":[{"name":"ID","dataType":{"type":"DECIMAL"}}]}}]}}`,
+			fetch:    `{"status":"ok","responseData":{"numRows":0}}`,
+			protocol: true,
+		},
+	}
+	for _, test := range tests {
+		fake.override = map[string]string{"execute": test.execute}
+		if test.fetch != "" {
+			fake.override["fetch"] = test.fetch
+		}
+		assert.NotPanics(t, func() {
+			_, err = c.FetchSlice("SELECT 1")
+			if assert.Error(t, err, test.name) && test.protocol {
+				assert.ErrorIs(t, err, ErrProtocol, test.name)
+			}
+		}, test.name)
+	}
+
+	fake.override = map[string]string{"execute": `{"status":"error","exception":{"text":"bad"}}`}
+	_, err = c.Execute("SELECT 1")
+	assert.EqualError(t, err, "Unable to Execute: Server Error: bad")
+	fake.override = nil
+}
+
+func TestClosedConn(t *testing.T) {
+	c := newFakeConn(t, ConnConf{SuppressError: true}, &fakeWSHandler{})
+	var err error
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Disconnect()
+		}()
+	}
+	wg.Wait()
+
+	assert.NotPanics(t, func() {
+		_, err = c.Execute("SELECT 1")
+		assert.ErrorIs(t, err, ErrConnClosed, "Execute")
+		_, err = c.FetchSlice("SELECT 1")
+		assert.ErrorIs(t, err, ErrConnClosed, "FetchSlice")
+		_, err = c.BulkExecute("IMPORT INTO t FROM CSV AT '%s' FILE 'data.csv'", bytes.NewBufferString("1\n"))
+		assert.ErrorIs(t, err, ErrConnClosed, "BulkExecute")
+		assert.ErrorIs(t, c.Reconnect(), ErrConnClosed, "Reconnect")
+	})
+}
+
+func TestFetchAll(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+
+	fake.override = map[string]string{
+		"execute": `{"status":"ok","responseData":{"numResults":3,"results":[
+			{"resultType":"resultSet","resultSet":{"numColumns":1,"numRows":2,"numRowsInMessage":2,
+				"columns":[{"name":"A","dataType":{"type":"DECIMAL","precision":18,"scale":0}}],
+				"data":[[1,2]]}},
+			{"resultType":"rowCount","rowCount":5},
+			{"resultType":"resultSet","resultSet":{"resultSetHandle":7,"numColumns":1,"numRows":3,
+				"numRowsInMessage":1,"columns":[{"name":"B","dataType":{"type":"VARCHAR","size":1}}],
+				"data":[["x"]]}}
+		]}}`,
+		"fetch": `{"status":"ok","responseData":{"numRows":2,"data":[["y","z"]]}}`,
+	}
+	got, err := c.FetchAll("EXECUTE SCRIPT s")
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, []string{"A"}, got[0].Columns)
+	assert.Equal(t, "DECIMAL", got[0].Types[0].Type)
+	assert.Equal(t, [][]interface{}{{float64(1)}, {float64(2)}}, got[0].Rows)
+	assert.Equal(t, int64(2), got[0].RowCount)
+	assert.Equal(t, &ResultSet{RowCount: 5}, got[1])
+	assert.Equal(t, [][]interface{}{{"x"}, {"y"}, {"z"}}, got[2].Rows)
+
+	// The second result set was fetched then closed
+	var cmds []interface{}
+	for _, r := range fake.requests {
+		cmds = append(cmds, r["command"])
+	}
+	assert.Equal(t, []interface{}{"execute", "fetch", "closeResultSet"}, cmds[len(cmds)-3:])
+	fake.override = nil
+}
+
+func TestConnectWithPipe(t *testing.T) {
+	client, server := net.Pipe()
+	handshake := make(chan *http.Request, 1)
+	go func() {
+		req, _ := http.ReadRequest(bufio.NewReader(server))
+		handshake <- req
+		server.Close()
+	}()
+
+	_, err := ConnectWith(ConnConf{
+		Host:           "tunnelled",
+		Port:           1234,
+		ConnectTimeout: 5 * time.Second,
+		Logger:         customTestLogger("fatal"),
+	}, client)
+	assert.Error(t, err, "The fake server hangs up")
+	req := <-handshake
+	if assert.NotNil(t, req, "The handshake went over the net.Conn") {
+		assert.Equal(t, "tunnelled:1234", req.Host)
+		assert.Equal(t, "websocket", req.Header.Get("Upgrade"))
+	}
+
+	_, err = ConnectWith(ConnConf{}, nil)
+	assert.EqualError(t, err, "ConnectWith requires a net.Conn")
+}
+
+// Returns a websocket server that completes the handshake
+// but never responds to anything
+func silentWSServer(t *testing.T) (*httptest.Server, string, uint16) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	portN, _ := strconv.Atoi(port)
+	return srv, host, uint16(portN)
+}
+
+func TestConnectLoginTimeout(t *testing.T) {
+	srv, host, port := silentWSServer(t)
+	defer srv.Close()
+
+	timeIn := time.Now()
+	_, err := Connect(ConnConf{
+		Host:           host,
+		Port:           port,
+		ConnectTimeout: 200 * time.Millisecond,
+		Logger:         customTestLogger("fatal"),
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to login to Exasol")
+	}
+	assert.Less(t, time.Since(timeIn).Seconds(), 2.0, "The login timed out")
+}
+
+func TestConnectRetries(t *testing.T) {
+	// Hangs up on every connection
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	var accepted int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			conn.Close()
+		}
+	}()
+
+	_, err = Connect(ConnConf{
+		Host:           "127.0.0.1",
+		Port:           uint16(ln.Addr().(*net.TCPAddr).Port),
+		ConnectRetries: 2,
+		ConnectBackoff: time.Millisecond,
+		Logger:         customTestLogger("fatal"),
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to connect to Exasol")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&accepted), "It retried twice")
+
+	// Server errors, e.g. bad credentials, aren't retried
+	fake := &fakeWSHandler{override: map[string]string{
+		"<nil>": `{"status":"error","exception":{"text":"Invalid password"}}`,
+	}}
+	_, err = NewConnWithTransport(ConnConf{
+		Host:           "fake",
+		Port:           8563,
+		ConnectRetries: 2,
+		ConnectBackoff: time.Millisecond,
+		Logger:         customTestLogger("fatal"),
+	}, fake)
+	assert.EqualError(t, err, "Unable to login to Exasol: Unable to authenticate: Server Error: Invalid password")
+	assert.Len(t, fake.requests, 2, "Only the one login attempt")
+}
+
+func TestReadTimeout(t *testing.T) {
+	srv, host, port := silentWSServer(t)
+	defer srv.Close()
+
+	for _, pingInterval := range []time.Duration{0, time.Minute} {
+		wsh := newDefaultWSHandler()
+		wsh.configure(ConnConf{ReadTimeout: 100 * time.Millisecond, PingInterval: pingInterval})
+		require.NoError(t, wsh.Connect(url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", host, port)}, nil, 0))
+		timeIn := time.Now()
+		err := wsh.ReadJSON(&response{})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "No response within the ReadTimeout of 100ms")
+		}
+		assert.Less(t, time.Since(timeIn).Seconds(), 1.0, "It timed out")
+		wsh.Close()
+	}
+
+	// The client waits longer than the server by default
+	c := &Conn{Conf: ConnConf{QueryTimeout: 30 * time.Second}}
+	assert.Equal(t, 35*time.Second, c.readTimeout())
+	c.Conf.ReadTimeout = time.Minute
+	assert.Equal(t, time.Minute, c.readTimeout())
+	c.Conf = ConnConf{}
+	assert.Equal(t, time.Duration(0), c.readTimeout(), "No limit")
+
+	// As do plain requests, not just the bulk ones
+	wsh := newDefaultWSHandler()
+	wsh.configure(ConnConf{QueryTimeout: 30 * time.Second})
+	assert.Equal(t, 35*time.Second, wsh.readTimeout)
+}
+
+func TestSnapshot(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{SuppressError: true}, fake)
+	defer c.Disconnect()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Execute("SELECT 1")
+			c.Snapshot()
+		}()
+	}
+	wg.Wait()
+
+	fake.override = map[string]string{"execute": `{"status":"error","exception":{"text":"bad"}}`}
+	_, err := c.Execute("SELECT 1")
+	assert.Error(t, err)
+
+	snap := c.Snapshot()
+	assert.Equal(t, 5, snap["Queries"])
+	assert.Equal(t, 1, snap["Errors"])
+	snap["Queries"] = 0
+	assert.Equal(t, 5, c.Snapshot()["Queries"], "It's a copy")
+}
+
+func TestServerVersion(t *testing.T) {
+	c := newFakeConn(t, ConnConf{}, &fakeWSHandler{override: map[string]string{
+		"<nil>": `{"status":"ok","responseData":{"sessionId":123,"protocolVersion":1,
+			"releaseVersion":"7.1.12","databaseName":"DB1"}}`,
+	}})
+	defer c.Disconnect()
+
+	assert.Equal(t, "7.1.12", c.ServerVersion)
+	assert.Equal(t, "DB1", c.DatabaseName)
+	for version, expect := range map[string]bool{
+		"7": true, "7.1": true, "7.1.12": true, "7.1.2": true, "6.2.9": true,
+		"7.1.13": false, "7.2": false, "8": false, "7.1.12.1": false,
+	} {
+		assert.Equal(t, expect, c.ServerVersionAtLeast(version), version)
+	}
+}
+
+func TestSessionAttrAccessors(t *testing.T) {
+	c := newFakeConn(t, ConnConf{}, &fakeWSHandler{override: map[string]string{
+		"getAttributes": `{"status":"ok","attributes":{"currentSchema":"MY_SCHEMA",
+			"compressionEnabled":true,"openTransaction":1,"queryTimeout":30}}`,
+	}})
+	defer c.Disconnect()
+
+	schema, err := c.CurrentSchema()
+	assert.NoError(t, err)
+	assert.Equal(t, "MY_SCHEMA", schema)
+	compressed, err := c.CompressionEnabled()
+	assert.NoError(t, err)
+	assert.True(t, compressed)
+	assert.False(t, c.Encrypted())
+
+	attrs, err := c.GetSessionAttr()
+	require.NoError(t, err)
+	assert.True(t, attrs.InTransaction())
+	assert.Equal(t, uint32(30), attrs.QueryTimeout)
+}
+
+func TestQueryComment(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{QueryComment: "trace-id: abc"}, fake)
+	defer c.Disconnect()
+
+	lastSQL := func() interface{} { return fake.requests[len(fake.requests)-1]["sqlText"] }
+	_, err := c.FetchSlice("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/* trace-id: abc */ SELECT 1", lastSQL())
+
+	_, err = c.ExecuteResult("INSERT INTO t VALUES (1)", ExecConf{Comment: "evil */ DROP"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/* evil * / DROP */ INSERT INTO t VALUES (1)", lastSQL(), "Can't end the comment early")
+	assert.True(t, isInsertSQL.MatchString(c.tagSQL("INSERT INTO t VALUES (1)", "")), "Comments are skipped")
+	assert.True(t, isSchemaChangeSQL.MatchString("/* a */ /* b\n*/ OPEN SCHEMA foo"))
+
+	c.Conf.QueryComment = ""
+	_, err = c.FetchSlice("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 1", lastSQL())
+
+	// Comments aren't part of the prepared statement cache's key
+	fake.override = map[string]string{
+		"createPreparedStatement": `{"status":"ok","responseData":{"statementHandle":1,
+			"parameterData":{"numColumns":1,"columns":[{"name":"A","dataType":{"type":"DECIMAL","precision":18}}]}}}`,
+		"executePreparedStatement": `{"status":"ok","responseData":{"numResults":1,
+			"results":[{"resultType":"rowCount","rowCount":1}]}}`,
+	}
+	c.Conf.CachePrepStmts = true
+	for _, trace := range []string{"trace-id: 1", "trace-id: 2"} {
+		_, err = c.ExecuteResult("INSERT INTO t VALUES (?)", ExecConf{Binds: [][]interface{}{{1}}, Comment: trace})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, c.PreparedStatementCount())
+	assert.Equal(t, 1, c.Snapshot()["StmtCacheMiss"], "Prepared once")
+}
+
+// Records the URLs it's asked to connect to
+type urlWSHandler struct {
+	fakeWSHandler
+	urls []string
+}
+
+func (u *urlWSHandler) Connect(url url.URL, t *tls.Config, d time.Duration) error {
+	u.urls = append(u.urls, url.String())
+	return nil
+}
+
+func TestConnectIPv6Host(t *testing.T) {
+	for _, host := range []string{"fd00::17", "[fd00::17]"} {
+		wsh := &urlWSHandler{fakeWSHandler: fakeWSHandler{}}
+		c := newFakeConn(t, ConnConf{Host: host}, wsh)
+		assert.Equal(t, []string{"ws://[fd00::17]:8563"}, wsh.urls, host)
+		assert.Equal(t, "fd00::17", c.host, "Stored unbracketed for the proxy")
+		c.Disconnect()
+	}
+}
+
+// Fails reads once failRead is set and the next failWrites writes
+type failingWSHandler struct {
+	fakeWSHandler
+	failRead   bool
+	failWrites int
+}
+
+func (f *failingWSHandler) WriteJSON(req interface{}) error {
+	if f.failWrites > 0 {
+		f.failWrites--
+		return errors.New("broken pipe")
+	}
+	return f.fakeWSHandler.WriteJSON(req)
+}
+
+func (f *failingWSHandler) ReadJSON(resp interface{}) error {
+	if f.failRead {
+		return errors.New("connection reset by peer")
+	}
+	return f.fakeWSHandler.ReadJSON(resp)
+}
+
+func TestIsAlive(t *testing.T) {
+	wsh := &failingWSHandler{fakeWSHandler: fakeWSHandler{}}
+	c := newFakeConn(t, ConnConf{}, wsh)
+	assert.True(t, c.IsAlive())
+
+	wsh.override = map[string]string{
+		"execute": `{"status":"error","exception":{"text":"syntax error"}}`,
+	}
+	c.Conf.SuppressError = true
+	_, err := c.Execute("ASDF")
+	assert.Error(t, err)
+	assert.True(t, c.IsAlive(), "Server errors don't affect it")
+
+	// It doesn't wait for in-flight requests
+	c.sendMux.Lock()
+	done := make(chan bool)
+	go func() { done <- c.IsAlive() }()
+	select {
+	case alive := <-done:
+		assert.True(t, alive)
+	case <-time.After(time.Second):
+		t.Error("IsAlive blocked on an in-flight request")
+	}
+	c.sendMux.Unlock()
+
+	wsh.failRead = true
+	assert.Error(t, c.Ping())
+	assert.False(t, c.IsAlive(), "After a websocket error")
+
+	wsh.failRead = false
+	require.NoError(t, c.Reconnect())
+	assert.True(t, c.IsAlive(), "Reconnected")
+
+	c.Disconnect()
+	assert.False(t, c.IsAlive(), "Disconnected")
+}
+
+func TestBooleanBindConversion(t *testing.T) {
+	c := &Conn{}
+	cols := []column{{DataType: DataType{Type: "BOOLEAN"}}, {DataType: DataType{Type: "VARCHAR"}}}
+	got, err := c.convertBinds([][]interface{}{
+		{true, false, nil, sql.NullBool{}, sql.NullBool{Bool: true, Valid: true}, "TRUE", "0"},
+		{true, "true"},
+	}, cols)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{true, false, nil, nil, true, true, false}, got[0])
+	assert.Equal(t, []interface{}{true, "true"}, got[1], "Strings are only converted for BOOLEAN columns")
+
+	_, err = c.convertBinds([][]interface{}{{"yes"}}, cols)
+	assert.EqualError(t, err, `Invalid bind value in row 1, column 1: "yes" isn't a BOOLEAN`)
+}
+
+func TestRaggedBinds(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{Logger: customTestLogger("fatal")}, fake)
+	defer c.Disconnect()
+	numReqs := len(fake.requests)
+
+	_, err := c.Execute("INSERT INTO t VALUES (?, ?)", [][]interface{}{{1, "a"}, {2}})
+	assert.EqualError(t, err, "Unable to Execute: Bind row 2 has 1 values but row 1 has 2")
+	_, err = c.Execute("INSERT INTO t VALUES (?, ?)", [][]interface{}{{1, 2}, {"a"}}, nil, nil, true)
+	assert.EqualError(t, err, "Unable to Execute: Bind column 2 has 1 values but column 1 has 2")
+	assert.Len(t, fake.requests, numReqs, "Nothing was sent")
+}
+
+// Execute already accepts a flat []interface{} as a single row of binds
+func TestExecArgsBindShapes(t *testing.T) {
+	c := &Conn{log: customTestLogger("fatal"), Conf: ConnConf{SuppressError: true}}
+
+	conf, err := c.execArgs([]interface{}{[]interface{}{1, "a", nil}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{1, "a", nil}}, conf.Binds, "One row")
+	assert.False(t, conf.IsColumnar)
+
+	conf, err = c.execArgs([]interface{}{[][]interface{}{{1, "a"}, {2, "b"}}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{1, "a"}, {2, "b"}}, conf.Binds, "Several rows")
+
+	_, err = c.execArgs([]interface{}{[]string{"a"}})
+	assert.EqualError(t, err, "Execute's 2nd param (binds) must be []interface{} or [][]interface{}")
+}
+
+func TestFetchArgsCopiesConf(t *testing.T) {
+	c := &Conn{log: customTestLogger("fatal"), Conf: ConnConf{SuppressError: true}}
+	orig := &ExecConf{FetchBytes: 1024}
+
+	conf, err := c.fetchArgs([]interface{}{orig, "MY_SCHEMA"})
+	require.NoError(t, err)
+	assert.Equal(t, "MY_SCHEMA", conf.Schema)
+	assert.Equal(t, 1024, conf.FetchBytes)
+	assert.Equal(t, &ExecConf{FetchBytes: 1024}, orig, "The caller's is unchanged")
+}
+
+func TestConsumerGroup(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{
+		Logger:        customTestLogger("fatal"),
+		ConsumerGroup: "etl",
+	}, fake)
+	defer c.Disconnect()
+	last := fake.requests[len(fake.requests)-1]
+	assert.Equal(t, `ALTER SESSION SET CONSUMER_GROUP = "ETL"`, last["sqlText"], "Applied at login")
+
+	require.NoError(t, c.SetConsumerGroup(`"Interactive"`))
+	last = fake.requests[len(fake.requests)-1]
+	assert.Equal(t, `ALTER SESSION SET CONSUMER_GROUP = "Interactive"`, last["sqlText"])
+	assert.Equal(t, `"Interactive"`, c.Conf.ConsumerGroup, "Kept for reconnecting")
+
+	fake.override = map[string]string{"execute": `{"status":"error","exception":{"text":"insufficient privileges"}}`}
+	c.Conf.SuppressError = true
+	assert.Error(t, c.SetConsumerGroup("admin"))
+	assert.Equal(t, `"Interactive"`, c.Conf.ConsumerGroup, "Unchanged on error")
+	_, err := NewConnWithTransport(ConnConf{
+		Host:          "fake",
+		Port:          8563,
+		Logger:        customTestLogger("fatal"),
+		ConsumerGroup: "etl",
+	}, fake)
+	assert.ErrorContains(t, err, "Unable to set ConsumerGroup")
+}
+
+// Delays every response
+type slowWSHandler struct {
+	*fakeWSHandler
+	delay time.Duration
+}
+
+func (wsh *slowWSHandler) ReadJSON(resp interface{}) error {
+	time.Sleep(wsh.delay)
+	return wsh.fakeWSHandler.ReadJSON(resp)
+}
+
+func TestResultDuration(t *testing.T) {
+	c := newFakeConn(t, ConnConf{}, &slowWSHandler{&fakeWSHandler{override: map[string]string{
+		"executeBatch": `{"status":"ok","responseData":{"numResults":2,"results":[
+			{"resultType":"rowCount","rowCount":1},{"resultType":"rowCount","rowCount":2}]}}`,
+	}}, 20 * time.Millisecond})
+	defer c.Disconnect()
+
+	got, err := c.ExecuteResult("DELETE FROM t")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(got.Duration), int64(20*time.Millisecond))
+
+	results, err := c.ExecuteBatch([]string{"DELETE FROM t", "DELETE FROM u"}, "")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		assert.GreaterOrEqual(t, int64(res.Duration), int64(20*time.Millisecond))
+	}
+}
+
+func (s *testSuite) TestClone() {
+	conf := s.connConf()
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	_, err = c.Execute("OPEN SCHEMA " + s.qschema)
+	s.Require().NoError(err)
+	s.Require().NoError(c.DisableAutoCommit())
+	s.Require().NoError(c.SetTimeout(42))
+
+	clone, err := c.Clone()
+	s.Require().NoError(err)
+	defer clone.Disconnect()
+	s.NotEqual(c.SessionID, clone.SessionID, "A separate session")
+	attrs, err := clone.GetAttributes()
+	if s.NoError(err) {
+		s.Equal(s.schema, *attrs.CurrentSchema)
+		s.False(*attrs.Autocommit)
+		s.Equal(uint32(42), *attrs.QueryTimeout)
+	}
+	s.Equal(conf.DefaultSchema, c.Conf.DefaultSchema, "The parent's ConnConf is unchanged")
+}
+
+func TestCloneFake(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"getAttributes": `{"status":"ok","attributes":{"currentSchema":"MY_SCHEMA",
+			"autocommit":false,"queryTimeout":30}}`,
+	}}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+
+	c.Conf.SuppressError = true
+	_, err := c.Clone()
+	assert.EqualError(t, err, "Unable to Clone a connection with a custom WSHandler, use CloneWithTransport")
+	_, err = c.CloneWithTransport(fake)
+	assert.Error(t, err, "The parent's WSHandler can't be shared")
+
+	fake2 := &fakeWSHandler{}
+	clone, err := c.CloneWithTransport(fake2)
+	require.NoError(t, err)
+	defer clone.Disconnect()
+	assert.Equal(t, "MY_SCHEMA", clone.Conf.DefaultSchema)
+	assert.Equal(t, "", c.Conf.DefaultSchema)
+	assert.Equal(t, fake2, clone.Conf.WSHandler)
+
+	var creds, setAttrs map[string]interface{}
+	for _, req := range fake2.requests {
+		switch req["command"] {
+		case nil:
+			creds = req
+		case "setAttributes":
+			setAttrs = req
+		}
+	}
+	if assert.NotNil(t, creds, "Logged in") {
+		assert.Equal(t, "MY_SCHEMA", creds["attributes"].(map[string]interface{})["currentSchema"])
+	}
+	if assert.NotNil(t, setAttrs) {
+		assert.Equal(t, map[string]interface{}{"autocommit": false, "queryTimeout": float64(30)}, setAttrs["attributes"])
+	}
+}
+
+func TestDescribeTableFake(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"createPreparedStatement": `{"status":"ok","responseData":{"statementHandle":1,
+			"parameterData":{"numColumns":2,"columns":[{"name":"A","dataType":{"type":"VARCHAR"}},
+				{"name":"B","dataType":{"type":"VARCHAR"}}]}}}`,
+		"executePreparedStatement": `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"numColumns":6,"numRows":2,"numRowsInMessage":2,
+			"columns":[{"name":"COLUMN_NAME","dataType":{"type":"VARCHAR"}},
+				{"name":"COLUMN_TYPE","dataType":{"type":"VARCHAR"}},
+				{"name":"COLUMN_IS_NULLABLE","dataType":{"type":"BOOLEAN"}},
+				{"name":"COLUMN_DEFAULT","dataType":{"type":"VARCHAR"}},
+				{"name":"IDENT","dataType":{"type":"BOOLEAN"}},
+				{"name":"COLUMN_COMMENT","dataType":{"type":"VARCHAR"}}],
+			"data":[["ID","NAME"],["DECIMAL(18,0)","VARCHAR(10) UTF8"],[false,true],
+				[null,"'x'"],[true,false],[null,"The name"]]}}]}}`,
+	}}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+
+	got, err := c.DescribeTable("my_schema", `"My Table"`)
+	require.NoError(t, err)
+	def := "'x'"
+	assert.Equal(t, []ColumnInfo{
+		{Name: "ID", Type: "DECIMAL(18,0)", Identity: true},
+		{Name: "NAME", Type: "VARCHAR(10) UTF8", Nullable: true, Default: &def, Comment: "The name"},
+	}, got)
+	var binds interface{}
+	for _, req := range fake.requests {
+		if req["command"] == "executePreparedStatement" {
+			binds = req["data"]
+		}
+	}
+	assert.Equal(t, "[[MY_SCHEMA] [My Table]]", fmt.Sprint(binds), "Bound as stored")
+
+	fake.override["executePreparedStatement"] = `{"status":"ok","responseData":{"numResults":1,"results":[{
+		"resultType":"resultSet","resultSet":{"numColumns":1,"numRows":0,"numRowsInMessage":0,
+		"columns":[{"name":"COLUMN_NAME","dataType":{"type":"VARCHAR"}}]}}]}}`
+	c.Conf.SuppressError = true
+	_, err = c.DescribeTable("s", "t")
+	assert.EqualError(t, err, "Unable to DescribeTable: s.t not found")
+}
+
+func TestResendOnWriteError(t *testing.T) {
+	wsh := &failingWSHandler{fakeWSHandler: fakeWSHandler{}}
+	c := newFakeConn(t, ConnConf{
+		Logger:        customTestLogger("fatal"),
+		AutoReconnect: true,
+		SuppressError: true,
+	}, wsh)
+	defer c.Disconnect()
+	logins := func() int {
+		n := 0
+		for _, req := range wsh.requests {
+			if req["command"] == "login" {
+				n++
+			}
+		}
+		return n
+	}
+
+	wsh.failWrites = 1
+	got, err := c.FetchSlice("/* x */ SELECT id, val FROM t")
+	assert.NoError(t, err, "Reconnected and resent")
+	assert.Len(t, got, 2)
+	assert.Equal(t, 2, logins())
+	assert.True(t, c.IsAlive())
+
+	wsh.failWrites = 1
+	_, err = c.Execute("DELETE FROM t")
+	assert.ErrorIs(t, err, errSend, "DML isn't resent")
+	assert.Equal(t, 2, logins())
+
+	wsh.failWrites = 1
+	assert.NoError(t, c.EnableAutoCommit(), "Control commands are resent")
+	assert.Equal(t, 3, logins())
+
+	// Not while autocommit is disabled as the new session wouldn't be in the transaction
+	require.NoError(t, c.DisableAutoCommit())
+	wsh.failWrites = 1
+	_, err = c.FetchSlice("SELECT id, val FROM t")
+	assert.ErrorIs(t, err, errSend, "Queries aren't resent")
+	wsh.failWrites = 1
+	_, err = c.GetAttributes()
+	assert.Error(t, err, "Nor are control commands")
+	assert.Equal(t, 3, logins())
+	require.NoError(t, c.Reconnect())
+
+	c.Conf.AutoReconnect = false
+	wsh.failWrites = 1
+	_, err = c.FetchSlice("SELECT id, val FROM t")
+	assert.ErrorIs(t, err, errSend)
+	assert.Equal(t, 4, logins())
+}
+
+func TestSetAttribute(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+	lastAttrs := func() interface{} {
+		req := fake.requests[len(fake.requests)-1]
+		assert.Equal(t, "setAttributes", req["command"])
+		return req["attributes"]
+	}
+
+	require.NoError(t, c.SetAttribute("feedbackInterval", 0))
+	assert.Equal(t, map[string]interface{}{"feedbackInterval": float64(0)}, lastAttrs(), "Zero is sent")
+	require.NoError(t, c.DisableAutoCommit())
+	assert.Equal(t, map[string]interface{}{"autocommit": false}, lastAttrs(), "As is false")
+	require.NoError(t, c.SetTimeout(0))
+	assert.Equal(t, map[string]interface{}{"queryTimeout": float64(0)}, lastAttrs())
+
+	require.NoError(t, c.SetAttribute("currentSchema", "MY_SCHEMA"))
+	assert.Equal(t, "MY_SCHEMA", c.currentSchema, "Tracked for the statement cache")
+
+	c.Conf.SuppressError = true
+	assert.EqualError(t, c.SetAttribute("", 1), "SetAttribute requires a key")
+}
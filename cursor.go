@@ -0,0 +1,143 @@
+/*
+	Paging through a result set with your own logic:
+
+	cursor, err := conn.OpenResultSet("SELECT * FROM big_table ORDER BY id")
+	defer cursor.Close()
+
+	rows, err := cursor.Fetch(cursor.NumRows-100, 0) // The last 100 rows
+
+	The result set stays open on the server (holding its resources)
+	until it's closed so always Close it once you're done.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"sync"
+)
+
+/*--- Public Interface ---*/
+
+// A query's result set left open for fetching its rows in any order
+type ResultCursor struct {
+	// Exasol's resultSetHandle. It's 0 when all the rows were small
+	// enough to be returned with the query, which Fetch then serves.
+	Handle  int
+	NumRows int64
+	Columns []string
+	Types   []DataType
+
+	conn       *Conn
+	data       [][]interface{} // The rows returned with the query (column-major)
+	fetchBytes int
+	closed     bool
+	mux        sync.Mutex
+}
+
+// Runs the query returning its result set without fetching any rows.
+// The optional args are the same as for FetchChan.
+func (c *Conn) OpenResultSet(sql string, args ...interface{}) (*ResultCursor, error) {
+	conf, err := c.fetchArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	fetchBytes, err := c.fetchBytes(conf.FetchBytes)
+	if err != nil {
+		return nil, c.errorf("Unable to Fetch: %s", err)
+	}
+	rs, err := c.query(sql, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &ResultCursor{
+		Handle:     rs.ResultSetHandle,
+		NumRows:    int64(rs.NumRows),
+		Columns:    make([]string, len(rs.Columns)),
+		Types:      columnTypes(rs.Columns),
+		conn:       c,
+		fetchBytes: fetchBytes,
+	}
+	for i, col := range rs.Columns {
+		rc.Columns[i] = col.Name
+	}
+	if rc.Handle == 0 {
+		rc.data = rs.Data
+	}
+	return rc, nil
+}
+
+// Returns the rows from the 0-based start row, up to numBytes worth of
+// them (0 for ConnConf.FetchBytes). It returns fewer rows than requested
+// at the end of the result set and none once start is past the end.
+func (rc *ResultCursor) Fetch(start int64, numBytes int) ([][]interface{}, error) {
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	if rc.closed {
+		return nil, rc.conn.error("Unable to Fetch: the result set is closed")
+	}
+	if start < 0 {
+		return nil, rc.conn.errorf("Unable to Fetch: invalid start row %d", start)
+	}
+	if start >= rc.NumRows {
+		return nil, nil
+	}
+	if rc.Handle == 0 {
+		return transposeRows(rc.data, int(start)), nil
+	}
+	if numBytes == 0 {
+		numBytes = rc.fetchBytes
+	} else if n, err := rc.conn.fetchBytes(numBytes); err != nil {
+		return nil, rc.conn.errorf("Unable to Fetch: %s", err)
+	} else {
+		numBytes = n
+	}
+	fetched, err := rc.conn.fetchRows(rc.Handle, uint64(start), numBytes)
+	if err != nil {
+		return nil, err
+	}
+	return transposeRows(fetched.Data, 0), nil
+}
+
+// Closes the result set on the server. Subsequent Fetches return an error.
+func (rc *ResultCursor) Close() {
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	if rc.closed {
+		return
+	}
+	rc.closed = true
+	rc.data = nil
+	if rc.Handle != 0 {
+		rc.conn.closeResultSet(rc.Handle)
+	}
+}
+
+/*--- Private Routines ---*/
+
+// Returns the rows of the column-major matrix from the start row
+func transposeRows(matrix [][]interface{}, start int) [][]interface{} {
+	if len(matrix) == 0 || start >= len(matrix[0]) {
+		return nil
+	}
+	rows := make([][]interface{}, len(matrix[0])-start)
+	for i := range rows {
+		row := make([]interface{}, len(matrix))
+		for col := range matrix {
+			row[col] = matrix[col][start+i]
+		}
+		rows[i] = row
+	}
+	return rows
+}
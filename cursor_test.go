@@ -0,0 +1,126 @@
+package exasol
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *testSuite) TestOpenResultSet() {
+	s.execute("CREATE TABLE foo AS SELECT level AS id FROM dual CONNECT BY level <= 2000")
+
+	rc, err := s.exaConn.OpenResultSet("SELECT id FROM foo ORDER BY id")
+	s.Require().NoError(err)
+	defer rc.Close()
+	s.NotZero(rc.Handle, "Too many rows to be returned with the query")
+	s.Equal(int64(2000), rc.NumRows)
+	s.Equal([]string{"ID"}, rc.Columns)
+
+	rows, err := rc.Fetch(1990, 0)
+	s.Require().NoError(err)
+	if s.Len(rows, 10) {
+		s.Equal(float64(1991), rows[0][0])
+	}
+	rows, err = rc.Fetch(5, 0)
+	s.Require().NoError(err)
+	s.Equal(float64(6), rows[0][0], "Random access")
+	rows, err = rc.Fetch(2000, 0)
+	s.NoError(err)
+	s.Nil(rows, "Past the end")
+}
+
+func TestResultCursor(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+	c.Conf.SuppressError = true
+
+	// The rows returned with the query
+	rc, err := c.OpenResultSet("SELECT id, val FROM t")
+	require.NoError(t, err)
+	assert.Zero(t, rc.Handle)
+	assert.Equal(t, []string{"ID", "VAL"}, rc.Columns)
+	assert.Equal(t, "VARCHAR", rc.Types[1].Type)
+	numReqs := len(fake.requests)
+	rows, err := rc.Fetch(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{float64(2), "b"}}, rows)
+	rows, err = rc.Fetch(2, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, rows)
+	rc.Close()
+	assert.Len(t, fake.requests, numReqs, "No requests were needed")
+
+	// Rows left on the server
+	fake.override = map[string]string{
+		"execute": `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"resultSetHandle":7,"numColumns":1,"numRows":5000,
+			"numRowsInMessage":0,"columns":[{"name":"ID","dataType":{"type":"DECIMAL"}}]}}]}}`,
+		"fetch": `{"status":"ok","responseData":{"numRows":2,"data":[[3001,3002]]}}`,
+	}
+	rc, err = c.OpenResultSet("SELECT id FROM t")
+	require.NoError(t, err)
+	assert.Equal(t, 7, rc.Handle)
+	assert.Equal(t, int64(5000), rc.NumRows)
+	rows, err = rc.Fetch(3000, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{float64(3001)}, {float64(3002)}}, rows)
+	req := fake.requests[len(fake.requests)-1]
+	assert.Equal(t, "fetch", req["command"])
+	assert.Equal(t, float64(3000), req["startPosition"])
+	assert.Equal(t, float64(1024), req["numBytes"])
+
+	_, err = rc.Fetch(0, maxFetchBytes+1)
+	assert.NoError(t, err)
+	req = fake.requests[len(fake.requests)-1]
+	assert.Equal(t, float64(maxFetchBytes), req["numBytes"], "Clamped to the max")
+	_, err = rc.Fetch(-1, 0)
+	assert.Error(t, err)
+
+	rc.Close()
+	req = fake.requests[len(fake.requests)-1]
+	assert.Equal(t, "closeResultSet", req["command"])
+	assert.Equal(t, "[7]", fmt.Sprint(req["resultSetHandles"]))
+	_, err = rc.Fetch(0, 0)
+	assert.EqualError(t, err, "Unable to Fetch: the result set is closed")
+}
+
+func TestFetchSizeHint(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"<nil>": `{"status":"ok","responseData":{"sessionId":123,"protocolVersion":1,
+			"maxDataMessageSize":4096}}`,
+		"execute": `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"resultSetHandle":7,"numColumns":1,"numRows":2,
+			"numRowsInMessage":0,"columns":[{"name":"ID","dataType":{"type":"DECIMAL"}}]}}]}}`,
+		"fetch": `{"status":"ok","responseData":{"numRows":2,"data":[[1,2]]}}`,
+	}}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+	c.Conf.SuppressError = true
+
+	got, err := c.FetchSlice("SELECT id FROM t")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{float64(1)}, {float64(2)}}, got)
+	var numBytes []interface{}
+	for _, req := range fake.requests {
+		if req["command"] == "fetch" {
+			numBytes = append(numBytes, req["numBytes"])
+		}
+	}
+	assert.Equal(t, []interface{}{float64(4096)}, numBytes, "The server's maxDataMessageSize")
+
+	numReqs := len(fake.requests)
+	_, err = c.FetchSlice("SELECT id FROM t", ExecConf{FetchBytes: 8192})
+	require.NoError(t, err)
+	for _, req := range fake.requests[numReqs:] {
+		if req["command"] == "fetch" {
+			assert.Equal(t, float64(4096), req["numBytes"], "Clamped to the server's max")
+		}
+	}
+
+	fake.override["fetch"] = `{"status":"ok","responseData":{"numRows":2,"data":[[1]]}}`
+	_, err = c.FetchSlice("SELECT id FROM t")
+	assert.ErrorIs(t, err, ErrProtocol, "numRows doesn't match the data")
+}
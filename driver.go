@@ -0,0 +1,309 @@
+/*
+	A database/sql driver configured with a ConnConf rather than a DSN:
+
+	db := sql.OpenDB(exasol.NewConnector(conf))
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM t WHERE id > ?", 10)
+
+	Each connection in the *sql.DB's pool is opened with Connect. Placeholders
+	are positional (?) only. Cancelling a statement's context aborts it,
+	including while a query's rows are still being fetched.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+/*--- Public Interface ---*/
+
+// Returns a driver.Connector for sql.OpenDB that opens each connection
+// with Connect(conf). If the context passed to it has a deadline and
+// conf.ConnectTimeout isn't set then the deadline bounds the connect.
+func NewConnector(conf ConnConf) driver.Connector {
+	return &connector{conf: conf}
+}
+
+/*--- Private Routines ---*/
+
+type connector struct {
+	conf ConnConf
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conf := c.conf
+	if deadline, ok := ctx.Deadline(); ok && conf.ConnectTimeout == 0 {
+		conf.ConnectTimeout = time.Until(deadline)
+	}
+	conn, err := Connect(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{conn: conn}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return sqlDriver{} }
+
+// There's no DSN format so the driver is only usable via NewConnector
+type sqlDriver struct{}
+
+func (sqlDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("DSNs aren't supported, use sql.OpenDB(exasol.NewConnector(conf))")
+}
+
+type sqlConn struct {
+	conn *Conn
+}
+
+func (sc *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return &sqlStmt{sc: sc, query: query}, nil
+}
+
+func (sc *sqlConn) Close() error {
+	sc.conn.Disconnect()
+	return nil
+}
+
+func (sc *sqlConn) Begin() (driver.Tx, error) {
+	tx, err := sc.conn.Begin()
+	if err != nil {
+		return nil, err // Not a nil *Tx in a non-nil interface
+	}
+	return tx, nil
+}
+
+func (sc *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly {
+		return nil, errors.New("Read only transactions aren't supported")
+	}
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelSerializable:
+	default:
+		return nil, errors.New("Exasol only supports its default (serializable) isolation level")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sc.Begin()
+}
+
+func (sc *sqlConn) Ping(ctx context.Context) error {
+	if !sc.IsValid() {
+		return driver.ErrBadConn
+	}
+	defer watchContext(ctx, sc.conn)()
+	return ctxErr(ctx, sc.conn.Ping())
+}
+
+// Lets database/sql discard connections that have been disconnected
+// (or have seen a websocket error)
+func (sc *sqlConn) IsValid() bool { return sc.conn.IsAlive() }
+
+// Nothing is sent on a connection that's no longer valid so database/sql
+// can safely retry on another one when we return driver.ErrBadConn
+func (sc *sqlConn) ResetSession(ctx context.Context) error {
+	if !sc.IsValid() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (sc *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !sc.IsValid() {
+		return nil, driver.ErrBadConn
+	}
+	binds, err := sqlBinds(args)
+	if err != nil {
+		return nil, err
+	}
+	defer watchContext(ctx, sc.conn)()
+	n, err := sc.conn.Execute(query, binds...)
+	if err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return sqlResult(n), nil
+}
+
+func (sc *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !sc.IsValid() {
+		return nil, driver.ErrBadConn
+	}
+	binds, err := sqlBinds(args)
+	if err != nil {
+		return nil, err
+	}
+	stopWatch := watchContext(ctx, sc.conn)
+	stream, err := sc.conn.FetchStream(query, binds...)
+	if err != nil {
+		stopWatch()
+		return nil, ctxErr(ctx, err)
+	}
+	return &sqlRows{stream: stream, ctx: ctx, stopWatch: stopWatch}, nil
+}
+
+// Statements are prepared on the server as they're executed
+// (and cached if ConnConf.CachePrepStmts is set)
+type sqlStmt struct {
+	sc    *sqlConn
+	query string
+}
+
+func (s *sqlStmt) Close() error  { return nil }
+func (s *sqlStmt) NumInput() int { return -1 }
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.sc.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.sc.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.sc.ExecContext(ctx, s.query, args)
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.sc.QueryContext(ctx, s.query, args)
+}
+
+type sqlResult int64
+
+func (r sqlResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func (r sqlResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId isn't supported as Exasol doesn't report generated identities")
+}
+
+// The query's context is watched until the rows are closed or exhausted
+// since later batches of rows are fetched as they're read
+type sqlRows struct {
+	stream    *ResultStream
+	ctx       context.Context
+	stopWatch func()
+}
+
+func (r *sqlRows) Columns() []string {
+	names := make([]string, len(r.stream.columns))
+	for i, col := range r.stream.columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+func (r *sqlRows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.stream.columns[i].DataType.Type
+}
+
+func (r *sqlRows) Close() error {
+	r.stream.CloseEarly()
+	r.done()
+	return nil
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	row, ok := r.stream.Next()
+	if !ok {
+		r.done()
+		if err := r.stream.Err(); err != nil {
+			return ctxErr(r.ctx, err)
+		}
+		return io.EOF
+	}
+	for i := range dest {
+		if i < len(row) {
+			dest[i] = row[i]
+		}
+	}
+	return nil
+}
+
+func (r *sqlRows) done() {
+	if r.stopWatch != nil {
+		r.stopWatch()
+		r.stopWatch = nil
+	}
+}
+
+// Returns the optional binds arg for Execute/FetchStream. time.Time
+// values are left for Execute to format as per their column's type.
+func sqlBinds(args []driver.NamedValue) ([]interface{}, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	binds := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("Named args aren't supported (only ? placeholders): %s", arg.Name)
+		}
+		binds[i] = arg.Value
+	}
+	return []interface{}{binds}, nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// Aborts the running statement if ctx is done before the returned func is
+// called. Once that's returned there's no abort to hit a later statement.
+func watchContext(ctx context.Context, c *Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	var mux sync.Mutex
+	stopped := false
+	stop := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+			mux.Lock()
+			if !stopped {
+				c.Abort()
+			}
+			mux.Unlock()
+		case <-stop:
+		}
+	}()
+	return func() {
+		mux.Lock()
+		stopped = true
+		mux.Unlock()
+		close(stop)
+	}
+}
+
+// Reports the ctx's error in place of the resulting abort error
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package exasol
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *testSuite) TestConnector() {
+	db := sql.OpenDB(NewConnector(s.connConf()))
+	defer db.Close()
+	s.Require().NoError(db.Ping())
+
+	_, err := db.Exec("CREATE TABLE foo (id INT, name VARCHAR(10), ts TIMESTAMP)")
+	s.Require().NoError(err)
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	res, err := db.Exec("INSERT INTO foo VALUES (?, ?, ?)", 1, "a", ts)
+	s.Require().NoError(err)
+	n, err := res.RowsAffected()
+	s.NoError(err)
+	s.Equal(int64(1), n, "RowsAffected")
+
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	_, err = tx.Exec("INSERT INTO foo VALUES (?, ?, NULL)", 2, "b")
+	s.NoError(err)
+	s.NoError(tx.Rollback())
+
+	rows, err := db.Query("SELECT id, name, ts FROM foo WHERE id >= ? ORDER BY id", 1)
+	s.Require().NoError(err)
+	cols, err := rows.Columns()
+	s.NoError(err)
+	s.Equal([]string{"ID", "NAME", "TS"}, cols)
+	var got []string
+	for rows.Next() {
+		var id int
+		var name, ts string
+		s.NoError(rows.Scan(&id, &name, &ts))
+		got = append(got, name+" "+ts)
+	}
+	s.NoError(rows.Err())
+	s.Equal([]string{"a 2020-01-02 03:04:05.000000"}, got, "The rollback discarded b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	s.ErrorIs(err, context.Canceled)
+}
+
+func TestConnectorFake(t *testing.T) {
+	db := sql.OpenDB(NewConnector(ConnConf{
+		Host:      "fake",
+		Port:      8563,
+		Logger:    customTestLogger("error"),
+		WSHandler: &fakeWSHandler{},
+	}))
+	defer db.Close()
+	db.SetMaxOpenConns(1) // They'd share the fake
+
+	rows, err := db.Query("SELECT id, val FROM t")
+	require.NoError(t, err)
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "ID", types[0].Name())
+	assert.Equal(t, "VARCHAR", types[1].DatabaseTypeName())
+
+	var got []string
+	for rows.Next() {
+		var id int
+		var val string
+		require.NoError(t, rows.Scan(&id, &val))
+		got = append(got, val)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, []string{"a", "b"}, got)
+
+	_, err = db.Exec("DELETE FROM t WHERE id = :id", sql.Named("id", 1))
+	assert.EqualError(t, err, "Named args aren't supported (only ? placeholders): id")
+
+	_, err = sql.Open("exasol", "")
+	assert.Error(t, err, "No DSN driver is registered")
+}
+
+func TestConnectorBrokenConn(t *testing.T) {
+	wsh := &failingWSHandler{fakeWSHandler: fakeWSHandler{}}
+	db := sql.OpenDB(NewConnector(ConnConf{
+		Host:      "fake",
+		Port:      8563,
+		Logger:    customTestLogger("error"),
+		WSHandler: wsh,
+	}))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	rawConn := func() (conn *Conn) {
+		sc, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		defer sc.Close()
+		sc.Raw(func(dc interface{}) error {
+			conn = dc.(*sqlConn).conn
+			return nil
+		})
+		return conn
+	}
+
+	first := rawConn()
+	assert.Same(t, first, rawConn(), "Reused while healthy")
+	wsh.failRead = true
+	_, err := db.Exec("DELETE FROM t")
+	assert.Error(t, err)
+	wsh.failRead = false
+	assert.False(t, first.IsAlive())
+
+	// The broken connection is discarded rather than reused
+	assert.NotSame(t, first, rawConn())
+	_, err = db.Exec("DELETE FROM t")
+	assert.NoError(t, err)
+}
+
+func TestConnectorTimeBinds(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"getAttributes": `{"status":"ok","attributes":{"timezone":"EUROPE/BERLIN"}}`,
+		"createPreparedStatement": `{"status":"ok","responseData":{"statementHandle":1,
+			"parameterData":{"numColumns":2,"columns":[
+				{"name":"D","dataType":{"type":"DATE"}},
+				{"name":"L","dataType":{"type":"TIMESTAMP","withLocalTimeZone":true}}]}}}`,
+		"executePreparedStatement": `{"status":"ok","responseData":{"numResults":1,
+			"results":[{"resultType":"rowCount","rowCount":1}]}}`,
+	}}
+	db := sql.OpenDB(NewConnector(ConnConf{
+		Host:      "fake",
+		Port:      8563,
+		Logger:    customTestLogger("error"),
+		WSHandler: fake,
+	}))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	_, err := db.Exec("INSERT INTO t VALUES (?, ?)", ts, ts)
+	require.NoError(t, err)
+
+	var data interface{}
+	for _, r := range fake.requests {
+		if r["command"] == "executePreparedStatement" {
+			data = r["data"]
+		}
+	}
+	assert.Equal(t, []interface{}{
+		[]interface{}{"2021-03-04"},
+		[]interface{}{"2021-03-04 06:06:07.891"},
+	}, data, "Formatted as per each column's type")
+}
+
+func TestConnectorBeginTx(t *testing.T) {
+	db := sql.OpenDB(NewConnector(ConnConf{
+		Host:      "fake",
+		Port:      8563,
+		Logger:    customTestLogger("error"),
+		WSHandler: &fakeWSHandler{},
+	}))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	for _, level := range []sql.IsolationLevel{sql.LevelDefault, sql.LevelSerializable} {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: level})
+		if assert.NoError(t, err, level) {
+			assert.NoError(t, tx.Commit())
+		}
+	}
+	_, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	assert.EqualError(t, err, "Exasol only supports its default (serializable) isolation level")
+}
+
+// Signals each abortQuery request
+type abortWSHandler struct {
+	fakeWSHandler
+	aborted chan bool
+}
+
+func (a *abortWSHandler) WriteJSON(req interface{}) error {
+	err := a.fakeWSHandler.WriteJSON(req)
+	if r, ok := req.(*request); ok && r.Command == "abortQuery" {
+		a.aborted <- true
+	}
+	return err
+}
+
+func TestConnectorQueryContext(t *testing.T) {
+	wsh := &abortWSHandler{aborted: make(chan bool, 1)}
+	dc, err := NewConnector(ConnConf{
+		Host:      "fake",
+		Port:      8563,
+		Logger:    customTestLogger("error"),
+		WSHandler: wsh,
+	}).Connect(context.Background())
+	require.NoError(t, err)
+	defer dc.Close()
+	sc := dc.(*sqlConn)
+	aborted := func() bool {
+		select {
+		case <-wsh.aborted:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	// Still watched while the rows are being read
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := sc.QueryContext(ctx, "SELECT id, val FROM t", nil)
+	require.NoError(t, err)
+	cancel()
+	assert.True(t, aborted())
+	rows.Close()
+
+	// But not once they've all been read
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	rows, err = sc.QueryContext(ctx, "SELECT id, val FROM t", nil)
+	require.NoError(t, err)
+	dest := make([]driver.Value, 2)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	assert.Equal(t, io.EOF, err)
+	cancel()
+	assert.False(t, aborted())
+	rows.Close()
+}
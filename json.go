@@ -0,0 +1,139 @@
+/*
+	Rendering result sets as JSON, e.g. for an HTTP API:
+
+	err := conn.StreamJSON(w, "SELECT id, name FROM t WHERE id > ?", []interface{}{10})
+
+	writes [{"ID":11,"NAME":"a"},...] as the rows are fetched. Doubles and
+	integers are rendered as JSON numbers, DECIMALs with a scale (or too large
+	for a float64) as strings so clients don't lose precision, BOOLEANs as
+	true/false, NULLs as null and everything else as strings. Duplicate
+	column names give duplicate keys so alias them if needed.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*--- Public Interface ---*/
+
+// Writes the query's rows to w as a JSON array of objects keyed by column
+// name, streaming them as they're fetched. The optional args are the same
+// as for FetchChan. If an error occurs partway w is left with incomplete JSON.
+func (c *Conn) StreamJSON(w io.Writer, sql string, args ...interface{}) error {
+	stream, err := c.FetchStream(sql, args...)
+	if err != nil {
+		return err
+	}
+	defer stream.CloseEarly() // In case we stop early
+
+	keys := make([][]byte, len(stream.columns))
+	for i, col := range stream.columns {
+		keys[i], _ = json.Marshal(col.Name)
+	}
+	bw := bufio.NewWriter(w)
+	buf := []byte{'['}
+	for n := 0; ; n++ {
+		row, ok := stream.Next()
+		if !ok {
+			break
+		}
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '{')
+		for i, val := range row {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(append(buf, keys[i]...), ':')
+			buf, err = appendJSONValue(buf, stream.columns[i].DataType, val)
+			if err != nil {
+				return c.errorf("Unable to render column %s as JSON: %s", stream.columns[i].Name, err)
+			}
+		}
+		buf = append(buf, '}')
+		if _, err = bw.Write(buf); err != nil {
+			return c.errorf("Unable to write JSON: %s", err)
+		}
+		buf = buf[:0]
+	}
+	if err = stream.Err(); err != nil {
+		return err
+	}
+	if _, err = bw.Write(append(buf, ']')); err != nil {
+		return c.errorf("Unable to write JSON: %s", err)
+	}
+	if err = bw.Flush(); err != nil {
+		return c.errorf("Unable to write JSON: %s", err)
+	}
+	return nil
+}
+
+// The same as StreamJSON but returns the JSON. For large
+// result sets use StreamJSON to avoid buffering it all.
+func (c *Conn) FetchJSON(sql string, args ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.StreamJSON(&buf, sql, args...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*--- Private Routines ---*/
+
+func appendJSONValue(buf []byte, col DataType, val interface{}) ([]byte, error) {
+	if val == nil {
+		return append(buf, "null"...), nil
+	}
+	switch strings.ToUpper(col.Type) {
+	case "BOOLEAN":
+		if b, ok := val.(bool); ok {
+			return strconv.AppendBool(buf, b), nil
+		}
+	case "DOUBLE":
+		if f, ok := val.(float64); ok {
+			return strconv.AppendFloat(buf, f, 'g', -1, 64), nil
+		}
+	case "DECIMAL":
+		// Exasol sends those too large for a float64 as strings
+		f, ok := val.(float64)
+		if ok {
+			if err := checkExactDecimal(col, f); err != nil {
+				return buf, err
+			}
+		}
+		if ok && col.Scale == 0 {
+			return strconv.AppendFloat(buf, f, 'f', -1, 64), nil
+		}
+		if ok {
+			val = strconv.FormatFloat(f, 'f', col.Scale, 64)
+		}
+	}
+	if _, ok := val.(string); !ok {
+		val = fmt.Sprint(val)
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, b...), nil
+}
@@ -0,0 +1,65 @@
+package exasol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *testSuite) TestFetchJSON() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo (id INT, amt DECIMAL(10,2), big DECIMAL(36,0), d DOUBLE, b BOOLEAN, name VARCHAR(10))")
+	s.execute(`INSERT INTO foo VALUES (1, 12.50, 123456789012345678901234567890, 0.5, TRUE, 'a"b'),
+		(2, NULL, NULL, NULL, NULL, NULL)`)
+
+	got, err := exa.FetchJSON("SELECT * FROM foo WHERE id >= ? ORDER BY id", []interface{}{1})
+	s.Require().NoError(err)
+	s.JSONEq(`[
+		{"ID":1,"AMT":"12.50","BIG":"123456789012345678901234567890","D":0.5,"B":true,"NAME":"a\"b"},
+		{"ID":2,"AMT":null,"BIG":null,"D":null,"B":null,"NAME":null}
+	]`, string(got))
+
+	got, err = exa.FetchJSON("SELECT * FROM foo WHERE id < 0")
+	s.NoError(err)
+	s.Equal("[]", string(got))
+
+	exa.Conf.SuppressError = true
+	_, err = exa.FetchJSON("ASDF")
+	s.Error(err)
+}
+
+func TestStreamJSON(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"execute": `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"resultSetHandle":7,"numColumns":5,"numRows":2,
+			"numRowsInMessage":2,"columns":[
+				{"name":"ID","dataType":{"type":"DECIMAL","precision":18,"scale":0}},
+				{"name":"AMT","dataType":{"type":"DECIMAL","precision":10,"scale":2}},
+				{"name":"D","dataType":{"type":"DOUBLE"}},
+				{"name":"B","dataType":{"type":"BOOLEAN"}},
+				{"name":"N\"AME","dataType":{"type":"VARCHAR"}}
+			],"data":[[1,2],["12.50",3],[0.25,null],[true,false],["x",null]]}}]}}`,
+	}}
+	c := newFakeConn(t, ConnConf{Logger: customTestLogger("fatal")}, fake)
+	defer c.Disconnect()
+
+	var buf bytes.Buffer
+	require.NoError(t, c.StreamJSON(&buf, "SELECT * FROM t"))
+	assert.Equal(t, `[{"ID":1,"AMT":"12.50","D":0.25,"B":true,"N\"AME":"x"},`+
+		`{"ID":2,"AMT":"3.00","D":null,"B":false,"N\"AME":null}]`, buf.String())
+
+	c.Conf.SuppressError = true
+	err := c.StreamJSON(&failingWriter{written: 1}, "SELECT * FROM t")
+	assert.EqualError(t, err, "Unable to write JSON: Disk full")
+
+	fake.override["execute"] = `{"status":"ok","responseData":{"numResults":1,"results":[{
+		"resultType":"resultSet","resultSet":{"numColumns":1,"numRows":1,"numRowsInMessage":1,
+		"columns":[{"name":"ID","dataType":{"type":"DECIMAL","precision":18,"scale":0}}],
+		"data":[[999999999999999999]]}}]}}`
+	err = c.StreamJSON(&buf, "SELECT * FROM t")
+	if assert.Error(t, err, "Rather than render it wrong") {
+		assert.Contains(t, err.Error(), "Unable to render column ID as JSON")
+	}
+}
@@ -1,13 +1,16 @@
 package exasol
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"regexp"
 )
 
 // By default we'll only print out warnings, errors and fatals to stderr.
-// If you want anything else you'll need to pass in a custom logger to the
-// connection and it needs to conform to the following interface:
+// ConnConf.LogLevel changes the minimum level printed. For anything else
+// you'll need to pass in a custom logger to the connection and it needs to
+// conform to the following interface:
 
 type Logger interface {
 	Debug(...interface{})
@@ -25,20 +28,143 @@ type Logger interface {
 
 type defLogger struct {
 	logger *log.Logger
+	level  LogLevel // The minimum level printed
 }
 
-func newDefaultLogger() *defLogger {
-	return &defLogger{log.New(os.Stderr, "[exasol]", log.Lshortfile)}
+func newDefaultLogger(level LogLevel) *defLogger {
+	if level == 0 {
+		level = LogWarning
+	}
+	return &defLogger{log.New(os.Stderr, "[exasol]", log.Lshortfile), level}
 }
 
-func (l *defLogger) Debug(args ...interface{})              {}
-func (l *defLogger) Debugf(str string, args ...interface{}) {}
+func (l *defLogger) Debug(args ...interface{})              { l.print(LogDebug, args...) }
+func (l *defLogger) Debugf(str string, args ...interface{}) { l.printf(LogDebug, str, args...) }
 
-func (l *defLogger) Info(args ...interface{})              {}
-func (l *defLogger) Infof(str string, args ...interface{}) {}
+func (l *defLogger) Info(args ...interface{})              { l.print(LogInfo, args...) }
+func (l *defLogger) Infof(str string, args ...interface{}) { l.printf(LogInfo, str, args...) }
 
-func (l *defLogger) Warning(args ...interface{})              { l.logger.Print(args...) }
-func (l *defLogger) Warningf(str string, args ...interface{}) { l.logger.Printf(str, args...) }
+func (l *defLogger) Warning(args ...interface{})              { l.print(LogWarning, args...) }
+func (l *defLogger) Warningf(str string, args ...interface{}) { l.printf(LogWarning, str, args...) }
 
-func (l *defLogger) Error(args ...interface{})              { l.logger.Print(args...) }
-func (l *defLogger) Errorf(str string, args ...interface{}) { l.logger.Printf(str, args...) }
+func (l *defLogger) Error(args ...interface{})              { l.print(LogError, args...) }
+func (l *defLogger) Errorf(str string, args ...interface{}) { l.printf(LogError, str, args...) }
+
+// The call depth is that of the caller of the Logger method for Lshortfile
+func (l *defLogger) print(level LogLevel, args ...interface{}) {
+	if level >= l.level {
+		l.logger.Output(3, fmt.Sprint(args...))
+	}
+}
+
+func (l *defLogger) printf(level LogLevel, str string, args ...interface{}) {
+	if level >= l.level {
+		l.logger.Output(3, fmt.Sprintf(str, args...))
+	}
+}
+
+// Alternatively for structured logging (e.g. via zap or zerolog) you can
+// pass in a LevelLogger instead. It receives each message along with
+// key/value pairs of fields like the session_id, sql and duration.
+
+// The zero value is no level, e.g. ConnConf.LogLevel being unset
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota + 1
+	LogInfo
+	LogWarning
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarning:
+		return "warning"
+	case LogError:
+		return "error"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+type LevelLogger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// Adapts a LevelLogger to the Logger interface
+type levelLogger struct {
+	ll   LevelLogger
+	conn *Conn
+}
+
+func (l *levelLogger) Debug(args ...interface{}) { l.log(LogDebug, fmt.Sprint(args...)) }
+func (l *levelLogger) Debugf(str string, args ...interface{}) {
+	l.log(LogDebug, fmt.Sprintf(str, args...))
+}
+
+func (l *levelLogger) Info(args ...interface{}) { l.log(LogInfo, fmt.Sprint(args...)) }
+func (l *levelLogger) Infof(str string, args ...interface{}) {
+	l.log(LogInfo, fmt.Sprintf(str, args...))
+}
+
+func (l *levelLogger) Warning(args ...interface{}) { l.log(LogWarning, fmt.Sprint(args...)) }
+func (l *levelLogger) Warningf(str string, args ...interface{}) {
+	l.log(LogWarning, fmt.Sprintf(str, args...))
+}
+
+func (l *levelLogger) Error(args ...interface{}) { l.log(LogError, fmt.Sprint(args...)) }
+func (l *levelLogger) Errorf(str string, args ...interface{}) {
+	l.log(LogError, fmt.Sprintf(str, args...))
+}
+
+func (l *levelLogger) log(level LogLevel, msg string, keyvals ...interface{}) {
+	keyvals = append([]interface{}{"session_id", l.conn.SessionID}, keyvals...)
+	l.ll.Log(level, msg, keyvals...)
+}
+
+// Logs msg with the key/value fields. If there's no
+// LevelLogger the fields are appended to the message.
+func (c *Conn) logFields(level LogLevel, msg string, keyvals ...interface{}) {
+	if l, ok := c.log.(*levelLogger); ok {
+		l.log(level, msg, keyvals...)
+		return
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		msg += fmt.Sprintf(" %v=%v", keyvals[i], keyvals[i+1])
+	}
+	switch level {
+	case LogDebug:
+		c.log.Debug(msg)
+	case LogInfo:
+		c.log.Info(msg)
+	case LogWarning:
+		c.log.Warning(msg)
+	default:
+		c.log.Error(msg)
+	}
+}
+
+var (
+	isPassword = regexp.MustCompile(`(?i)(IDENTIFIED\s+BY\s+)("[^"]*"|'[^']*'|\S+)`)
+	isLiteral  = regexp.MustCompile(`'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+)
+
+// Prepares SQL for logging. Passwords are always masked then
+// ConnConf.RedactBinds, RedactSQL and LogSQLMaxLen are applied.
+func (c *Conn) logSQL(sql string) string {
+	sql = isPassword.ReplaceAllString(sql, "${1}***")
+	if c.Conf.RedactBinds {
+		sql = isLiteral.ReplaceAllString(sql, "?")
+	}
+	if c.Conf.RedactSQL != nil {
+		sql = c.Conf.RedactSQL(sql)
+	}
+	if max := c.Conf.LogSQLMaxLen; max > 0 && len(sql) > max {
+		sql = fmt.Sprintf("%s...(%d more bytes)", sql[:max], len(sql)-max)
+	}
+	return sql
+}
@@ -0,0 +1,24 @@
+package exasol
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultLoggerLevel(t *testing.T) {
+	logAll := func(l *defLogger) string {
+		buf := &bytes.Buffer{}
+		l.logger = log.New(buf, "", 0)
+		l.Debug("d")
+		l.Infof("%s", "i")
+		l.Warning("w")
+		l.Errorf("%s", "e")
+		return buf.String()
+	}
+	assert.Equal(t, "w\ne\n", logAll(newDefaultLogger(0)), "Defaults to warnings")
+	assert.Equal(t, "d\ni\nw\ne\n", logAll(newDefaultLogger(LogDebug)))
+	assert.Equal(t, "e\n", logAll(newDefaultLogger(LogError)))
+}
@@ -0,0 +1,133 @@
+/*
+	Bulk upserting CSV data via a staging table:
+
+	err := conn.BulkMerge(schema, "people", []string{"id"}, csvData)
+
+	The data is IMPORTed into a staging table created LIKE the target table,
+	MERGEd into the target on the key columns (rows with a matching key are
+	updated, the rest inserted) and then the staging table is dropped. This
+	is done in a single transaction so on any error it's all rolled back,
+	including the staging table's creation.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*--- Public Interface ---*/
+
+// The CSV data must have all of the table's columns in order (as for BulkInsert)
+func (c *Conn) BulkMerge(schema, table string, keyCols []string, data *bytes.Buffer) error {
+	if len(keyCols) == 0 {
+		return c.error("Unable to BulkMerge: no key columns given")
+	}
+	cols, err := c.tableColumns(schema, table)
+	if err != nil {
+		return c.errorf("Unable to BulkMerge: %w", err)
+	}
+	if len(cols) == 0 {
+		return c.errorf("Unable to BulkMerge: table %s.%s not found", schema, table)
+	}
+	isKey := map[string]bool{}
+	for _, k := range keyCols {
+		name := identName(k)
+		if !containsString(cols, name) {
+			return c.errorf("Unable to BulkMerge: key column %s not found in %s.%s", k, schema, table)
+		}
+		isKey[name] = true
+	}
+
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	// Rolling back also undoes creating the staging table
+	defer tx.Rollback()
+
+	target := c.QuoteIdent(schema) + "." + c.QuoteIdent(table)
+	stage := fmt.Sprintf("%s.GO_EXASOL_MERGE_%d", c.QuoteIdent(schema), time.Now().UnixNano())
+	_, err = tx.Execute(fmt.Sprintf("CREATE TABLE %s LIKE %s", stage, target))
+	if err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("IMPORT INTO %s FROM CSV AT '%%s' FILE 'data.csv'", stage)
+	if _, err = c.BulkExecute(sql, data); err != nil {
+		return err
+	}
+	_, err = tx.Execute(mergeSQL(target, stage, cols, isKey))
+	if err != nil {
+		return err
+	}
+	// Dropped before committing so it's never visible to other sessions
+	if _, err = tx.Execute("DROP TABLE " + stage); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*--- Private Routines ---*/
+
+// Returns the table's column names in order (as stored in the data dictionary)
+func (c *Conn) tableColumns(schema, table string) ([]string, error) {
+	info, err := c.describeTable(schema, table)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]string, len(info))
+	for i, col := range info {
+		cols[i] = col.Name
+	}
+	return cols, nil
+}
+
+func mergeSQL(target, stage string, cols []string, isKey map[string]bool) string {
+	var on, set, insertCols, values []string
+	for _, col := range cols {
+		q := quoteName(col)
+		if isKey[col] {
+			on = append(on, fmt.Sprintf("t.%s = s.%s", q, q))
+		} else {
+			set = append(set, fmt.Sprintf("t.%s = s.%s", q, q))
+		}
+		insertCols = append(insertCols, q)
+		values = append(values, "s."+q)
+	}
+	sql := fmt.Sprintf("MERGE INTO %s t USING %s s ON (%s)", target, stage, strings.Join(on, " AND "))
+	if len(set) > 0 {
+		sql += " WHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", ")
+	}
+	return sql + fmt.Sprintf(
+		" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		strings.Join(insertCols, ", "), strings.Join(values, ", "),
+	)
+}
+
+// Quotes a name exactly as stored in the data dictionary
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+/*
+	Query metrics and per-connection counters:
+
+	conf.Metrics = myPrometheusMetrics // Implements exasol.Metrics
+	...
+	snap := conn.Snapshot()
+	fmt.Println(snap["Queries"], snap["Errors"])
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"sync"
+	"time"
+)
+
+// By default no metrics are collected. If you want to export query
+// metrics (e.g. to Prometheus) you can pass in a custom metrics handler
+// to the connection and it needs to conform to the following interface:
+
+type Metrics interface {
+	// Called after every successful query or statement (including bulk
+	// IMPORTs and EXPORTs) with the number of rows affected, fetched
+	// or transferred.
+	OnQuery(sql string, duration time.Duration, rows int64)
+	// Called whenever a request to Exasol fails
+	OnError(err error)
+	// Called whenever the connection to Exasol is re-established
+	OnReconnect()
+}
+
+type defMetrics struct{}
+
+func newDefaultMetrics() *defMetrics {
+	return &defMetrics{}
+}
+
+func (m *defMetrics) OnQuery(sql string, duration time.Duration, rows int64) {}
+func (m *defMetrics) OnError(err error)                                      {}
+func (m *defMetrics) OnReconnect()                                           {}
+
+// Returns a consistent copy of the connection's counters:
+//
+//	Queries        Successful queries and statements (including bulk ones)
+//	Errors         Failed requests to Exasol
+//	Reconnects     Times the connection was re-established
+//	BytesImported  CSV data sent by the Stream/Bulk IMPORT methods
+//	BytesExported  CSV data received by the Stream/Bulk EXPORT methods
+//	StmtCacheLen   Prepared statements currently cached
+//	StmtCacheMiss  Prepared statements created for the cache
+//
+// Counters that haven't been incremented yet are absent.
+func (c *Conn) Snapshot() map[string]int {
+	return c.stats.snapshot()
+}
+
+// Safe for concurrent use since e.g. the Stream methods update
+// it from their own Go routines
+type connStats struct {
+	mux    sync.Mutex
+	counts map[string]int
+}
+
+func (s *connStats) add(name string, n int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.counts == nil {
+		s.counts = map[string]int{}
+	}
+	s.counts[name] += n
+}
+
+func (s *connStats) set(name string, n int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.counts == nil {
+		s.counts = map[string]int{}
+	}
+	s.counts[name] = n
+}
+
+func (s *connStats) snapshot() map[string]int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	snap := make(map[string]int, len(s.counts))
+	for name, n := range s.counts {
+		snap[name] = n
+	}
+	return snap
+}
+
+func (c *Conn) onError(err error) {
+	c.stats.add("Errors", 1)
+	c.metrics.OnError(err)
+}
+
+func (c *Conn) onReconnect() {
+	c.stats.add("Reconnects", 1)
+	c.metrics.OnReconnect()
+}
+
+// Reports a completed query to the metrics handler and the debug log
+func (c *Conn) onQuery(sql string, duration time.Duration, rows int64) {
+	c.stats.add("Queries", 1)
+	c.metrics.OnQuery(sql, duration, rows)
+	c.logFields(LogDebug, "Query completed", "sql", c.logSQL(sql), "duration", duration, "rows", rows)
+}
@@ -0,0 +1,168 @@
+/*
+	A Pool of connections for sharing across Go routines:
+
+	pool := exasol.NewPool(exasol.PoolConf{ConnConf: conf, MaxSize: 5})
+	defer pool.Close()
+
+	conn, err := pool.Get()
+	if err != nil { ... }
+	defer pool.Put(conn)
+
+	Connections are opened lazily and health-checked (via Conn.Ping)
+	before being handed out. Dead ones are discarded and replaced.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Returned by Pool.Get once the pool has been closed
+var ErrPoolClosed = errors.New("Pool closed")
+
+const defaultPoolMaxSize = 10
+
+type PoolConf struct {
+	ConnConf ConnConf // Used to open each connection
+	// Max number of open connections (defaults to 10). Get blocks while
+	// they're all in use. Exasol limits the number of sessions per cluster
+	// so size this accordingly.
+	MaxSize int
+	// Idle connections unused for longer than this are closed rather
+	// than reused (defaults to never)
+	IdleTimeout time.Duration
+}
+
+type Pool struct {
+	conf    PoolConf
+	mux     sync.Mutex
+	cond    *sync.Cond // Signalled whenever a connection is returned or discarded
+	idle    []*idleConn
+	numOpen int // Includes those being connected
+	closed  bool
+}
+
+type idleConn struct {
+	conn  *Conn
+	since time.Time
+}
+
+/*--- Public Interface ---*/
+
+func NewPool(conf PoolConf) *Pool {
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = defaultPoolMaxSize
+	}
+	p := &Pool{conf: conf}
+	p.cond = sync.NewCond(&p.mux)
+	return p
+}
+
+// Returns an idle connection if there is a healthy one otherwise
+// opens a new one, waiting for one to be Put back if the pool is full.
+func (p *Pool) Get() (*Conn, error) {
+	p.mux.Lock()
+	for {
+		if p.closed {
+			p.mux.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if n := len(p.idle); n > 0 {
+			// Most recently used first so the rest can idle out
+			ic := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mux.Unlock()
+			if p.isHealthy(ic) {
+				return ic.conn, nil
+			}
+			p.discard(ic.conn)
+			p.mux.Lock()
+			continue
+		}
+		if p.numOpen < p.conf.MaxSize {
+			p.numOpen++
+			p.mux.Unlock()
+			conn, err := Connect(p.conf.ConnConf)
+			if err != nil {
+				p.mux.Lock()
+				p.numOpen--
+				p.cond.Signal()
+				p.mux.Unlock()
+				return nil, err
+			}
+			return conn, nil
+		}
+		p.cond.Wait()
+	}
+}
+
+// Returns conn to the pool. Disconnected (or broken, see Conn.IsAlive)
+// connections are discarded so it's fine to Put one that's failed.
+func (p *Pool) Put(conn *Conn) {
+	if conn == nil {
+		return
+	}
+	connDead := !conn.IsAlive()
+	p.mux.Lock()
+	if p.closed || connDead {
+		p.mux.Unlock()
+		p.discard(conn)
+		return
+	}
+	p.idle = append(p.idle, &idleConn{conn: conn, since: time.Now()})
+	p.cond.Signal()
+	p.mux.Unlock()
+}
+
+// Disconnects the idle connections. Those in use are disconnected as
+// they're Put back. Any subsequent (or blocked) Get returns ErrPoolClosed.
+func (p *Pool) Close() {
+	p.mux.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.cond.Broadcast()
+	p.mux.Unlock()
+	for _, ic := range idle {
+		p.discard(ic.conn)
+	}
+}
+
+// Returns the number of open connections (both idle and in use)
+func (p *Pool) NumOpen() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.numOpen
+}
+
+/*--- Private Routines ---*/
+
+func (p *Pool) isHealthy(ic *idleConn) bool {
+	if p.conf.IdleTimeout > 0 && time.Since(ic.since) > p.conf.IdleTimeout {
+		return false
+	}
+	return ic.conn.Ping() == nil
+}
+
+func (p *Pool) discard(conn *Conn) {
+	conn.Conf.SuppressError = true // It's probably already dead
+	conn.Disconnect()
+	p.mux.Lock()
+	p.numOpen--
+	p.cond.Signal()
+	p.mux.Unlock()
+}
@@ -0,0 +1,72 @@
+package exasol
+
+import "time"
+
+func (s *testSuite) TestPool() {
+	pool := NewPool(PoolConf{ConnConf: s.connConf(), MaxSize: 2})
+	defer pool.Close()
+
+	c1, err := pool.Get()
+	s.Require().NoError(err)
+	c2, err := pool.Get()
+	s.Require().NoError(err)
+	s.NotEqual(c1.SessionID, c2.SessionID)
+	s.Equal(2, pool.NumOpen())
+
+	// Blocks until a connection is returned
+	got := make(chan *Conn)
+	go func() {
+		c, _ := pool.Get()
+		got <- c
+	}()
+	select {
+	case <-got:
+		s.Fail("Get should block when the pool is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	pool.Put(c1)
+	c3 := <-got
+	s.Equal(c1, c3, "Reused the returned connection")
+	s.Equal(2, pool.NumOpen())
+
+	// Dead connections are replaced
+	pool.Put(c2)
+	c2.Conf.SuppressError = true
+	c2.Disconnect()
+	c4, err := pool.Get()
+	s.Require().NoError(err)
+	s.NotEqual(c2, c4, "Dead connection discarded")
+	s.NoError(c4.Ping())
+	s.Equal(2, pool.NumOpen())
+
+	// Discarded once Disconnected
+	c4.Disconnect()
+	pool.Put(c4)
+	s.Equal(1, pool.NumOpen())
+
+	pool.Put(c3)
+	pool.Close()
+	s.Equal(0, pool.NumOpen())
+	_, err = pool.Get()
+	s.ErrorIs(err, ErrPoolClosed)
+}
+
+func (s *testSuite) TestPoolIdleTimeout() {
+	pool := NewPool(PoolConf{ConnConf: s.connConf(), IdleTimeout: 50 * time.Millisecond})
+	defer pool.Close()
+
+	c1, err := pool.Get()
+	s.Require().NoError(err)
+	pool.Put(c1)
+	c2, err := pool.Get()
+	s.Require().NoError(err)
+	s.Equal(c1, c2, "Reused before the idle timeout")
+	pool.Put(c2)
+
+	time.Sleep(100 * time.Millisecond)
+	c3, err := pool.Get()
+	s.Require().NoError(err)
+	s.NotEqual(c1, c3, "Idle connection was replaced")
+	s.Equal(1, pool.NumOpen())
+	pool.Put(c3)
+}
@@ -13,57 +13,144 @@
 package exasol
 
 import (
-	"sort"
-	"time"
+	"container/list"
+	"regexp"
+	"sync"
 )
 
 type prepStmt struct {
-	sth      int
-	columns  []column
-	lastUsed time.Time
+	sth        int
+	columns    []column
+	resultCols []column // Only set for statements returning a result set
+	users      int      // How many are executing it (guarded by the stmtCache's mux)
 }
 
-func (c *Conn) getPrepStmt(schema, sql string) (*prepStmt, error) {
+// Exasol is unhappy if there are thousands of open statements
+const defaultMaxPreparedStatements = 1000
+
+// An LRU cache of prepared statements. They're keyed by schema as well as
+// SQL as the statement's tables are resolved against the schema. It's safe
+// for concurrent use. Statements evicted while another Go routine is using
+// them are only handed back for closing once released.
+type stmtCache struct {
+	mux     sync.Mutex
+	max     int
+	order   *list.List // Of *stmtCacheEntry, most recently used first
+	stmts   map[stmtCacheKey]*list.Element
+	evicted map[*prepStmt]bool // Those evicted while in use
+}
+
+type stmtCacheKey struct {
+	schema string
+	sql    string
+}
+
+type stmtCacheEntry struct {
+	key stmtCacheKey
+	ps  *prepStmt
+}
+
+/*--- Public Interface ---*/
+
+// Returns the number of prepared statements in the cache (see ConnConf.CachePrepStmts)
+func (c *Conn) PreparedStatementCount() int {
+	return c.prepStmtCache.len()
+}
+
+// Closes the cached prepared statements freeing their server-side handles.
+// Other than the cost of re-preparing them it's safe to call at any time.
+func (c *Conn) ClearPreparedStatements() {
+	for _, ps := range c.prepStmtCache.clear() {
+		c.closePrepStmt(ps.sth)
+	}
+	c.stats.set("StmtCacheLen", 0)
+}
+
+/*--- Private Routines ---*/
+
+// The returned func must be called once done executing the statement. It's
+// passed whether the handle has gone away (so there's no point closing it).
+func (c *Conn) getPrepStmt(schema, sql string) (*prepStmt, func(gone bool), error) {
 	// TODO die if the num cols/rows expected by prepared statement
 	//      doesn't match the passed in data (i.e. placeholder/binds mismatch)
 	//      otherwise results in lowerlevel websocket closure
 
-	c.log.Debug("Preparing stmt for:", sql)
-	psc := c.prepStmtCache
-	ps := psc[sql]
-	if ps == nil {
-		var err error
-		ps, err = c.createPrepStmt(schema, sql)
-		if err != nil {
-			return nil, err
-		}
-		if c.Conf.CachePrepStmts {
-			psc[sql] = ps
-			c.Stats["StmtCacheLen"] = len(psc)
-			c.Stats["StmtCacheMiss"]++
+	c.logFields(LogDebug, "Preparing stmt", "sql", c.logSQL(sql))
+	key := c.stmtKey(schema, sql)
+	release := func(ps *prepStmt) func(bool) {
+		return func(gone bool) {
+			if c.prepStmtCache.release(ps) && !gone {
+				c.closePrepStmt(ps.sth)
+			}
 		}
 	}
-	ps.lastUsed = time.Now()
-
-	// Prune the prep stmt cache. I don't know how necessary it is
-	// but I saw something on the site about Exasol
-	// being unhappy if there are thousands of open statements.
-	if len(psc) > 1000 {
-		sortedStmts := make([]string, len(psc))
-		i := 0
-		for sql := range psc {
-			sortedStmts[i] = sql
-			i++
-		}
-		sort.Slice(sortedStmts, func(i, j int) bool {
-			return psc[sortedStmts[i]].lastUsed.Before(psc[sortedStmts[j]].lastUsed)
-		})
-		leastUsed := sortedStmts[0]
-		c.closePrepStmt(psc[leastUsed].sth)
-		delete(psc, leastUsed)
+	if ps := c.prepStmtCache.get(key); ps != nil {
+		return ps, release(ps), nil
 	}
+	ps, err := c.createPrepStmt(schema, sql)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !c.Conf.CachePrepStmts {
+		return ps, func(gone bool) {
+			if !gone {
+				c.closePrepStmt(ps.sth)
+			}
+		}, nil
+	}
+	if evicted := c.prepStmtCache.add(key, ps); evicted != nil {
+		c.closePrepStmt(evicted.sth)
+	}
+	c.stats.set("StmtCacheLen", c.prepStmtCache.len())
+	c.stats.add("StmtCacheMiss", 1)
+	return ps, release(ps), nil
+}
 
-	return ps, nil
+// Statements are keyed by the schema they were prepared against which,
+// if none was specified, is the session's current schema. Leading comments
+// are ignored so a QueryComment (e.g. a per-request trace ID) doesn't defeat
+// the cache, a cached statement keeping the comment it was prepared with.
+func (c *Conn) stmtKey(schema, sql string) stmtCacheKey {
+	if schema == "" {
+		schema = c.currentSchema
+	}
+	return stmtCacheKey{schema: schema, sql: leadingCommentsPrefix.ReplaceAllString(sql, "")}
+}
+
+var leadingCommentsPrefix = regexp.MustCompile(`(?s)^` + leadingComments + `\s*`)
+
+// SQL that may change the session's current schema (creating a schema opens it)
+var isSchemaChangeSQL = regexp.MustCompile(`(?is)^` + leadingComments + `\s*(OPEN|CLOSE|CREATE|DROP|RENAME)\s+SCHEMA\b`)
+
+// Called after each request that may have changed the current schema.
+// Exasol applies a request's schema to the session (even if the request
+// fails) so that becomes current. After SQL opening or closing a schema we
+// ask the server. Scripts can also change it but we don't try to detect that.
+func (c *Conn) trackCurrentSchema(schema string, err error, sqls ...string) {
+	if schema != "" {
+		c.currentSchema = schema
+	}
+	if err != nil {
+		return
+	}
+	for _, sql := range sqls {
+		if !isSchemaChangeSQL.MatchString(sql) {
+			continue
+		}
+		attrs, err := c.GetAttributes()
+		if err != nil {
+			// Rather than risk running statements against the wrong schema
+			c.log.Warning("Unable to get the current schema, clearing the prepared statement cache: ", err)
+			c.ClearPreparedStatements()
+			c.currentSchema = ""
+			return
+		}
+		c.currentSchema = ""
+		if attrs.CurrentSchema != nil {
+			c.currentSchema = *attrs.CurrentSchema
+		}
+		return
+	}
 }
 
 func (c *Conn) createPrepStmt(schema string, sql string) (*prepStmt, error) {
@@ -74,13 +161,20 @@ func (c *Conn) createPrepStmt(schema string, sql string) (*prepStmt, error) {
 	}
 	sthRes := &createPrepStmtRes{}
 	err := c.send(sthReq, sthRes)
+	c.trackCurrentSchema(schema, err)
 	if err != nil {
 		return nil, err
 	}
 
-	sth := sthRes.ResponseData.StatementHandle
-	cols := sthRes.ResponseData.ParameterData.Columns
-	return &prepStmt{sth, cols, time.Now()}, nil
+	data := sthRes.ResponseData
+	ps := &prepStmt{
+		sth:     data.StatementHandle,
+		columns: data.ParameterData.Columns,
+	}
+	if len(data.Results) > 0 && data.Results[0].ResultSet != nil {
+		ps.resultCols = data.Results[0].ResultSet.Columns
+	}
+	return ps, nil
 }
 
 func (c *Conn) closePrepStmt(sth int) error {
@@ -95,3 +189,117 @@ func (c *Conn) closePrepStmt(sth int) error {
 	}
 	return nil
 }
+
+func newStmtCache(max int) *stmtCache {
+	if max <= 0 {
+		max = defaultMaxPreparedStatements
+	}
+	return &stmtCache{
+		max:     max,
+		order:   list.New(),
+		stmts:   map[stmtCacheKey]*list.Element{},
+		evicted: map[*prepStmt]bool{},
+	}
+}
+
+// Returns nil if the statement isn't cached. Otherwise it's marked
+// as in use until released.
+func (sc *stmtCache) get(key stmtCacheKey) *prepStmt {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	el, ok := sc.stmts[key]
+	if !ok {
+		return nil
+	}
+	sc.order.MoveToFront(el)
+	ps := el.Value.(*stmtCacheEntry).ps
+	ps.users++
+	return ps
+}
+
+// Adds ps marked as in use until released, returning the least recently
+// used statement if it had to be evicted to make room (and isn't in use).
+// It's up to the caller to close it.
+func (sc *stmtCache) add(key stmtCacheKey, ps *prepStmt) (evicted *prepStmt) {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	ps.users++
+	if el, ok := sc.stmts[key]; ok {
+		// Another Go routine prepared it concurrently
+		entry := el.Value.(*stmtCacheEntry)
+		old := entry.ps
+		entry.ps = ps
+		sc.order.MoveToFront(el)
+		return sc.evict(old)
+	}
+	sc.stmts[key] = sc.order.PushFront(&stmtCacheEntry{key: key, ps: ps})
+	if sc.order.Len() > sc.max {
+		oldest := sc.order.Back()
+		entry := sc.order.Remove(oldest).(*stmtCacheEntry)
+		delete(sc.stmts, entry.key)
+		return sc.evict(entry.ps)
+	}
+	return nil
+}
+
+// Returns whether ps was evicted while in use so should now be closed
+func (sc *stmtCache) release(ps *prepStmt) bool {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	ps.users--
+	if ps.users > 0 || !sc.evicted[ps] {
+		return false
+	}
+	delete(sc.evicted, ps)
+	return true
+}
+
+// Removes a statement whose handle has gone away so mustn't be closed
+func (sc *stmtCache) remove(key stmtCacheKey) {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	if el, ok := sc.stmts[key]; ok {
+		sc.order.Remove(el)
+		delete(sc.stmts, key)
+	}
+}
+
+func (sc *stmtCache) len() int {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	return sc.order.Len()
+}
+
+// Empties the cache returning the statements that were in it
+// (other than those in use which are returned by release instead)
+func (sc *stmtCache) clear() []*prepStmt {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	stmts := make([]*prepStmt, 0, sc.order.Len())
+	for el := sc.order.Front(); el != nil; el = el.Next() {
+		if ps := sc.evict(el.Value.(*stmtCacheEntry).ps); ps != nil {
+			stmts = append(stmts, ps)
+		}
+	}
+	sc.order.Init()
+	sc.stmts = map[stmtCacheKey]*list.Element{}
+	return stmts
+}
+
+// Empties the cache after the session's been lost taking the handles with it
+func (sc *stmtCache) reset() {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	sc.order.Init()
+	sc.stmts = map[stmtCacheKey]*list.Element{}
+	sc.evicted = map[*prepStmt]bool{}
+}
+
+// Returns ps if it can be closed now. sc.mux must be held.
+func (sc *stmtCache) evict(ps *prepStmt) *prepStmt {
+	if ps.users > 0 {
+		sc.evicted[ps] = true
+		return nil
+	}
+	return ps
+}
@@ -0,0 +1,161 @@
+package exasol
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStmtCache(t *testing.T) {
+	sc := newStmtCache(2)
+	a := stmtCacheKey{schema: "S", sql: "SELECT ?"}
+	b := stmtCacheKey{schema: "T", sql: "SELECT ?"} // Same SQL, different schema
+	c := stmtCacheKey{schema: "S", sql: "SELECT ?, ?"}
+	// As if executed once
+	add := func(key stmtCacheKey, sth int) *prepStmt {
+		ps := &prepStmt{sth: sth}
+		evicted := sc.add(key, ps)
+		assert.False(t, sc.release(ps))
+		return evicted
+	}
+	use := func(key stmtCacheKey) int {
+		ps := sc.get(key)
+		if ps == nil {
+			return 0
+		}
+		assert.False(t, sc.release(ps))
+		return ps.sth
+	}
+
+	assert.Nil(t, sc.get(a))
+	assert.Nil(t, add(a, 1))
+	assert.Nil(t, add(b, 2))
+	assert.Equal(t, 1, use(a))
+	assert.Equal(t, 2, use(b))
+	assert.Equal(t, 2, sc.len())
+
+	// a is now the least recently used
+	assert.Equal(t, &prepStmt{sth: 1}, add(c, 3), "Evicted")
+	assert.Equal(t, 0, use(a))
+	assert.Equal(t, 2, sc.len())
+
+	// Those in use are only handed back once released
+	pb := sc.get(b)
+	assert.Equal(t, &prepStmt{sth: 3}, add(a, 4), "c is the least recently used")
+	assert.Nil(t, add(c, 5), "b is in use")
+	assert.Equal(t, 2, sc.len())
+	assert.True(t, sc.release(pb), "So close it now")
+	assert.Equal(t, 0, use(b))
+
+	sc.remove(a) // As if its handle had gone away
+	assert.Equal(t, 0, use(a))
+	pc := sc.get(c)
+	assert.Empty(t, sc.clear(), "c is in use")
+	assert.Equal(t, 0, sc.len())
+	assert.True(t, sc.release(pc))
+
+	add(a, 6)
+	assert.Equal(t, []*prepStmt{{sth: 6}}, sc.clear())
+
+	pc = &prepStmt{sth: 7}
+	sc.add(c, pc)
+	sc.reset()
+	assert.False(t, sc.release(pc), "Nothing to close after a reconnect")
+	assert.Equal(t, defaultMaxPreparedStatements, newStmtCache(0).max)
+}
+
+func TestStmtCacheConcurrent(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"createPreparedStatement": `{"status":"ok","responseData":{"statementHandle":1,
+			"parameterData":{"numColumns":1,"columns":[{"name":"A","dataType":{"type":"DECIMAL","precision":18}}]}}}`,
+		"executePreparedStatement": `{"status":"ok","responseData":{"numResults":1,
+			"results":[{"resultType":"rowCount","rowCount":1}]}}`,
+	}}
+	c := newFakeConn(t, ConnConf{
+		CachePrepStmts:        true,
+		MaxPreparedStatements: 2,
+	}, fake)
+	defer c.Disconnect()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				sql := fmt.Sprintf("INSERT INTO t%d VALUES (?)", (i+j)%4)
+				_, err := c.Execute(sql, [][]interface{}{{j}})
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, c.PreparedStatementCount(), 2)
+}
+
+func (s *testSuite) TestMaxPreparedStatements() {
+	conf := s.connConf()
+	conf.CachePrepStmts = true
+	conf.MaxPreparedStatements = 2
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+
+	for i := 1; i <= 3; i++ {
+		sql := fmt.Sprintf("SELECT %d FROM dual WHERE true = ?", i)
+		_, err := c.FetchSlice(sql, []interface{}{true})
+		s.NoError(err)
+	}
+	s.Equal(2, c.PreparedStatementCount())
+	s.Equal(2, c.Snapshot()["StmtCacheLen"])
+
+	_, err = c.FetchSlice("SELECT 1 FROM dual WHERE true = ?", []interface{}{true})
+	s.NoError(err, "The evicted statement is re-prepared")
+	s.Equal(4, c.Snapshot()["StmtCacheMiss"])
+}
+
+func (s *testSuite) TestPrepStmtCacheSchema() {
+	conf := s.connConf()
+	conf.CachePrepStmts = true
+	c, err := Connect(conf)
+	s.Require().NoError(err)
+	defer c.Disconnect()
+	for _, schema := range []string{"test_a", "test_b"} {
+		c.Execute("DROP SCHEMA IF EXISTS " + schema + " CASCADE")
+		c.Execute("CREATE SCHEMA " + schema)
+		c.Execute("CREATE TABLE t ( id INT )")
+		defer s.execute("DROP SCHEMA IF EXISTS " + schema + " CASCADE")
+	}
+	sql := "INSERT INTO t VALUES (?)"
+	count := func(schema string) interface{} {
+		got, _ := c.QueryScalar("SELECT COUNT(*) FROM " + schema + ".t")
+		return got
+	}
+
+	// Explicit schemas (which like currentSchema are case sensitive)
+	_, err = c.Execute(sql, [][]interface{}{{1}}, "TEST_A")
+	s.NoError(err)
+	_, err = c.Execute(sql, [][]interface{}{{2}}, "TEST_B")
+	s.NoError(err)
+	s.Equal(float64(1), count("test_a"))
+	s.Equal(float64(1), count("test_b"))
+
+	// The session's current schema
+	c.Execute("OPEN SCHEMA test_a")
+	_, err = c.Execute(sql, [][]interface{}{{3}})
+	s.NoError(err)
+	s.NoError(c.UseSchema("TEST_B"))
+	_, err = c.Execute(sql, [][]interface{}{{4}})
+	s.NoError(err)
+	c.Execute("OPEN SCHEMA test_a")
+	_, err = c.Execute(sql, [][]interface{}{{5}})
+	s.NoError(err)
+
+	got, _ := c.FetchSlice("SELECT id FROM test_a.t ORDER BY id")
+	s.Equal([][]interface{}{{float64(1)}, {float64(3)}, {float64(5)}}, got)
+	got, _ = c.FetchSlice("SELECT id FROM test_b.t ORDER BY id")
+	s.Equal([][]interface{}{{float64(2)}, {float64(4)}}, got)
+	s.Equal(2, c.PreparedStatementCount(), "One per schema")
+}
@@ -0,0 +1,142 @@
+/*
+	Profiling a statement to see where its time goes:
+
+	rows, err := conn.Profile("SELECT ... ")
+	for _, r := range rows {
+		fmt.Println(r.PartName, r.ObjectName, r.Duration)
+	}
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ProfileRow is one part (e.g. a scan or join) of a profiled statement's
+// execution as reported in EXA_USER_PROFILE_LAST_DAY.
+type ProfileRow struct {
+	PartID        int
+	PartName      string // e.g. "COMPILE / EXECUTE", "SCAN", "JOIN"
+	PartInfo      string // e.g. "on REPLICATED table"
+	ObjectSchema  string
+	ObjectName    string
+	ObjectRows    int64   // Number of rows in the object processed
+	OutRows       int64   // Number of result rows
+	Duration      float64 // Seconds
+	CPU           float64 // Percent
+	TempDBRAMPeak float64 // MiB
+	HDDRead       float64 // MiB/s
+	HDDWrite      float64 // MiB
+	Net           float64 // MiB/s
+	Remarks       string
+}
+
+/*--- Public Interface ---*/
+
+// Profile executes the statement with profiling enabled and returns its
+// profile (in part order). Any rows the statement returns are discarded.
+// The optional args are the same as for Execute. Profiling is switched
+// off for the session afterwards and the statistics are flushed
+// (FLUSH STATISTICS commits the current transaction).
+func (c *Conn) Profile(sql string, args ...interface{}) ([]ProfileRow, error) {
+	if _, err := c.Execute("ALTER SESSION SET PROFILE = 'ON'"); err != nil {
+		return nil, c.errorf("Unable to Profile: %w", err)
+	}
+	profiling := true
+	stopProfiling := func() error {
+		if !profiling {
+			return nil
+		}
+		profiling = false
+		_, err := c.Execute("ALTER SESSION SET PROFILE = 'OFF'")
+		return err
+	}
+	defer stopProfiling()
+
+	// The statement IDs of a session are sequential so the statement
+	// profiled is the first one after this
+	stmtID, err := c.QueryScalar("SELECT CURRENT_STATEMENT")
+	if err != nil {
+		return nil, c.errorf("Unable to Profile: %w", err)
+	}
+	if _, err := c.Execute(sql, args...); err != nil {
+		return nil, err
+	}
+	if err := stopProfiling(); err != nil {
+		return nil, c.errorf("Unable to Profile: %w", err)
+	}
+	if _, err := c.Execute("FLUSH STATISTICS"); err != nil {
+		return nil, c.errorf("Unable to Profile: %w", err)
+	}
+
+	data, err := c.FetchSlice(`
+		SELECT part_id, part_name, part_info, object_schema, object_name,
+			object_rows, out_rows, duration, cpu, temp_db_ram_peak,
+			hdd_read, hdd_write, net, remarks
+		FROM exa_statistics.exa_user_profile_last_day
+		WHERE session_id = CURRENT_SESSION
+		AND stmt_id = (
+			SELECT MIN(stmt_id) FROM exa_statistics.exa_user_profile_last_day
+			WHERE session_id = CURRENT_SESSION AND stmt_id > ?
+		)
+		ORDER BY part_id
+	`, []interface{}{stmtID})
+	if err != nil {
+		return nil, c.errorf("Unable to Profile: %w", err)
+	}
+
+	rows := make([]ProfileRow, len(data))
+	for i, d := range data {
+		rows[i] = ProfileRow{
+			PartID:        int(profileFloat(d[0])),
+			PartName:      profileString(d[1]),
+			PartInfo:      profileString(d[2]),
+			ObjectSchema:  profileString(d[3]),
+			ObjectName:    profileString(d[4]),
+			ObjectRows:    int64(profileFloat(d[5])),
+			OutRows:       int64(profileFloat(d[6])),
+			Duration:      profileFloat(d[7]),
+			CPU:           profileFloat(d[8]),
+			TempDBRAMPeak: profileFloat(d[9]),
+			HDDRead:       profileFloat(d[10]),
+			HDDWrite:      profileFloat(d[11]),
+			Net:           profileFloat(d[12]),
+			Remarks:       profileString(d[13]),
+		}
+	}
+	return rows, nil
+}
+
+/*--- Private Routines ---*/
+
+// NULLs become the zero value
+func profileString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func profileFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case string: // Wide DECIMALs may be returned as strings
+		f, _ := strconv.ParseFloat(x, 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package exasol
+
+func (s *testSuite) TestProfile() {
+	s.execute("CREATE TABLE foo ( id INT, val CHAR(1) )")
+	s.execute("INSERT INTO foo VALUES (1,'a'),(2,'b'),(3,'c')")
+
+	rows, err := s.exaConn.Profile("SELECT * FROM foo WHERE id > ?", []interface{}{1})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(rows)
+	var scanned bool
+	for i, r := range rows {
+		s.Equal(i+1, r.PartID, "In part order")
+		if r.PartName == "SCAN" && r.ObjectName == "FOO" {
+			scanned = true
+			s.Equal(int64(3), r.ObjectRows)
+			s.Equal(int64(2), r.OutRows)
+		}
+	}
+	s.True(scanned, "Profiled the scan of FOO")
+
+	got, _ := s.exaConn.QueryScalar("SELECT SESSION_PARAMETER(CURRENT_SESSION, 'PROFILE')")
+	s.Equal("OFF", got, "Profiling switched off")
+
+	s.exaConn.Conf.SuppressError = true
+	_, err = s.exaConn.Profile("SELECT * FROM asdf")
+	s.Error(err)
+}
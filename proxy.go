@@ -2,6 +2,11 @@
 	This sets up the proxy server connection that Exasol
 	uses for doing bulk IMPORTs and EXPORTs
 
+	The proxy is a tunnel over a connection we make out to Exasol.
+	Exasol replies with the internal host:port it's listening on
+	which gets embedded in the IMPORT/EXPORT SQL so Exasol never
+	connects back to the client and no inbound ports need opening.
+
     AUTHOR
 
 	Grant Street Group <developers@grantstreet.com>
@@ -18,33 +23,92 @@ package exasol
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Proxy struct {
+	// When data last moved (UnixNano). Accessed atomically so it's
+	// first to keep it 64-bit aligned on 32-bit platforms.
+	lastActive int64
+
 	Host string
 	Port uint32
+	// If set Read only sends complete CSV rows down the data chan
+	RowAligned bool
+
+	conn     net.Conn
+	running  bool
+	pool     *sync.Pool
+	log      Logger
+	partial  []byte          // Incomplete trailing row when RowAligned
+	inQuote  bool            // Whether we're inside a quoted CSV field when RowAligned
+	srcErr   func() error    // Checked by Write before sending the final chunk
+	progress func(int64)     // Called by Write with the bytes written so far
+	done     <-chan struct{} // Closed to stop Read/Write (e.g. a cancelled context)
+}
+
+// ProxyDialError is returned by the Stream/Bulk methods when the proxy
+// connection couldn't be set up. Either we couldn't connect to Exasol or
+// (if Remote) Exasol reported it couldn't connect to its end of the proxy
+// before any data was transferred. The latter is retried by default.
+type ProxyDialError struct {
+	Addr   string // The address we dialed (empty if Remote)
+	Remote bool
+	Err    error
+}
+
+func (e *ProxyDialError) Error() string { return e.Err.Error() }
+func (e *ProxyDialError) Unwrap() error { return e.Err }
 
-	conn    net.Conn
-	running bool
-	pool    *sync.Pool
-	log     Logger
+// ProxyReadError is returned by the export methods when receiving
+// the data over the proxy fails (after BytesRead bytes)
+type ProxyReadError struct {
+	BytesRead int64
+	Err       error
 }
 
+func (e *ProxyReadError) Error() string { return e.Err.Error() }
+func (e *ProxyReadError) Unwrap() error { return e.Err }
+
+// ProxyWriteError is returned by the import methods when sending
+// the data over the proxy fails (after BytesWritten bytes)
+type ProxyWriteError struct {
+	BytesWritten int64
+	Err          error
+}
+
+func (e *ProxyWriteError) Error() string { return e.Err.Error() }
+func (e *ProxyWriteError) Unwrap() error { return e.Err }
+
 func NewProxy(host string, port uint16, bufPool *sync.Pool, log Logger) (*Proxy, error) {
+	return newProxy(nil, "", host, port, bufPool, log)
+}
+
+// As NewProxy but dialing with the given dialer and network (both optional)
+func newProxy(dialer *net.Dialer, network, host string, port uint16, bufPool *sync.Pool, log Logger) (*Proxy, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	if network == "" {
+		network = "tcp"
+	}
 	p := &Proxy{
 		pool: bufPool,
 		log:  log,
 	}
 
 	var err error
-	uri := fmt.Sprintf("%s:%d", host, port)
-	p.conn, err = net.Dial("tcp", uri)
+	uri := net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(int(port)))
+	p.conn, err = dialer.Dial(network, uri)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to setup proxy (1): %s", err)
+		return nil, &ProxyDialError{Addr: uri, Err: fmt.Errorf("Unable to setup proxy (1): %w", err)}
 	}
 	p.running = true
 
@@ -55,14 +119,14 @@ func NewProxy(host string, port uint16, bufPool *sync.Pool, log Logger) (*Proxy,
 	binary.LittleEndian.PutUint32(req[8:], 1)
 	_, err = p.conn.Write(req)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to setup proxy (2): %s", err)
+		return nil, &ProxyDialError{Addr: uri, Err: fmt.Errorf("Unable to setup proxy (2): %w", err)}
 	}
 
 	// Exasol replies with the internal host/port it's listening on
 	resp := make([]byte, 24)
 	_, err = p.conn.Read(resp)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to setup proxy (3): %s", err)
+		return nil, &ProxyDialError{Addr: uri, Err: fmt.Errorf("Unable to setup proxy (3): %w", err)}
 	}
 
 	p.Port = binary.LittleEndian.Uint32(resp[4:])
@@ -75,7 +139,7 @@ func NewProxy(host string, port uint16, bufPool *sync.Pool, log Logger) (*Proxy,
 func (p *Proxy) Read(data chan<- []byte, stop <-chan bool) (int64, error) {
 	_, err := p.readHeaders()
 	if err != nil {
-		return 0, err
+		return 0, &ProxyReadError{Err: err}
 	}
 
 	p.sendHeaders([]string{
@@ -90,17 +154,19 @@ DATA:
 	for {
 		chunkSize, err := p.readLine()
 		if err != nil {
-			return totalRead, fmt.Errorf("Unable to read from proxy(2): %s", err)
+			return totalRead, &ProxyReadError{totalRead, fmt.Errorf("Unable to read from proxy(2): %w", err)}
 		}
 
 		chunkLen, err := strconv.ParseInt(string(chunkSize), 16, 64)
 		if err != nil {
-			return totalRead, fmt.Errorf("Unable to parse chunkSize %s: %s", chunkSize, err)
+			return totalRead, &ProxyReadError{totalRead, fmt.Errorf("Unable to parse chunkSize %s: %w", chunkSize, err)}
 		}
 		chunk := p.pool.Get().([]byte)
 		if chunkLen > int64(cap(chunk)) {
-			p.log.Warningf("Proxy chunk len %d > buffer cap %d", chunkLen, cap(chunk))
-			chunk = make([]byte, chunkLen)
+			if chunkLen > proxyBufSize {
+				p.log.Warningf("Proxy chunk len %d > buffer size %d", chunkLen, proxyBufSize)
+			}
+			chunk = make([]byte, chunkLen, maxInt64(chunkLen, proxyBufSize))
 		} else if chunkLen != int64(len(chunk)) {
 			chunk = chunk[:chunkLen]
 		}
@@ -109,7 +175,7 @@ DATA:
 		for {
 			l, err := p.conn.Read(chunk[readLen:])
 			if err != nil {
-				return totalRead, fmt.Errorf("Unable to read from proxy(3): %s", err)
+				return totalRead, &ProxyReadError{totalRead, fmt.Errorf("Unable to read from proxy(3): %w", err)}
 			}
 			readLen += l
 			if int64(readLen) == chunkLen {
@@ -117,8 +183,10 @@ DATA:
 			}
 		}
 		endOfChunk, err := p.readLine()
-		if len(endOfChunk) != 0 || err != nil {
-			return totalRead, fmt.Errorf("Unable to read from proxy(4):%s/%s", endOfChunk, err)
+		if err != nil {
+			return totalRead, &ProxyReadError{totalRead, fmt.Errorf("Unable to read from proxy(4):%s/%w", endOfChunk, err)}
+		} else if len(endOfChunk) != 0 {
+			return totalRead, fmt.Errorf("Unable to read from proxy(4):%s/expected the end of the chunk", endOfChunk)
 		}
 
 		if chunkLen == 0 {
@@ -128,14 +196,35 @@ DATA:
 				"Content-Length: 0",
 				"Connection: close",
 			})
+			if len(p.partial) > 0 {
+				// The final row had no trailing row separator
+				select {
+				case <-stop:
+					p.Shutdown()
+				case <-p.done:
+					p.Shutdown()
+				case data <- p.partial:
+				}
+				p.partial = nil
+			}
 			break
 		}
 
 		totalRead += chunkLen
+		p.touch()
+		if p.RowAligned {
+			chunk = p.alignRows(chunk)
+			if chunk == nil {
+				continue // No complete row yet
+			}
+		}
 		select {
 		case <-stop:
 			p.Shutdown()
 			break DATA
+		case <-p.done:
+			p.Shutdown()
+			break DATA
 		case data <- chunk:
 		}
 	}
@@ -143,10 +232,12 @@ DATA:
 	return totalRead, nil
 }
 
+var errProxyStopped = errors.New("Proxy stopped")
+
 func (p *Proxy) Write(data <-chan []byte) (bytesWritten int64, err error) {
 	_, err = p.readHeaders()
 	if err != nil {
-		return bytesWritten, err
+		return bytesWritten, &ProxyWriteError{Err: err}
 	}
 
 	err = p.sendHeaders([]string{
@@ -158,9 +249,21 @@ func (p *Proxy) Write(data <-chan []byte) (bytesWritten int64, err error) {
 	})
 
 	if err != nil {
-		err = fmt.Errorf("Unable to send headers to proxy: %s", err)
+		err = &ProxyWriteError{Err: fmt.Errorf("Unable to send headers to proxy: %w", err)}
 	} else {
-		for b := range data {
+	DATA:
+		for {
+			var b []byte
+			var ok bool
+			select {
+			case <-p.done:
+				// Without the final chunk Exasol won't commit the partial data
+				return bytesWritten, errProxyStopped
+			case b, ok = <-data:
+			}
+			if !ok {
+				break DATA
+			}
 			l := int64(len(b))
 			bytesWritten += l
 			chunkSize := strconv.FormatInt(l, 16)
@@ -168,12 +271,25 @@ func (p *Proxy) Write(data <-chan []byte) (bytesWritten int64, err error) {
 			p.conn.Write([]byte("\r\n"))
 			_, err = p.conn.Write(b)
 			if err != nil {
-				err = fmt.Errorf("Unable to upload data to proxy (2): %s", err)
-				break
+				err = &ProxyWriteError{bytesWritten, fmt.Errorf("Unable to upload data to proxy (2): %w", err)}
+				break DATA
 			}
 			p.conn.Write([]byte("\r\n"))
+			p.touch()
+			if p.progress != nil {
+				p.progress(bytesWritten)
+			}
+		}
+		if err == nil && p.srcErr != nil {
+			// Without the final chunk Exasol won't commit the incomplete data
+			if e := p.srcErr(); e != nil {
+				return bytesWritten, fmt.Errorf("Unable to read data to upload: %w", e)
+			}
 		}
 		p.conn.Write([]byte("0\r\n\r\n")) // A final zero chunk
+		if err == nil && p.progress != nil {
+			p.progress(bytesWritten)
+		}
 	}
 	return bytesWritten, err
 }
@@ -193,12 +309,99 @@ func (p *Proxy) IsRunning() bool {
 
 /* Private routines */
 
+func (p *Proxy) touch() {
+	atomic.StoreInt64(&p.lastActive, time.Now().UnixNano())
+}
+
+// Returns a chan that's closed once no data has moved over the proxy for
+// timeout and a func to stop watching. This lets long but progressing bulk
+// operations run rather than being killed at a fixed deadline. With no
+// timeout the chan is never closed.
+func (p *Proxy) idleTimeout(timeout time.Duration) (<-chan struct{}, func()) {
+	if timeout <= 0 {
+		return nil, func() {}
+	}
+	idle := make(chan struct{})
+	stop := make(chan struct{})
+	p.touch()
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+			last := time.Unix(0, atomic.LoadInt64(&p.lastActive))
+			left := timeout - time.Since(last)
+			if left <= 0 {
+				close(idle)
+				return
+			}
+			timer.Reset(left)
+		}
+	}()
+	return idle, func() { close(stop) }
+}
+
+// Returns the complete CSV rows in the chunk (prefixed by any partial row
+// left over from the previous chunk) and holds onto the trailing partial row.
+// Returns nil if the chunk doesn't complete a row.
+func (p *Proxy) alignRows(chunk []byte) []byte {
+	rowEnd := -1
+	for i, b := range chunk {
+		if b == '"' {
+			// Escaped quotes are doubled so toggling still works out
+			p.inQuote = !p.inQuote
+		} else if b == '\n' && !p.inQuote {
+			rowEnd = i + 1
+		}
+	}
+
+	if rowEnd < 0 {
+		p.partial = append(p.partial, chunk...)
+		recycleBuf(p.pool, chunk)
+		return nil
+	}
+
+	// The remainder has to be copied out because the
+	// chunk buffer will be returned to the pool by the consumer
+	remainder := append([]byte(nil), chunk[rowEnd:]...)
+	rows := chunk[:rowEnd]
+	if len(p.partial) > 0 {
+		rows = append(p.partial, rows...)
+		recycleBuf(p.pool, chunk)
+	}
+	p.partial = nil
+	if len(remainder) > 0 {
+		p.partial = remainder
+	}
+	return rows
+}
+
+// Only full sized buffers are returned to the pool so that
+// (e.g. RowAligned) slices allocated elsewhere don't end up in it
+func recycleBuf(pool *sync.Pool, b []byte) {
+	if cap(b) == proxyBufSize {
+		pool.Put(b[:proxyBufSize])
+	}
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func (p *Proxy) readLine() ([]byte, error) {
 	var line bytes.Buffer
+	var length int
 	var err error
 	b := make([]byte, 1)
 	for {
-		length, err := p.conn.Read(b)
+		length, err = p.conn.Read(b)
 		if err != nil || length == 0 {
 			break
 		} else if b[0] == '\r' {
@@ -222,7 +425,7 @@ func (p *Proxy) sendHeaders(headers []string) error {
 		p.log.Debug("Sent Header: ", header)
 		_, err := p.conn.Write([]byte(header))
 		if err != nil {
-			return fmt.Errorf("Unable to send header <%s>to proxy: %s", header, err)
+			return fmt.Errorf("Unable to send header <%s>to proxy: %w", header, err)
 		}
 	}
 	return nil
@@ -232,7 +435,7 @@ func (p *Proxy) readHeaders() (headers []string, err error) {
 	for {
 		line, err := p.readLine()
 		if err != nil {
-			return headers, fmt.Errorf("Unable to read from proxy(1): %s", err)
+			return headers, fmt.Errorf("Unable to read from proxy(1): %w", err)
 		}
 		p.log.Debug("Got header:", string(line))
 		// Blank line means end of headers
@@ -0,0 +1,252 @@
+package exasol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Plays the part of Exasol sending numChunks full sized chunks to the proxy
+func fakeExport(conn net.Conn, numChunks int) {
+	go io.Copy(ioutil.Discard, conn) // The proxy's response headers
+	w := bufio.NewWriter(conn)
+	fmt.Fprint(w, "PUT /data.csv HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n")
+	chunk := make([]byte, proxyBufSize)
+	for i := 0; i < numChunks; i++ {
+		fmt.Fprintf(w, "%x\r\n", len(chunk))
+		w.Write(chunk)
+		fmt.Fprint(w, "\r\n")
+	}
+	fmt.Fprint(w, "0\r\n\r\n")
+	w.Flush()
+}
+
+func proxyRead(numChunks int, recycle bool) int64 {
+	client, server := net.Pipe()
+	defer client.Close()
+	go fakeExport(server, numChunks)
+
+	p := &Proxy{conn: client, running: true, pool: &bufPool, log: customTestLogger("error")}
+	rows := &Rows{}
+	data := make(chan []byte)
+	done := make(chan int64)
+	go func() {
+		n, _ := p.Read(data, make(chan bool))
+		close(data)
+		done <- n
+	}()
+	for chunk := range data {
+		if recycle {
+			rows.Recycle(chunk)
+		}
+	}
+	return <-done
+}
+
+func TestRowsRecycle(t *testing.T) {
+	assert.Equal(t, int64(3*proxyBufSize), proxyRead(3, true))
+
+	rows := &Rows{}
+	rows.Recycle(make([]byte, 10)) // Not from the pool so it's ignored
+	rows.Recycle(make([]byte, 10, proxyBufSize)[:10])
+	for i := 0; i < 10; i++ {
+		got := bufPool.Get().([]byte)
+		assert.Equal(t, proxyBufSize, len(got), "Only full sized buffers are pooled")
+	}
+}
+
+// Compare the B/op of these to see the buffers being reused
+func BenchmarkProxyReadRecycled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		proxyRead(100, true)
+	}
+}
+
+func BenchmarkProxyReadNotRecycled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		proxyRead(100, false)
+	}
+}
+
+// Plays the part of Exasol replying to the proxy's setup request with the
+// host:port it's listening on. The peer's address is sent down the chan.
+func fakeProxyServer(t *testing.T, network, addr, replyHost string) (uint16, <-chan net.Addr) {
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("Unable to listen on %s: %s", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	peers := make(chan net.Addr, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		peers <- conn.RemoteAddr()
+		io.ReadFull(conn, make([]byte, 12))
+		resp := make([]byte, 24)
+		binary.LittleEndian.PutUint32(resp[4:], 20001)
+		copy(resp[8:], replyHost)
+		conn.Write(resp)
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return uint16(n), peers
+}
+
+func TestProxyDial(t *testing.T) {
+	log := customTestLogger("error")
+
+	port, peers := fakeProxyServer(t, "tcp4", "127.0.0.1:0", "fd00::17")
+	local := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}}
+	p, err := newProxy(local, "tcp4", "127.0.0.1", port, &bufPool, log)
+	require.NoError(t, err)
+	defer p.Shutdown()
+	assert.Equal(t, "127.0.0.1", (<-peers).(*net.TCPAddr).IP.String(), "Bound the local address")
+	assert.Equal(t, "fd00::17", p.Host, "Exasol's advertised IPv6 address")
+	assert.Equal(t, uint32(20001), p.Port)
+
+	_, err = newProxy(nil, "tcp6", "127.0.0.1", port, &bufPool, log)
+	assert.Error(t, err, "An IPv4 address can't be dialed as tcp6")
+
+	port, peers = fakeProxyServer(t, "tcp6", "[::1]:0", "10.0.0.17")
+	p, err = NewProxy("[::1]", port, &bufPool, log)
+	require.NoError(t, err, "Bracketed IPv6 literals are accepted")
+	defer p.Shutdown()
+	assert.Equal(t, "::1", (<-peers).(*net.TCPAddr).IP.String())
+	assert.Equal(t, "10.0.0.17", p.Host)
+}
+
+func TestStreamWriterClosedConn(t *testing.T) {
+	c := newFakeConn(t, ConnConf{Logger: customTestLogger("fatal")}, &fakeWSHandler{})
+	var err error
+	c.Disconnect()
+
+	w := c.StreamExecuteWriter("IMPORT INTO t FROM CSV AT '%s' FILE 'data.csv'")
+	for err == nil {
+		_, err = w.Write([]byte("1\n")) // Until the IMPORT's failure is seen
+	}
+	assert.ErrorIs(t, err, ErrConnClosed)
+	assert.ErrorIs(t, w.Close(), ErrConnClosed)
+	assert.ErrorIs(t, w.Close(), ErrConnClosed, "Closing twice is fine")
+	_, err = w.Write([]byte("1\n"))
+	assert.ErrorIs(t, err, ErrConnClosed, "Writing after Close fails rather than panicking")
+	w.Cancel()
+}
+
+func TestStreamExecuteRedactsSQL(t *testing.T) {
+	c := newFakeConn(t, ConnConf{
+		Logger:    customTestLogger("fatal"),
+		RedactSQL: func(string) string { return "<redacted>" },
+	}, &fakeWSHandler{})
+	c.Disconnect()
+
+	_, err := c.StreamExecute("IMPORT INTO t FROM CSV AT '%s' USER 'me' IDENTIFIED BY 'secret' FILE 'data.csv'", make(chan []byte))
+	assert.ErrorIs(t, err, ErrConnClosed)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Contains(t, err.Error(), "<redacted>")
+}
+
+func TestProxyErrors(t *testing.T) {
+	log := customTestLogger("fatal")
+
+	// Nothing's listening on the port once the listener's closed
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	_, port, _ := net.SplitHostPort(addr)
+	n, _ := strconv.Atoi(port)
+	_, err = NewProxy("127.0.0.1", uint16(n), &bufPool, log)
+	var dialErr *ProxyDialError
+	if assert.ErrorAs(t, err, &dialErr) {
+		assert.Equal(t, addr, dialErr.Addr)
+		assert.False(t, dialErr.Remote)
+		assert.Contains(t, err.Error(), "Unable to setup proxy (1)")
+		assert.False(t, retryableError(err), "We couldn't reach Exasol")
+	}
+
+	// The export stops partway through a chunk
+	client, server := net.Pipe()
+	go func() {
+		go io.Copy(ioutil.Discard, server)
+		fmt.Fprint(server, "PUT /data.csv HTTP/1.1\r\n\r\n4\r\n1\n2\n\r\n4\r\n3\n")
+		server.Close()
+	}()
+	p := &Proxy{conn: client, running: true, pool: &bufPool, log: log}
+	data := make(chan []byte, 10)
+	_, err = p.Read(data, make(chan bool))
+	var readErr *ProxyReadError
+	if assert.ErrorAs(t, err, &readErr) {
+		assert.Equal(t, int64(4), readErr.BytesRead)
+		assert.ErrorIs(t, err, io.EOF)
+	}
+
+	// The export stops between chunks
+	client, server = net.Pipe()
+	go func() {
+		go io.Copy(ioutil.Discard, server)
+		fmt.Fprint(server, "PUT /data.csv HTTP/1.1\r\n\r\n4\r\n1\n2\n\r\n")
+		server.Close()
+	}()
+	p = &Proxy{conn: client, running: true, pool: &bufPool, log: log}
+	_, err = p.Read(data, make(chan bool))
+	if assert.ErrorAs(t, err, &readErr) {
+		assert.Equal(t, int64(4), readErr.BytesRead)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Contains(t, err.Error(), "Unable to read from proxy(2)")
+	}
+
+	// Exasol couldn't connect to its end of the proxy
+	refused := fmt.Errorf("%w: Connection to proxy failed after 0 bytes: Connection refused", errServer)
+	err = remoteProxyError(refused)
+	if assert.ErrorAs(t, err, &dialErr) {
+		assert.True(t, dialErr.Remote)
+		assert.ErrorIs(t, err, errServer)
+		assert.True(t, retryableError(err))
+	}
+	other := fmt.Errorf("%w: syntax error", errServer)
+	assert.Equal(t, other, remoteProxyError(other))
+	assert.False(t, retryableError(other))
+
+	reset := &ProxyWriteError{Err: fmt.Errorf("Unable to upload data to proxy (2): %w", syscall.ECONNRESET)}
+	assert.True(t, retryableError(reset), "Connection resets are retried")
+}
+
+func TestProxyIdleTimeout(t *testing.T) {
+	p := &Proxy{}
+	idle, stop := p.idleTimeout(0)
+	assert.Nil(t, idle, "No timeout")
+	stop()
+
+	start := time.Now()
+	idle, stop = p.idleTimeout(100 * time.Millisecond)
+	defer stop()
+	for time.Since(start) < 300*time.Millisecond {
+		select {
+		case <-idle:
+			t.Fatal("Timed out despite data moving")
+		case <-time.After(20 * time.Millisecond):
+			p.touch()
+		}
+	}
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("Didn't time out once the data stopped")
+	}
+	assert.Greater(t, int64(time.Since(start)), int64(400*time.Millisecond), "Longer than the timeout in total")
+}
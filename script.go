@@ -0,0 +1,122 @@
+/*
+	Running Exasol (e.g. Lua) scripts:
+
+	res, err := conn.ExecuteScript("etl.load_day", "2020-01-02", 3, true)
+
+	runs EXECUTE SCRIPT "ETL"."LOAD_DAY"('2020-01-02', 3, TRUE) with the
+	parameters rendered as quoted SQL literals so they can't inject SQL.
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*--- Public Interface ---*/
+
+// Runs the script (optionally schema-qualified) with the given parameters
+// returning its result set keyed by column name, each value being that
+// column's values ([]interface{}) in row order. The script must return a
+// table (i.e. be created with RETURNS TABLE). Parameters may be nil (NULL),
+// strings, bools, ints, floats or []interface{} (an ARRAY of those).
+func (c *Conn) ExecuteScript(name string, params ...interface{}) (map[string]interface{}, error) {
+	sql, err := executeScriptSQL(name, params)
+	if err != nil {
+		return nil, c.errorf("Unable to ExecuteScript: %s", err)
+	}
+	names, data, err := c.FetchColumns(sql)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]interface{}, len(names))
+	for i, col := range names {
+		res[col] = data[i]
+	}
+	return res, nil
+}
+
+/*--- Private Routines ---*/
+
+func executeScriptSQL(name string, params []interface{}) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("no script name given")
+	}
+	if !isQuotedIdent.MatchString(name) {
+		// Dots can't appear within identifiers so they separate the schema
+		parts := strings.Split(name, ".")
+		for i, part := range parts {
+			parts[i] = quoteName(identName(part))
+		}
+		name = strings.Join(parts, ".")
+	}
+	args, err := scriptLiterals(params)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("EXECUTE SCRIPT %s(%s)", name, args), nil
+}
+
+func scriptLiterals(params []interface{}) (string, error) {
+	lits := make([]string, len(params))
+	for i, param := range params {
+		var err error
+		lits[i], err = scriptLiteral(param)
+		if err != nil {
+			return "", fmt.Errorf("parameter %d: %s", i+1, err)
+		}
+	}
+	return strings.Join(lits, ", "), nil
+}
+
+func scriptLiteral(param interface{}) (string, error) {
+	switch v := param.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return QuoteString(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return scriptLiteral(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("%v has no SQL literal", v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []interface{}:
+		lits, err := scriptLiterals(v)
+		if err != nil {
+			return "", err
+		}
+		return "ARRAY(" + lits + ")", nil
+	}
+	return "", fmt.Errorf("unsupported type %T", param)
+}
@@ -0,0 +1,50 @@
+package exasol
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *testSuite) TestExecuteScript() {
+	exa := s.exaConn
+	s.execute(`CREATE OR REPLACE LUA SCRIPT echo (a, b) RETURNS TABLE AS
+		exit({{a, b}, {a, b}}, "a VARCHAR(20), b DECIMAL(18,0)")`)
+
+	got, err := exa.ExecuteScript(s.qschema+".echo", "it's", 3)
+	if s.NoError(err) {
+		s.Equal(map[string]interface{}{
+			"A": []interface{}{"it's", "it's"},
+			"B": []interface{}{float64(3), float64(3)},
+		}, got)
+	}
+
+	exa.Conf.SuppressError = true
+	_, err = exa.ExecuteScript(s.qschema + ".missing_script")
+	s.Error(err)
+	_, err = exa.ExecuteScript("echo", struct{}{})
+	s.EqualError(err, "Unable to ExecuteScript: parameter 1: unsupported type struct {}")
+}
+
+func TestExecuteScriptSQL(t *testing.T) {
+	got, err := executeScriptSQL("etl.load", []interface{}{
+		"'; DROP TABLE t; --", nil, true, 3, int64(-4), 1.5, []interface{}{"a", 2},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, `EXECUTE SCRIPT "ETL"."LOAD"('''; DROP TABLE t; --', NULL, TRUE, 3, -4, 1.5, ARRAY('a', 2))`, got)
+	}
+	got, err = executeScriptSQL(`"etl"."My Script"`, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `EXECUTE SCRIPT "etl"."My Script"()`, got, "Already quoted")
+	}
+	got, err = executeScriptSQL(`do"it`, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `EXECUTE SCRIPT "DO""IT"()`, got)
+	}
+
+	_, err = executeScriptSQL("", nil)
+	assert.Error(t, err)
+	_, err = executeScriptSQL("s", []interface{}{1, math.NaN()})
+	assert.EqualError(t, err, "parameter 2: NaN has no SQL literal")
+}
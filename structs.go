@@ -0,0 +1,166 @@
+/*
+	Bulk inserting Go structs via the IMPORT proxy.
+
+	Each exported struct field maps to a column named by its db tag
+	(or the field name if untagged). Fields tagged db:"-" are skipped.
+
+	type person struct {
+		ID    int     `db:"id"`
+		Name  string  `db:"name"`
+		Email *string `db:"email"` // nil is imported as NULL
+	}
+	n, err := conn.BulkInsertStructs(schema, "people", []person{...})
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*--- Public Interface ---*/
+
+// rows must be a slice of structs (or struct pointers). Every exported
+// field is imported, into the column named by its db tag or else by the
+// field's name, except those tagged db:"-". Table columns without a
+// field get their defaults. Returns the number of rows imported.
+func (c *Conn) BulkInsertStructs(schema, table string, rows interface{}, opts ...ImportOptions) (int64, error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, c.errorf("BulkInsertStructs expects a slice of structs not %T", rows)
+	}
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return 0, c.errorf("BulkInsertStructs expects a slice of structs not %T", rows)
+	}
+	fields := structColumns(elemType)
+	if len(fields) == 0 {
+		return 0, c.errorf("%s has no exported fields to insert", elemType)
+	}
+
+	o := importOpts(opts)
+	o.Columns = make([]string, len(fields))
+	for i, f := range fields {
+		o.Columns[i] = f.column
+	}
+	sql := c.getTableImportSQL(schema, table, o)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeStructsCSV(pw, v, fields))
+	}()
+	n, err := c.ReaderExecute(sql, pr, o)
+	pr.Close() // Unblocks the writer if the import failed early
+	return n, err
+}
+
+/*--- Private Routines ---*/
+
+type structColumn struct {
+	column string
+	index  []int
+}
+
+func structColumns(t reflect.Type) []structColumn {
+	var cols []structColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // Unexported
+		}
+		name := f.Tag.Get("db")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = f.Name
+		}
+		cols = append(cols, structColumn{column: name, index: f.Index})
+	}
+	return cols
+}
+
+func writeStructsCSV(w io.Writer, rows reflect.Value, fields []structColumn) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(fields))
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				return fmt.Errorf("Row %d is a nil pointer", i+1)
+			}
+			row = row.Elem()
+		}
+		for j, f := range fields {
+			val, err := csvValue(row.FieldByIndex(f.index))
+			if err != nil {
+				return fmt.Errorf("Invalid value in row %d, column %s: %s", i+1, f.column, err)
+			}
+			record[j] = val
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Exasol imports empty CSV fields (so nil pointers and empty strings) as NULLs
+func csvValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	val, err := convertBind(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	switch x := val.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return FormatTimestamp(DataType{Type: "TIMESTAMP"}, x), nil
+	case []byte:
+		return string(x), nil
+	case string:
+		return x, nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return rv.String(), nil
+	}
+	return fmt.Sprint(rv.Interface()), nil
+}
@@ -0,0 +1,62 @@
+package exasol
+
+import (
+	"database/sql"
+	"time"
+)
+
+type testPerson struct {
+	ID      int            `db:"id"`
+	Name    string         `db:"name"`
+	Email   *string        `db:"email"`
+	Score   float64        `db:"score"`
+	Active  bool           `db:"active"`
+	Joined  time.Time      `db:"joined"`
+	Nick    sql.NullString `db:"nick"`
+	Ignored string         `db:"-"`
+	private string         // Unexported fields are skipped
+	Comment string         // Untagged so maps to the COMMENT column
+}
+
+func (s *testSuite) TestBulkInsertStructs() {
+	s.execute(`CREATE TABLE foo (
+		id INT, name VARCHAR(100), email VARCHAR(100), score DOUBLE, active BOOLEAN,
+		joined TIMESTAMP, nick VARCHAR(10), ignored VARCHAR(10), comment VARCHAR(100)
+	)`)
+
+	email := "bob@example.com"
+	joined := time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	rows := []testPerson{
+		{
+			ID: 1, Name: `Bob "the builder", Jr`, Email: &email, Score: 1.5,
+			Active: true, Joined: joined, Nick: sql.NullString{String: "bobby", Valid: true},
+			Ignored: "x", private: "y", Comment: "multi\nline",
+		},
+		{ID: 2, Name: "Null Fields"},
+	}
+	n, err := s.exaConn.BulkInsertStructs(s.qschema, "FOO", rows)
+	s.Require().NoError(err)
+	s.Equal(int64(2), n, "Rows imported")
+
+	got := s.fetch("SELECT * FROM foo ORDER BY id")
+	expect := [][]interface{}{
+		{
+			float64(1), `Bob "the builder", Jr`, email, float64(1.5), true,
+			"2020-01-02 03:04:05.006000", "bobby", nil, "multi\nline",
+		},
+		{float64(2), "Null Fields", nil, float64(0), false, "0001-01-01 00:00:00.000000", nil, nil, nil},
+	}
+	s.Equal(expect, got)
+
+	// Pointers to structs work too
+	_, err = s.exaConn.BulkInsertStructs(s.qschema, "FOO", []*testPerson{{ID: 3}})
+	s.NoError(err)
+
+	s.exaConn.Conf.SuppressError = true
+	_, err = s.exaConn.BulkInsertStructs(s.qschema, "FOO", testPerson{})
+	s.Error(err, "Not a slice")
+	_, err = s.exaConn.BulkInsertStructs(s.qschema, "FOO", []int{1})
+	s.Error(err, "Not a slice of structs")
+	_, err = s.exaConn.BulkInsertStructs(s.qschema, "FOO", []*testPerson{nil})
+	s.Error(err, "Nil row")
+}
@@ -0,0 +1,145 @@
+/*
+	A Tx groups statements into a transaction:
+
+	tx, err := conn.Begin()
+	if err != nil { ... }
+	defer tx.Rollback() // Does nothing once committed
+
+	_, err = tx.Execute("INSERT INTO t VALUES (1)")
+	if err != nil { return err }
+	return tx.Commit()
+
+
+	AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import "errors"
+
+// Returned when using a Tx after it has been committed or rolled back
+var ErrTxDone = errors.New("Transaction has already been committed or rolled back")
+
+// Returned when using a Tx after the Conn has reconnected to a new
+// session, as the transaction's uncommitted work went with the old one
+var ErrTxLost = errors.New("Transaction was lost as the connection reconnected")
+
+// A Tx isn't safe for concurrent use. A session only has a single
+// transaction so while a Tx is open the Conn is held via Conn.Lock
+// which other Go routines should also use to coordinate.
+type Tx struct {
+	conn       *Conn
+	done       bool
+	autoCommit bool   // Whether to re-enable autocommit when done
+	sessionID  uint64 // The session the transaction is in
+}
+
+// Begin disables autocommit (if enabled) for the duration of the transaction
+func (c *Conn) Begin() (*Tx, error) {
+	c.Lock()
+	attrs, err := c.GetAttributes()
+	if err != nil {
+		c.Unlock()
+		return nil, err
+	}
+	tx := &Tx{conn: c, sessionID: c.SessionID}
+	if attrs.Autocommit != nil && *attrs.Autocommit {
+		err = c.DisableAutoCommit()
+		if err != nil {
+			c.Unlock()
+			return nil, err
+		}
+		tx.autoCommit = true
+	}
+	return tx, nil
+}
+
+// Takes the same args as Conn.Execute
+func (tx *Tx) Execute(sql string, args ...interface{}) (int64, error) {
+	if err := tx.check(); err != nil {
+		return 0, err
+	}
+	return tx.conn.Execute(sql, args...)
+}
+
+// Takes the same args as Conn.Exec
+func (tx *Tx) Exec(sql string, binds ...interface{}) (int64, error) {
+	if err := tx.check(); err != nil {
+		return 0, err
+	}
+	return tx.conn.Exec(sql, binds...)
+}
+
+// Takes the same args as Conn.FetchSlice
+func (tx *Tx) FetchSlice(sql string, args ...interface{}) ([][]interface{}, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+	return tx.conn.FetchSlice(sql, args...)
+}
+
+// Takes the same args as Conn.FetchStream
+func (tx *Tx) FetchStream(sql string, args ...interface{}) (*ResultStream, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+	return tx.conn.FetchStream(sql, args...)
+}
+
+// Returns ErrTxLost (and commits nothing) if the Conn has since reconnected
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if tx.lost() {
+		return tx.end(ErrTxLost)
+	}
+	return tx.end(tx.conn.Commit())
+}
+
+// Returns ErrTxDone (and does nothing) if already committed or rolled back
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if tx.lost() {
+		return tx.end(ErrTxLost)
+	}
+	return tx.end(tx.conn.Rollback())
+}
+
+/*--- Private Routines ---*/
+
+func (tx *Tx) check() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if tx.lost() {
+		return ErrTxLost
+	}
+	return nil
+}
+
+// Whether the Conn has reconnected (which re-enables autocommit)
+func (tx *Tx) lost() bool {
+	return tx.conn.SessionID != tx.sessionID
+}
+
+func (tx *Tx) end(err error) error {
+	tx.done = true
+	defer tx.conn.Unlock()
+	if tx.autoCommit && !tx.lost() {
+		if e := tx.conn.EnableAutoCommit(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
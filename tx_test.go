@@ -0,0 +1,113 @@
+package exasol
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *testSuite) TestTx() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id INT )")
+
+	// Rolled back
+	tx, err := exa.Begin()
+	s.Require().NoError(err)
+	attrs, _ := exa.GetAttributes()
+	s.False(*attrs.Autocommit, "Autocommit disabled within the Tx")
+	_, err = tx.Execute("INSERT INTO foo VALUES (1)")
+	s.NoError(err)
+	got, err := tx.FetchSlice("SELECT COUNT(*) FROM foo")
+	if s.NoError(err) {
+		s.Equal(float64(1), got[0][0], "Visible within the Tx")
+	}
+	s.NoError(tx.Rollback())
+	attrs, _ = exa.GetAttributes()
+	s.True(*attrs.Autocommit, "Autocommit restored")
+	got = s.fetch("SELECT COUNT(*) FROM foo")
+	s.Equal(float64(0), got[0][0], "Rolled back")
+
+	// Committed
+	tx, err = exa.Begin()
+	s.Require().NoError(err)
+	_, err = tx.Exec("INSERT INTO foo VALUES (?)", 2)
+	s.NoError(err)
+	s.NoError(tx.Commit())
+	got = s.fetch("SELECT COUNT(*) FROM foo")
+	s.Equal(float64(1), got[0][0], "Committed")
+
+	// Use after commit
+	s.ErrorIs(tx.Rollback(), ErrTxDone, "Rollback is a no-op")
+	s.ErrorIs(tx.Commit(), ErrTxDone)
+	_, err = tx.Execute("INSERT INTO foo VALUES (3)")
+	s.ErrorIs(err, ErrTxDone)
+	_, err = tx.Exec("INSERT INTO foo VALUES (?)", 3)
+	s.ErrorIs(err, ErrTxDone)
+	_, err = tx.FetchSlice("SELECT * FROM foo")
+	s.ErrorIs(err, ErrTxDone)
+	got = s.fetch("SELECT COUNT(*) FROM foo")
+	s.Equal(float64(1), got[0][0], "Nothing more inserted")
+}
+
+func (s *testSuite) TestTxAutoCommitDisabled() {
+	exa := s.exaConn
+	s.execute("CREATE TABLE foo ( id INT )")
+	exa.DisableAutoCommit()
+	defer exa.EnableAutoCommit()
+
+	tx, err := exa.Begin()
+	s.Require().NoError(err)
+	tx.Execute("INSERT INTO foo VALUES (1)")
+	s.NoError(tx.Commit())
+	attrs, _ := exa.GetAttributes()
+	s.False(*attrs.Autocommit, "Autocommit left disabled")
+}
+
+func TestTxNoAutoReconnect(t *testing.T) {
+	fake := &fakeWSHandler{override: map[string]string{
+		"getAttributes": `{"status":"ok","attributes":{"autocommit":true}}`,
+	}}
+	wsh := &sessionLossWSHandler{WSHandler: fake}
+	c := newFakeConn(t, ConnConf{
+		Logger:        customTestLogger("fatal"),
+		AutoReconnect: true,
+		SuppressError: true,
+	}, wsh)
+	defer c.Disconnect()
+	logins := func() int {
+		n := 0
+		for _, req := range fake.requests {
+			if req["command"] == "login" {
+				n++
+			}
+		}
+		return n
+	}
+
+	tx, err := c.Begin()
+	require.NoError(t, err)
+	atomic.StoreInt32(&wsh.lose, 1)
+	_, err = tx.Execute("INSERT INTO t VALUES (1)")
+	if assert.Error(t, err, "Not retried in a new session") {
+		assert.Contains(t, err.Error(), "session not found")
+	}
+	assert.Equal(t, 1, logins())
+	assert.NoError(t, tx.Rollback())
+
+	atomic.StoreInt32(&wsh.lose, 1)
+	_, err = c.Execute("INSERT INTO t VALUES (1)")
+	assert.NoError(t, err, "Retried once the Tx is over")
+	assert.Equal(t, 2, logins())
+
+	tx, err = c.Begin()
+	require.NoError(t, err)
+	fake.override["<nil>"] = `{"status":"ok","responseData":{"sessionId":456,"protocolVersion":1}}`
+	require.NoError(t, c.Reconnect())
+	_, err = tx.Execute("INSERT INTO t VALUES (1)")
+	assert.ErrorIs(t, err, ErrTxLost)
+	assert.ErrorIs(t, tx.Commit(), ErrTxLost, "Nothing is committed")
+	assert.ErrorIs(t, tx.Commit(), ErrTxDone)
+	assert.False(t, c.inTransaction(), "The new session has autocommit on")
+}
@@ -0,0 +1,366 @@
+/*
+	Helpers for converting between Go types and the string
+	representations Exasol uses for its non-JSON-native types.
+
+    AUTHOR
+
+	Grant Street Group <developers@grantstreet.com>
+
+	COPYRIGHT AND LICENSE
+
+	This software is Copyright (c) 2019 by Grant Street Group.
+	This is free software, licensed under:
+	    MIT License
+*/
+
+package exasol
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+const timestampLayout = "2006-01-02 15:04:05"
+
+// Exasol's default fractional second precision for timestamps
+// (and INTERVAL DAY TO SECOND values)
+const defaultTimestampPrecision = 3
+
+var yearToMonthRE = regexp.MustCompile(`^([+-]?)(\d+)-(\d+)$`)
+var dayToSecondRE = regexp.MustCompile(`^([+-]?)(\d+) (\d+):(\d+):(\d+)(?:\.(\d{1,9}))?$`)
+var geometryTypeRE = regexp.MustCompile(`(?i)^\s*([a-z]+)\s*(\(|empty\b)`)
+
+/*--- Public Interface ---*/
+
+// Parses a DATE or TIMESTAMP value as returned by Exasol into a time.Time in UTC.
+// For TIMESTAMP WITH LOCAL TIME ZONE columns the value is in
+// the session's time zone so use ParseTimestampIn with the
+// location returned by Conn.SessionTimeZone instead.
+func ParseTimestamp(col DataType, s string) (time.Time, error) {
+	return ParseTimestampIn(col, s, time.UTC)
+}
+
+// The same as ParseTimestamp but the value is interpreted as being in loc
+func ParseTimestampIn(col DataType, s string, loc *time.Location) (time.Time, error) {
+	layout := timestampLayout
+	if strings.ToUpper(col.Type) == "DATE" {
+		layout = dateLayout
+	}
+	// Go accepts fractional seconds even though the layout doesn't specify them
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return t, fmt.Errorf("Unable to parse %s value '%s': %s", col.Type, s, err)
+	}
+	return t, nil
+}
+
+// Formats t as the literal Exasol expects for a DATE or TIMESTAMP column.
+// Timestamps are formatted using their own location's wall clock.
+func FormatTimestamp(col DataType, t time.Time) string {
+	if strings.ToUpper(col.Type) == "DATE" {
+		return t.Format(dateLayout)
+	}
+	precision := defaultTimestampPrecision
+	if col.Precision > 0 && col.Precision <= 9 {
+		precision = col.Precision
+	}
+	return t.Format(timestampLayout + "." + strings.Repeat("0", precision))
+}
+
+// Returns the Exasol FORMAT matching FormatTimestamp's output for a DATE or
+// TIMESTAMP column, e.g. for ImportOptions.ColumnFormats. Otherwise "".
+func CSVFormat(col DataType) string {
+	switch strings.ToUpper(col.Type) {
+	case "DATE":
+		return "YYYY-MM-DD"
+	case "TIMESTAMP", "TIMESTAMP WITH LOCAL TIME ZONE":
+		precision := defaultTimestampPrecision
+		if col.Precision > 0 && col.Precision <= 9 {
+			precision = col.Precision
+		}
+		return fmt.Sprintf("YYYY-MM-DD HH24:MI:SS.FF%d", precision)
+	}
+	return ""
+}
+
+// An INTERVAL YEAR TO MONTH value, e.g. "+01-06" is {Years: 1, Months: 6}.
+// For negative intervals both Years and Months are negative (or zero).
+type IntervalYearToMonth struct {
+	Years  int
+	Months int
+}
+
+// Parses an INTERVAL YEAR TO MONTH value as returned by Exasol
+func ParseIntervalYearToMonth(s string) (IntervalYearToMonth, error) {
+	m := yearToMonthRE.FindStringSubmatch(s)
+	if m == nil {
+		return IntervalYearToMonth{}, fmt.Errorf("Unable to parse INTERVAL YEAR TO MONTH value '%s'", s)
+	}
+	years, _ := strconv.Atoi(m[2])
+	months, _ := strconv.Atoi(m[3])
+	if m[1] == "-" {
+		years, months = -years, -months
+	}
+	return IntervalYearToMonth{Years: years, Months: months}, nil
+}
+
+// Formats the interval as the literal Exasol expects, e.g. "-01-06"
+func (i IntervalYearToMonth) String() string {
+	sign := "+"
+	years, months := i.Years, i.Months
+	if years < 0 || months < 0 {
+		sign = "-"
+		years, months = -years, -months
+	}
+	return fmt.Sprintf("%s%02d-%02d", sign, years, months)
+}
+
+// Parses an INTERVAL DAY TO SECOND value as returned by Exasol,
+// e.g. "+02 12:30:00.500". Values beyond what a time.Duration
+// can hold (about 292 years) are an error.
+func ParseIntervalDayToSecond(s string) (time.Duration, error) {
+	m := dayToSecondRE.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("Unable to parse INTERVAL DAY TO SECOND value '%s'", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		n, err := strconv.ParseInt(m[i+2], 10, 64)
+		if err != nil || (n > 0 && (1<<63-1-d)/unit < time.Duration(n)) {
+			return 0, fmt.Errorf("Unable to parse INTERVAL DAY TO SECOND value '%s': out of range", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if frac := m[6]; frac != "" {
+		nanos, _ := strconv.Atoi(frac + strings.Repeat("0", 9-len(frac)))
+		d += time.Duration(nanos)
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// Formats d as the literal Exasol expects for an INTERVAL DAY TO SECOND
+// column with the column's Fraction digits of fractional seconds.
+func FormatIntervalDayToSecond(col DataType, d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	s := fmt.Sprintf("%s%d %02d:%02d:%02d", sign, days, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	fraction := defaultTimestampPrecision
+	if col.Fraction > 0 && col.Fraction <= 9 {
+		fraction = col.Fraction
+	}
+	nanos := fmt.Sprintf("%09d", d%time.Second)
+	return s + "." + nanos[:fraction]
+}
+
+// A GEOMETRY value. Exasol returns them as WKT (well-known text) so
+// pass WKT to your geospatial library of choice to parse it further.
+type Geometry struct {
+	Type string // The WKT geometry type in uppercase, e.g. POINT or POLYGON
+	WKT  string
+	SRID int // The column's spatial reference system (0 if unspecified)
+}
+
+// Wraps a GEOMETRY value as returned by Exasol
+func ParseGeometry(col DataType, s string) (Geometry, error) {
+	m := geometryTypeRE.FindStringSubmatch(s)
+	if m == nil {
+		return Geometry{}, fmt.Errorf("Unable to parse GEOMETRY value '%s'", s)
+	}
+	return Geometry{Type: strings.ToUpper(m[1]), WKT: s, SRID: col.SRId}, nil
+}
+
+// Returns the session's current time zone which is what
+// TIMESTAMP WITH LOCAL TIME ZONE values are relative to.
+func (c *Conn) SessionTimeZone() (*time.Location, error) {
+	tz := ""
+	attr, err := c.GetSessionAttr()
+	if err == nil && attr != nil {
+		tz = attr.Timezone
+	}
+	if tz == "" && c.Metadata != nil {
+		tz = c.Metadata.TimeZone
+	}
+	if tz == "" {
+		return nil, c.error("Unable to determine the session time zone")
+	}
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return nil, c.errorf("Unable to load session time zone: %s", err)
+	}
+	return loc, nil
+}
+
+// DECIMALs with up to this many digits (and no scale) fit in an int64
+const maxInt64Precision = 18
+
+// The largest integer a float64 (and so Exasol's JSON parsing) holds exactly
+const maxExactInt = 1 << 53
+
+// Exasol sends most DECIMALs as JSON numbers which are decoded as float64s.
+// Those with more significant digits than a float64 holds may be off so
+// this errors rather than let them be silently wrong.
+func checkExactDecimal(col DataType, f float64) error {
+	if math.Abs(f)*math.Pow10(col.Scale) > maxExactInt {
+		return fmt.Errorf("Unable to convert DECIMAL value %v exactly: it has more digits than a float64 holds", f)
+	}
+	return nil
+}
+
+// Converts a value as returned by the Fetch methods into the Go type for its
+// column: integral DECIMALs of up to 18 digits to int64, other DECIMALs to
+// their decimal string, DOUBLEs to float64, BOOLEANs to bool, DATEs
+// and TIMESTAMPs to time.Time (in loc for TIMESTAMP WITH LOCAL TIME ZONE
+// columns, otherwise UTC), INTERVALs to IntervalYearToMonth or time.Duration,
+// GEOMETRYs to Geometry and everything else to string. NULLs are nil.
+// DECIMALs received as float64s with more than 2^53 significant digits
+// (e.g. DECIMAL(18,0)s above 9007199254740992) can't have been decoded
+// exactly so they return an error.
+func ConvertValue(col DataType, val interface{}, loc *time.Location) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	typ := strings.ToUpper(col.Type)
+	switch v := val.(type) {
+	case bool:
+		if typ == "BOOLEAN" {
+			return v, nil
+		}
+	case float64:
+		switch {
+		case typ == "DOUBLE":
+			return v, nil
+		case typ == "DECIMAL":
+			if err := checkExactDecimal(col, v); err != nil {
+				return nil, err
+			}
+			if col.Scale == 0 && col.Precision <= maxInt64Precision {
+				return int64(v), nil
+			}
+			return strconv.FormatFloat(v, 'f', col.Scale, 64), nil
+		}
+	case string:
+		switch typ {
+		case "DECIMAL":
+			if col.Scale == 0 && col.Precision <= maxInt64Precision {
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to parse DECIMAL value '%s': %s", v, err)
+				}
+				return n, nil
+			}
+			return v, nil
+		case "DOUBLE":
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse DOUBLE value '%s': %s", v, err)
+			}
+			return f, nil
+		case "DATE", "TIMESTAMP":
+			if !col.WithLocalTimeZone || loc == nil {
+				loc = time.UTC
+			}
+			return ParseTimestampIn(col, v, loc)
+		case "INTERVAL YEAR TO MONTH":
+			return ParseIntervalYearToMonth(v)
+		case "INTERVAL DAY TO SECOND":
+			return ParseIntervalDayToSecond(v)
+		case "GEOMETRY":
+			return ParseGeometry(col, v)
+		case "BOOLEAN":
+			// Not expected but cheap to handle
+			return strconv.ParseBool(v)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("Unable to convert %s value %v (%T)", col.Type, val, val)
+}
+
+// A ResultStream whose Next converts the values via ConvertValue
+type TypedStream struct {
+	*ResultStream
+	loc *time.Location
+	err error
+}
+
+// The same as FetchStream but each row's values are converted to Go types
+// (see ConvertValue) as they're streamed. TIMESTAMP WITH LOCAL TIME ZONE
+// values are in the session's time zone (see SessionTimeZone).
+func (c *Conn) FetchTypedStream(sql string, args ...interface{}) (*TypedStream, error) {
+	stream, err := c.FetchStream(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	ts := &TypedStream{ResultStream: stream, loc: time.UTC}
+	for _, col := range stream.columns {
+		if col.DataType.WithLocalTimeZone {
+			// Requests are serialized so this is fine while the fetching runs
+			if ts.loc, err = c.SessionTimeZone(); err != nil {
+				stream.CloseEarly()
+				return nil, err
+			}
+			break
+		}
+	}
+	return ts, nil
+}
+
+// Returns the next row with its values converted. The bool is false once
+// all rows have been returned, an error occurred fetching them or a value
+// couldn't be converted (in which case the rest of the rows are discarded).
+func (ts *TypedStream) Next() ([]interface{}, bool) {
+	if ts.err != nil {
+		return nil, false
+	}
+	row, ok := ts.ResultStream.Next()
+	if !ok {
+		return nil, false
+	}
+	for i, val := range row {
+		row[i], ts.err = ConvertValue(ts.columns[i].DataType, val, ts.loc)
+		if ts.err != nil {
+			ts.CloseEarly()
+			return nil, false
+		}
+	}
+	return row, true
+}
+
+// Returns the error, if any, that stopped the stream including conversion
+// errors. This is only meaningful once Next has returned false.
+func (ts *TypedStream) Err() error {
+	if ts.err != nil {
+		return ts.err
+	}
+	return ts.ResultStream.Err()
+}
+
+/*--- Private Routines ---*/
+
+var tzWordStart = regexp.MustCompile(`(^|[/_-])[a-z]`)
+
+// Exasol reports time zones in uppercase (e.g. EUROPE/BERLIN)
+// whereas the tz database is case sensitive (e.g. Europe/Berlin)
+func loadLocation(tz string) (*time.Location, error) {
+	loc, err := time.LoadLocation(tz)
+	if err == nil {
+		return loc, nil
+	}
+	titled := tzWordStart.ReplaceAllStringFunc(strings.ToLower(tz), strings.ToUpper)
+	if loc, e := time.LoadLocation(titled); e == nil {
+		return loc, nil
+	}
+	return nil, err
+}
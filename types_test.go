@@ -0,0 +1,251 @@
+package exasol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	got, err := ParseTimestamp(DataType{Type: "DATE"}, "2021-03-04")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), got)
+	}
+	got, err = ParseTimestamp(DataType{Type: "TIMESTAMP"}, "2021-03-04 05:06:07.891")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC), got)
+	}
+	got, err = ParseTimestamp(DataType{Type: "TIMESTAMP"}, "2021-03-04 05:06:07")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), got)
+	}
+	_, err = ParseTimestamp(DataType{Type: "DATE"}, "asdf")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to parse DATE")
+	}
+
+	loc, _ := loadLocation("EUROPE/BERLIN")
+	got, err = ParseTimestampIn(DataType{Type: "TIMESTAMP", WithLocalTimeZone: true}, "2021-03-04 05:06:07", loc)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2021, 3, 4, 4, 6, 7, 0, time.UTC), got.UTC())
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891234000, time.UTC)
+	assert.Equal(t, "2021-03-04", FormatTimestamp(DataType{Type: "DATE"}, ts))
+	assert.Equal(t, "2021-03-04 05:06:07.891", FormatTimestamp(DataType{Type: "TIMESTAMP"}, ts))
+	assert.Equal(t, "2021-03-04 05:06:07.891234", FormatTimestamp(DataType{Type: "TIMESTAMP", Precision: 6}, ts))
+}
+
+func TestLoadLocation(t *testing.T) {
+	for tz, expect := range map[string]string{
+		"UTC":              "UTC",
+		"EUROPE/BERLIN":    "Europe/Berlin",
+		"AMERICA/NEW_YORK": "America/New_York",
+		"America/Chicago":  "America/Chicago",
+	} {
+		loc, err := loadLocation(tz)
+		if assert.NoError(t, err, tz) {
+			assert.Equal(t, expect, loc.String())
+		}
+	}
+	_, err := loadLocation("ASDF/QWERTY")
+	assert.Error(t, err)
+}
+
+func (s *testSuite) TestTimestampBinds() {
+	exa := s.exaConn
+	exa.Execute("CREATE TABLE foo ( d DATE, t TIMESTAMP, l TIMESTAMP WITH LOCAL TIME ZONE )")
+	exa.Execute("ALTER SESSION SET TIME_ZONE = 'EUROPE/BERLIN'")
+	defer exa.Execute("ALTER SESSION SET TIME_ZONE = 'UTC'")
+
+	t := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	_, err := exa.Execute("INSERT INTO foo VALUES (?,?,?)", []interface{}{t, t, t})
+	s.Nil(err)
+
+	got, err := exa.FetchSlice("SELECT d, t, l FROM foo")
+	if s.NoError(err) {
+		s.Equal([][]interface{}{{
+			"2021-03-04", "2021-03-04 05:06:07.891000", "2021-03-04 06:06:07.891000",
+		}}, got, "Local timestamps are in the session time zone")
+	}
+
+	loc, err := exa.SessionTimeZone()
+	if s.NoError(err) {
+		s.Equal("Europe/Berlin", loc.String())
+		l, err := ParseTimestampIn(DataType{Type: "TIMESTAMP", WithLocalTimeZone: true}, got[0][2].(string), loc)
+		if s.NoError(err) {
+			s.True(t.Equal(l), "Round-tripped the local timestamp")
+		}
+	}
+}
+
+func TestParseIntervals(t *testing.T) {
+	ym, err := ParseIntervalYearToMonth("+01-06")
+	if assert.NoError(t, err) {
+		assert.Equal(t, IntervalYearToMonth{Years: 1, Months: 6}, ym)
+		assert.Equal(t, "+01-06", ym.String())
+	}
+	ym, err = ParseIntervalYearToMonth("-100-02")
+	if assert.NoError(t, err) {
+		assert.Equal(t, IntervalYearToMonth{Years: -100, Months: -2}, ym)
+		assert.Equal(t, "-100-02", ym.String())
+	}
+	_, err = ParseIntervalYearToMonth("1 year")
+	assert.EqualError(t, err, "Unable to parse INTERVAL YEAR TO MONTH value '1 year'")
+
+	d, err := ParseIntervalDayToSecond("+02 12:30:05.5")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2*24*time.Hour+12*time.Hour+30*time.Minute+5500*time.Millisecond, d)
+		assert.Equal(t, "+2 12:30:05.500", FormatIntervalDayToSecond(DataType{}, d))
+	}
+	d, err = ParseIntervalDayToSecond("-00 00:00:01.000001")
+	if assert.NoError(t, err) {
+		assert.Equal(t, -time.Second-time.Microsecond, d)
+		assert.Equal(t, "-0 00:00:01.000001", FormatIntervalDayToSecond(DataType{Fraction: 6}, d))
+	}
+	_, err = ParseIntervalDayToSecond("+999999999 00:00:00")
+	assert.EqualError(t, err, "Unable to parse INTERVAL DAY TO SECOND value '+999999999 00:00:00': out of range")
+	_, err = ParseIntervalDayToSecond("12:30")
+	assert.Error(t, err)
+}
+
+func TestParseGeometry(t *testing.T) {
+	g, err := ParseGeometry(DataType{Type: "GEOMETRY", SRId: 4326}, "POINT (1 2)")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Geometry{Type: "POINT", WKT: "POINT (1 2)", SRID: 4326}, g)
+	}
+	g, err = ParseGeometry(DataType{Type: "GEOMETRY"}, "polygon EMPTY")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "POLYGON", g.Type)
+	}
+	_, err = ParseGeometry(DataType{Type: "GEOMETRY"}, "asdf")
+	assert.EqualError(t, err, "Unable to parse GEOMETRY value 'asdf'")
+}
+
+func (s *testSuite) TestIntervalGeometryColumns() {
+	stream, err := s.exaConn.FetchStream(`
+		SELECT CAST('+01-06' AS INTERVAL YEAR TO MONTH),
+		       CAST('+02 12:30:05.5' AS INTERVAL DAY(3) TO SECOND(6)),
+		       CAST('POINT (1 2)' AS GEOMETRY(4326))`)
+	s.Require().NoError(err)
+	types := stream.Types()
+	row, ok := stream.Next()
+	s.Require().True(ok)
+	stream.CloseEarly()
+
+	s.Equal("INTERVAL YEAR TO MONTH", types[0].Type)
+	ym, err := ParseIntervalYearToMonth(row[0].(string))
+	s.NoError(err)
+	s.Equal(IntervalYearToMonth{Years: 1, Months: 6}, ym)
+
+	s.Equal("INTERVAL DAY TO SECOND", types[1].Type)
+	s.Equal(6, types[1].Fraction)
+	d, err := ParseIntervalDayToSecond(row[1].(string))
+	s.NoError(err)
+	s.Equal(60*time.Hour+30*time.Minute+5500*time.Millisecond, d)
+
+	s.Equal("GEOMETRY", types[2].Type)
+	g, err := ParseGeometry(types[2], row[2].(string))
+	s.NoError(err)
+	s.Equal(Geometry{Type: "POINT", WKT: "POINT (1 2)", SRID: 4326}, g)
+}
+
+func TestConvertValue(t *testing.T) {
+	berlin, err := loadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	tests := []struct {
+		col    DataType
+		val    interface{}
+		expect interface{}
+	}{
+		{DataType{Type: "DECIMAL", Precision: 18}, float64(123), int64(123)},
+		{DataType{Type: "DECIMAL", Precision: 18}, "-123", int64(-123)},
+		{DataType{Type: "DECIMAL", Precision: 18}, float64(-1 << 53), int64(-1 << 53)},
+		{DataType{Type: "DECIMAL", Precision: 36}, "123456789012345678901234567890", "123456789012345678901234567890"},
+		{DataType{Type: "DECIMAL", Precision: 10, Scale: 2}, 12.5, "12.50"},
+		{DataType{Type: "DECIMAL", Precision: 10, Scale: 2}, nil, nil},
+		{DataType{Type: "DOUBLE"}, 0.25, 0.25},
+		{DataType{Type: "BOOLEAN"}, true, true},
+		{DataType{Type: "VARCHAR"}, "a", "a"},
+		{DataType{Type: "DATE"}, "2021-03-04", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{DataType{Type: "TIMESTAMP"}, "2021-03-04 05:06:07.891000", ts},
+		{DataType{Type: "TIMESTAMP", WithLocalTimeZone: true}, "2021-03-04 06:06:07.891000", ts.In(berlin)},
+		{DataType{Type: "INTERVAL YEAR TO MONTH"}, "+01-06", IntervalYearToMonth{Years: 1, Months: 6}},
+		{DataType{Type: "INTERVAL DAY TO SECOND"}, "+00 00:00:01.500", 1500 * time.Millisecond},
+		{DataType{Type: "GEOMETRY"}, "POINT (1 2)", Geometry{Type: "POINT", WKT: "POINT (1 2)"}},
+	}
+	for _, test := range tests {
+		got, err := ConvertValue(test.col, test.val, berlin)
+		if assert.NoError(t, err, test.col.Type) {
+			assert.Equal(t, test.expect, got, test.col.Type)
+		}
+	}
+
+	_, err = ConvertValue(DataType{Type: "DECIMAL", Precision: 18}, "1.5", nil)
+	assert.Error(t, err)
+	_, err = ConvertValue(DataType{Type: "VARCHAR"}, 1.5, nil)
+	assert.EqualError(t, err, "Unable to convert VARCHAR value 1.5 (float64)")
+	_, err = ConvertValue(DataType{Type: "DECIMAL", Precision: 18}, float64(999999999999999999), nil)
+	assert.Error(t, err, "Not exact as a float64")
+	_, err = ConvertValue(DataType{Type: "DECIMAL", Precision: 18, Scale: 4}, 1234567890123.4567, nil)
+	assert.Error(t, err, "Nor are too many decimal places")
+}
+
+func (s *testSuite) TestFetchTypedStream() {
+	exa := s.exaConn
+	exa.Execute("ALTER SESSION SET TIME_ZONE = 'EUROPE/BERLIN'")
+	defer exa.Execute("ALTER SESSION SET TIME_ZONE = 'UTC'")
+
+	stream, err := exa.FetchTypedStream(`
+		SELECT level, CAST(level / 2 AS DECIMAL(10,1)), CAST(level AS DOUBLE), level > 1,
+		       CAST('2021-03-04 06:06:07.891' AS TIMESTAMP WITH LOCAL TIME ZONE), NULL
+		FROM dual CONNECT BY level <= 2500`)
+	s.Require().NoError(err)
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	numRows := 0
+	for row, ok := stream.Next(); ok; row, ok = stream.Next() {
+		numRows++
+		if numRows == 2 {
+			s.Equal([]interface{}{int64(2), "1.0", float64(2), true}, row[:4])
+			s.True(ts.Equal(row[4].(time.Time)), "In the session time zone")
+			s.Nil(row[5])
+		}
+	}
+	s.NoError(stream.Err())
+	s.Equal(2500, numRows)
+}
+
+func TestTypedStreamFake(t *testing.T) {
+	fake := &fakeWSHandler{}
+	c := newFakeConn(t, ConnConf{}, fake)
+	defer c.Disconnect()
+
+	stream, err := c.FetchTypedStream("SELECT id, val FROM t")
+	require.NoError(t, err)
+	var got [][]interface{}
+	for row, ok := stream.Next(); ok; row, ok = stream.Next() {
+		got = append(got, row)
+	}
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, [][]interface{}{{int64(1), "a"}, {int64(2), "b"}}, got)
+
+	fake.override = map[string]string{
+		"execute": `{"status":"ok","responseData":{"numResults":1,"results":[{
+			"resultType":"resultSet","resultSet":{"resultSetHandle":0,"numColumns":1,"numRows":2,
+			"numRowsInMessage":2,"columns":[{"name":"D","dataType":{"type":"DATE"}}],
+			"data":[["2021-03-04","asdf"]]}}]}}`,
+	}
+	stream, err = c.FetchTypedStream("SELECT d FROM t")
+	require.NoError(t, err)
+	row, ok := stream.Next()
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)}, row)
+	_, ok = stream.Next()
+	assert.False(t, ok)
+	assert.ErrorContains(t, stream.Err(), "Unable to parse DATE value 'asdf'")
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -25,6 +26,11 @@ import (
 var keywordLock sync.RWMutex
 var keywords map[string]bool
 
+// Already quoted (possibly schema-qualified) identifiers e.g. [s].[t] or "s"."t"
+var isQuotedIdent = regexp.MustCompile(
+	`^(\[[^\]]*\]|"(""|[^"])*")(\.(\[[^\]]*\]|"(""|[^"])*"|[A-Za-z][A-Za-z0-9_]*))*$`,
+)
+
 /*--- Public Interface ---*/
 
 // The optional second argument to QuoteIdent is for backwards compatibility.
@@ -43,7 +49,7 @@ func (c *Conn) QuoteIdent(ident string, args ...interface{}) string {
 		}
 	}
 
-	if regexp.MustCompile(`^(\[|")`).MatchString(ident) {
+	if isQuotedIdent.MatchString(ident) {
 		// Return if already quoted
 		return ident
 	}
@@ -55,7 +61,9 @@ func (c *Conn) QuoteIdent(ident string, args ...interface{}) string {
 			sql := "SELECT LOWER(keyword) FROM sys.exa_sql_keywords WHERE reserved"
 			kwRes, _ := c.FetchChan(sql)
 			for col := range kwRes {
-				kw[col[0].(string)] = true
+				if k, ok := col[0].(string); ok {
+					kw[k] = true
+				}
 			}
 			keywords = kw
 		}
@@ -75,18 +83,62 @@ func (c *Conn) QuoteIdent(ident string, args ...interface{}) string {
 		// For quoted identifiers any characters can be contained within
 		// the quotation marks except the dot ('.')
 		ident = regexp.MustCompile(`\.`).ReplaceAllString(ident, "_")
-		return fmt.Sprintf(`[%s]`, strings.ToUpper(ident))
+		ident = strings.ToUpper(ident)
+		if strings.ContainsAny(ident, `"]`) {
+			// Brackets can't be escaped so fall back to double quotes
+			return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+		}
+		return fmt.Sprintf(`[%s]`, ident)
 	}
 	return ident
 }
 
+// Escapes the single quotes in str. See also QuoteString.
 func QuoteStr(str string) string {
 	return regexp.MustCompile("'").ReplaceAllString(str, "''")
 }
 
+// Returns str as a single-quoted SQL string literal
+func QuoteString(str string) string {
+	return "'" + QuoteStr(str) + "'"
+}
+
+// Splits a script into its statements in the manner of EXAplus. Statements
+// are terminated by semicolons except those in string literals, quoted
+// identifiers and comments. The bodies of CREATE SCRIPT/FUNCTION statements
+// may contain semicolons so they're instead terminated by a line containing
+// only a slash. Empty statements and the terminators are omitted.
+func SplitStatements(script string) []string {
+	var stmts []string
+	for len(script) > 0 {
+		var end, next int
+		if isCreateScript.MatchString(script) {
+			end, next = slashLine(script)
+		} else {
+			end = statementEnd(script)
+			next = end + 1
+		}
+		if stmt := strings.TrimSpace(script[:end]); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		if next >= len(script) {
+			break
+		}
+		script = script[next:]
+	}
+	return stmts
+}
+
+// Transposes the rows of matrix into columns (or vice versa). Rows shorter
+// than the longest are padded with nils (i.e. NULLs if used as binds).
 func Transpose(matrix [][]interface{}) [][]interface{} {
 	numRows := len(matrix)
-	numCols := len(matrix[0])
+	numCols := 0
+	for _, row := range matrix {
+		if len(row) > numCols {
+			numCols = len(row)
+		}
+	}
 	ret := make([][]interface{}, numCols)
 
 	for x, _ := range ret {
@@ -102,29 +154,119 @@ func Transpose(matrix [][]interface{}) [][]interface{} {
 
 /*--- Private Routines ---*/
 
-func (c *Conn) error(text string) error {
-	err := errors.New(text)
-	if !c.Conf.SuppressError {
-		c.log.Error(err)
+// Returns an error if the matrix's rows (or columns, per what) differ in length
+func checkRagged(matrix [][]interface{}, what string) error {
+	for i, row := range matrix {
+		if len(row) != len(matrix[0]) {
+			return fmt.Errorf("Bind %s %d has %d values but %s 1 has %d",
+				what, i+1, len(row), what, len(matrix[0]))
+		}
 	}
-	return err
+	return nil
+}
+
+var isCreateScript = regexp.MustCompile(
+	`(?is)^(\s|--[^\n]*\n|/\*.*?\*/)*CREATE\s+(OR\s+REPLACE\s+)?` +
+		`((LUA|PYTHON\d*|JAVA|R|SCALAR|SET|ADAPTER|UDF)\s+)*(SCRIPT|FUNCTION)\b`,
+)
+
+var isSlashLine = regexp.MustCompile(`(?m)^[ \t]*/[ \t]*\r?$`)
+
+// Returns the index of the semicolon ending the first statement
+// (or the length of script if there isn't one)
+func statementEnd(script string) int {
+	for i := 0; i < len(script); i++ {
+		switch {
+		case script[i] == ';':
+			return i
+		case script[i] == '\'' || script[i] == '"':
+			// Doubled quotes are escapes so they're just two adjacent literals
+			j := strings.IndexByte(script[i+1:], script[i])
+			if j < 0 {
+				return len(script)
+			}
+			i += j + 1
+		case strings.HasPrefix(script[i:], "--"):
+			j := strings.IndexByte(script[i:], '\n')
+			if j < 0 {
+				return len(script)
+			}
+			i += j
+		case strings.HasPrefix(script[i:], "/*"):
+			j := strings.Index(script[i+2:], "*/")
+			if j < 0 {
+				return len(script)
+			}
+			i += j + 3
+		}
+	}
+	return len(script)
+}
+
+// Returns the start and end of the first line containing only a slash
+// (or the length of script if there isn't one)
+func slashLine(script string) (int, int) {
+	loc := isSlashLine.FindStringIndex(script)
+	if loc == nil {
+		return len(script), len(script)
+	}
+	return loc[0], loc[1]
+}
+
+func (c *Conn) error(text string) error {
+	return c.logError(errors.New(text))
 }
 
 func (c *Conn) errorf(format string, args ...interface{}) error {
-	err := fmt.Errorf(format, args...)
-	if c.Conf.SuppressError == false {
+	return c.logError(fmt.Errorf(format, args...))
+}
+
+func (c *Conn) logError(err error) error {
+	if !c.Conf.SuppressError {
 		c.log.Error(err)
 	}
 	return err
 }
 
-func transposeToChan(ch chan<- []interface{}, matrix [][]interface{}) {
+// Returns false if it was stopped before sending all the rows
+func transposeToChan(ch chan<- []interface{}, matrix [][]interface{}, stop <-chan bool) bool {
 	// matrix is columnar ... this transposes it to rowular
 	for row := range matrix[0] {
 		ret := make([]interface{}, len(matrix))
 		for col := range matrix {
 			ret[col] = matrix[col][row]
 		}
-		ch <- ret
+		select {
+		case <-stop:
+			return false
+		case ch <- ret:
+		}
+	}
+	return true
+}
+
+var versionPart = regexp.MustCompile(`^\d+`)
+
+// Splits a version like "7.1.2-rc1" into its numeric parts, i.e. [7 1 2]
+func versionParts(version string) []int {
+	var parts []int
+	for _, p := range strings.Split(version, ".") {
+		digits := versionPart.FindString(p)
+		if digits == "" {
+			break
+		}
+		n, _ := strconv.Atoi(digits)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// Returns the name of the identifier as stored in the data dictionary,
+// i.e. unquoted identifiers uppercased and quoted ones as is.
+func identName(ident string) string {
+	n := len(ident)
+	if n >= 2 && (ident[0] == '"' && ident[n-1] == '"' || ident[0] == '[' && ident[n-1] == ']') {
+		return strings.ReplaceAll(ident[1:n-1], `""`, `"`)
 	}
+	return strings.ToUpper(ident)
 }
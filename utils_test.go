@@ -1,5 +1,11 @@
 package exasol
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
 func (s *testSuite) TestQuoteIdent() {
 	exa := s.exaConn
 	s.Equal("[test]", exa.QuoteIdent("[test]"), "Already quoted")
@@ -11,12 +17,106 @@ func (s *testSuite) TestQuoteIdent() {
 	s.Equal("okAY", exa.QuoteIdent("okAY"), "Default")
 }
 
+func (s *testSuite) TestQuoteIdentEscaping() {
+	exa := s.exaConn
+	tests := []struct {
+		ident  string
+		expect string
+	}{
+		{`[s].[t]`, `[s].[t]`},
+		{`"s"."t"`, `"s"."t"`},
+		{`[test].foo`, `[test].foo`},
+		{`"my""id"`, `"my""id"`},
+		{`my"id`, `"MY""ID"`},
+		{`"unbalanced`, `"""UNBALANCED"`},
+		{`"a" OR 1=1 --`, `"""A"" OR 1=1 --"`},
+		{`my]id`, `"MY]ID"`},
+		{"new\nline", "[NEW\nLINE]"},
+		{`café`, `[CAFÉ]`},
+	}
+	for _, t := range tests {
+		s.Equal(t.expect, exa.QuoteIdent(t.ident), t.ident)
+	}
+
+	// The quoted identifiers work as table names
+	for _, name := range []string{`my"id`, "new\nline", `café`} {
+		ident := exa.QuoteIdent(name)
+		s.execute("CREATE TABLE " + ident + " ( id INT )")
+		s.execute("INSERT INTO " + ident + " VALUES (1)")
+		got := s.fetch("SELECT * FROM " + ident)
+		s.Equal([][]interface{}{{float64(1)}}, got, name)
+	}
+}
+
 func (s *testSuite) TestQuoteStr() {
 	s.Equal("my''str", QuoteStr("my'str"))
 }
 
+func (s *testSuite) TestQuoteString() {
+	tests := []struct {
+		str    string
+		expect string
+	}{
+		{``, `''`},
+		{`plain`, `'plain'`},
+		{`my'str`, `'my''str'`},
+		{`''`, `''''''`},
+		{`'; DROP TABLE foo; --`, `'''; DROP TABLE foo; --'`},
+		{`back\slash`, `'back\slash'`},
+		{"new\nline", "'new\nline'"},
+		{`"double"`, `'"double"'`},
+		{`ünïcødé ☃`, `'ünïcødé ☃'`},
+	}
+	for _, t := range tests {
+		s.Equal(t.expect, QuoteString(t.str), t.str)
+		// And it round trips through Exasol
+		got := s.fetch("SELECT " + QuoteString(t.str) + " || 'x'")
+		s.Equal(t.str+"x", got[0][0], t.str)
+	}
+}
+
 func (s *testSuite) TestTranspose() {
 	data := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}
 	expect := [][]interface{}{{1, 2, 3}, {"a", "b", "c"}}
 	s.Equal(expect, Transpose(data))
+
+	s.Equal([][]interface{}{}, Transpose(nil), "Empty")
+	s.Equal([][]interface{}{{1}, {"a"}}, Transpose([][]interface{}{{1, "a"}}), "Single row")
+	s.Equal([][]interface{}{{1, 2, 3}, {"a", nil, "c"}, {nil, nil, true}},
+		Transpose([][]interface{}{{1, "a"}, {2}, {3, "c", true}}), "Ragged rows are padded")
+}
+
+func TestIdentName(t *testing.T) {
+	assert.Equal(t, "FOO", identName("foo"))
+	assert.Equal(t, "foo", identName(`"foo"`))
+	assert.Equal(t, `my"foo`, identName(`"my""foo"`))
+	assert.Equal(t, "foo", identName("[foo]"))
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		script string
+		expect []string
+	}{
+		{"", nil},
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"a; b;;\n c;", []string{"a", "b", "c"}},
+		{`SELECT 'x;''y' FROM "a;b"; SELECT 2 /* ; */`, []string{`SELECT 'x;''y' FROM "a;b"`, "SELECT 2 /* ; */"}},
+		{"SELECT 1 -- ;\n;", []string{"SELECT 1 -- ;"}},
+		{"SELECT 4 / 2;", []string{"SELECT 4 / 2"}},
+		{
+			"CREATE TABLE t (id INT);\nCREATE OR REPLACE PYTHON3 SCALAR SCRIPT s() AS\na = 1; b = 'c'\n/\nSELECT 1;",
+			[]string{"CREATE TABLE t (id INT)", "CREATE OR REPLACE PYTHON3 SCALAR SCRIPT s() AS\na = 1; b = 'c'", "SELECT 1"},
+		},
+		{"CREATE FUNCTION f RETURN INT IS BEGIN RETURN 1; END f;", []string{"CREATE FUNCTION f RETURN INT IS BEGIN RETURN 1; END f;"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expect, SplitStatements(test.script), test.script)
+	}
+}
+
+func TestVersionParts(t *testing.T) {
+	assert.Equal(t, []int{7, 1, 2}, versionParts("7.1.2"))
+	assert.Equal(t, []int{8, 0}, versionParts("8.0-rc1"))
+	assert.Nil(t, versionParts(""))
 }
@@ -13,12 +13,16 @@
 package exasol
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/url"
-	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -53,7 +57,8 @@ func (c *Conn) wsConnect() (err error) {
 }
 
 func (c *Conn) wsConnectHost(host string) error {
-	uri := fmt.Sprintf("%s:%d", host, c.Conf.Port)
+	host = strings.Trim(host, "[]") // IPv6 literals may be given bracketed
+	uri := net.JoinHostPort(host, strconv.Itoa(int(c.Conf.Port)))
 	scheme := "ws"
 	if c.Conf.TLSConfig != nil {
 		scheme = "wss"
@@ -64,11 +69,18 @@ func (c *Conn) wsConnectHost(host string) error {
 	}
 	c.log.Debugf("Connecting to %s", u.String())
 
-	return c.wsh.Connect(u, c.Conf.TLSConfig, c.Conf.ConnectTimeout)
+	err := c.wsh.Connect(u, c.Conf.TLSConfig, c.Conf.ConnectTimeout)
+	if err == nil {
+		c.host = host
+	}
+	return err
 }
 
 // Request and Response are pointers to structs representing the API JSON.
 // The Response struct is updated in-place.
+//
+// Exasol's protocol is synchronous per session so each request/response
+// pair holds sendMux, making concurrent use of a Conn safe.
 
 func (c *Conn) send(request, response interface{}) error {
 	receiver, err := c.asyncSend(request)
@@ -78,28 +90,95 @@ func (c *Conn) send(request, response interface{}) error {
 	return receiver(response)
 }
 
+// The same as send but for commands that are safe to resend (e.g. getting
+// or setting attributes). With AutoReconnect if sending fails it reconnects
+// and sends it once more, unless autocommit is disabled as reconnecting
+// would lose any uncommitted work.
+func (c *Conn) sendIdempotent(request, response interface{}) error {
+	err := c.send(request, response)
+	if err == nil || !c.Conf.AutoReconnect || !errors.Is(err, errSend) {
+		return err
+	}
+	if c.inTransaction() {
+		c.log.Warning("Unable to send command, not reconnecting as autocommit is disabled: ", err)
+		return err
+	}
+	c.log.Warning("Unable to send command, reconnecting: ", err)
+	if e := c.Reconnect(); e != nil {
+		c.log.Warning(e)
+		return err
+	}
+	return c.send(request, response)
+}
+
+// The returned receiver must be called to release the connection
+// for other requests.
 func (c *Conn) asyncSend(request interface{}) (func(interface{}) error, error) {
-	err := c.wsh.WriteJSON(request)
+	c.sendMux.Lock()
+	if c.wsh == nil {
+		c.sendMux.Unlock()
+		return nil, ErrConnClosed
+	}
+	// Any earlier abort no longer applies
+	atomic.StoreInt32(&c.aborting, 0)
+
+	err := c.write(request)
 	if err != nil {
-		return nil, c.errorf("WebSocket API Error sending: %s", err)
+		atomic.StoreInt32(&c.broken, 1)
+		c.sendMux.Unlock()
+		c.onError(err)
+		return nil, c.errorf("%w: %s", errSend, err)
 	}
 
+	var once sync.Once
 	return func(response interface{}) error {
-		err = c.wsh.ReadJSON(response)
+		defer once.Do(c.sendMux.Unlock)
+		err := c.receive(response)
 		if err != nil {
-			if regexp.MustCompile(`abnormal closure`).
-				MatchString(err.Error()) {
-				return fmt.Errorf("Server terminated statement")
-			}
-			return fmt.Errorf("WebSocket API Error recving: %s", err)
-		}
-		r := reflect.Indirect(reflect.ValueOf(response))
-		status := r.FieldByName("Status").String()
-		if status != "ok" {
-			err := reflect.Indirect(r.FieldByName("Exception")).
-				FieldByName("Text").String()
-			return fmt.Errorf("Server Error: %s", err)
+			c.onError(err)
 		}
-		return nil
+		return err
 	}, nil
 }
+
+func (c *Conn) receive(response interface{}) error {
+	if c.wsh == nil {
+		return ErrConnClosed
+	}
+	err := c.wsh.ReadJSON(response)
+	if err != nil {
+		atomic.StoreInt32(&c.broken, 1)
+		if regexp.MustCompile(`abnormal closure`).
+			MatchString(err.Error()) {
+			return fmt.Errorf("Server terminated statement")
+		}
+		return fmt.Errorf("WebSocket API Error recving: %s", err)
+	}
+	sr, ok := response.(statusResponse)
+	if !ok {
+		return fmt.Errorf("%w: unexpected response type %T", ErrProtocol, response)
+	}
+	status, exception := sr.status()
+	if status != "ok" {
+		if exception == nil {
+			return fmt.Errorf("%w: response status %q has no exception", ErrProtocol, status)
+		}
+		if atomic.CompareAndSwapInt32(&c.aborting, 1, 0) {
+			return fmt.Errorf("%w: %s", ErrQueryAborted, exception.Text)
+		}
+		return fmt.Errorf("%w: %s", errServer, exception.Text)
+	}
+	if dr, ok := response.(dataResponse); ok && !dr.hasData() {
+		return fmt.Errorf("%w: response has no responseData", ErrProtocol)
+	}
+	return nil
+}
+
+func (c *Conn) write(request interface{}) error {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	if c.wsh == nil {
+		return ErrConnClosed
+	}
+	return c.wsh.WriteJSON(request)
+}
@@ -13,7 +13,12 @@
 package exasol
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -24,41 +29,148 @@ import (
 // and conforms to the WSHandler interface
 
 type defWSHandler struct {
-	ws *websocket.Conn
+	ws           *websocket.Conn
+	dialer       websocket.Dialer
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	stopPings    chan bool
+	deadline     time.Time // Overall bound on I/O (e.g. during login) if set
+	readBy       time.Time // When the current read times out (if readTimeout is set)
 }
 
 func newDefaultWSHandler() *defWSHandler {
-	return &defWSHandler{}
+	return &defWSHandler{dialer: defaultDialer}
 }
 
+// By default HTTP_PROXY, HTTPS_PROXY and NO_PROXY are respected
 var defaultDialer = *websocket.DefaultDialer
 
 func init() {
-	defaultDialer.Proxy = nil // TODO use proxy env
 	defaultDialer.EnableCompression = false
 }
 
+// Applies the ConnConf dialer, ping and read timeout options
+func (wsh *defWSHandler) configure(conf ConnConf) {
+	wsh.pingInterval = conf.PingInterval
+	wsh.readTimeout = conf.readTimeout()
+	if conf.Dialer != nil {
+		wsh.dialer.NetDialContext = conf.Dialer.DialContext
+	}
+	if conf.HTTPProxy != nil {
+		wsh.dialer.Proxy = http.ProxyURL(conf.HTTPProxy)
+	} else if conf.IgnoreProxyEnv {
+		wsh.dialer.Proxy = nil
+	}
+}
+
+// Makes the handshake go over conn rather than dialing (so proxies don't apply)
+func (wsh *defWSHandler) useNetConn(conn net.Conn) {
+	wsh.dialer.Proxy = nil
+	wsh.dialer.NetDialContext = func(context.Context, string, string) (net.Conn, error) {
+		return conn, nil
+	}
+}
+
 func (wsh *defWSHandler) Connect(url url.URL, tls *tls.Config, timeout time.Duration) error {
 	if timeout != time.Duration(0) {
-		defaultDialer.HandshakeTimeout = timeout
+		wsh.dialer.HandshakeTimeout = timeout
 	}
-	defaultDialer.TLSClientConfig = tls
+	wsh.dialer.TLSClientConfig = tls
 
-	// According to documentation:
-	// > It is safe to call Dialer's methods concurrently.
-	ws, _, err := defaultDialer.Dial(url.String(), nil)
+	ws, _, err := wsh.dialer.Dial(url.String(), nil)
 	if err != nil {
 		return err
 	}
 
 	wsh.ws = ws
+	if wsh.pingInterval > 0 {
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(wsh.readDeadline())
+		})
+		wsh.stopPings = make(chan bool)
+		go wsh.ping(ws, wsh.stopPings)
+	}
 	return nil
 }
 
 func (wsh *defWSHandler) WriteJSON(req interface{}) error { return wsh.ws.WriteJSON(req) }
-func (wsh *defWSHandler) ReadJSON(resp interface{}) error { return wsh.ws.ReadJSON(resp) }
 func (wsh *defWSHandler) EnableCompression(e bool)        { wsh.ws.EnableWriteCompression(e) }
+
+func (wsh *defWSHandler) ReadJSON(resp interface{}) error {
+	if wsh.pingInterval == 0 && wsh.readTimeout == 0 {
+		return wsh.ws.ReadJSON(resp)
+	}
+	wsh.readBy = time.Time{}
+	if wsh.readTimeout > 0 {
+		wsh.readBy = time.Now().Add(wsh.readTimeout)
+	}
+	// Pongs are only processed while reading so the deadline is only
+	// set while we're waiting on a response. Each pong extends it.
+	wsh.ws.SetReadDeadline(wsh.readDeadline())
+	err := wsh.ws.ReadJSON(resp)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && !wsh.pastDeadline() {
+		if !wsh.readBy.IsZero() && !time.Now().Before(wsh.readBy) {
+			return fmt.Errorf("No response within the ReadTimeout of %s: %w", wsh.readTimeout, err)
+		}
+		return fmt.Errorf("Connection is dead, no pong received within %s: %w", wsh.pongWait(), err)
+	}
+	return err
+}
+
 func (wsh *defWSHandler) Close() {
+	if wsh.stopPings != nil {
+		close(wsh.stopPings)
+		wsh.stopPings = nil
+	}
 	wsh.ws.Close()
 	wsh.ws = nil
 }
+
+// A zero t clears the deadline
+func (wsh *defWSHandler) setDeadline(t time.Time) {
+	wsh.deadline = t
+	wsh.ws.SetWriteDeadline(t)
+	wsh.ws.SetReadDeadline(t)
+}
+
+func (wsh *defWSHandler) pastDeadline() bool {
+	return !wsh.deadline.IsZero() && !time.Now().Before(wsh.deadline)
+}
+
+// Pongs extend the read deadline but not beyond the read timeout
+// or any overall deadline
+func (wsh *defWSHandler) readDeadline() time.Time {
+	var t time.Time
+	if wsh.pingInterval > 0 {
+		t = time.Now().Add(wsh.pongWait())
+	}
+	for _, limit := range []time.Time{wsh.readBy, wsh.deadline} {
+		if !limit.IsZero() && (t.IsZero() || limit.Before(t)) {
+			t = limit
+		}
+	}
+	return t
+}
+
+// How long to wait for a pong, allowing for one to go missing
+func (wsh *defWSHandler) pongWait() time.Duration {
+	return 2 * wsh.pingInterval
+}
+
+func (wsh *defWSHandler) ping(ws *websocket.Conn, stop <-chan bool) {
+	ticker := time.NewTicker(wsh.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// WriteControl is safe to call concurrently with WriteJSON
+			err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsh.pingInterval))
+			if err != nil {
+				return // The next read will report the dead connection
+			}
+		}
+	}
+}